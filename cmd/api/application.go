@@ -1,48 +1,92 @@
 package main
 
 import (
+	"HareID/config"
+	"HareID/internal/db"
 	"HareID/internal/repository"
 	"HareID/internal/services"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type application struct {
-	config     configs
+	config     config.Config
 	repository repository.Repository
 	services   services.Services
-}
 
-type configs struct {
-	api_port string
-	db       dbConfig
+	jobs sync.WaitGroup
 }
 
-type dbConfig struct {
-	url          string
-	key          string
-	maxOpenConns int
-	maxIdleConns int
-	maxIdleTime  time.Duration
+// Inicia um job em segundo plano. O desligamento espera os jobs terminarem antes de fechar o banco
+func (app *application) startJob(ctx context.Context, job func(ctx context.Context)) {
+	app.jobs.Add(1)
+
+	go func() {
+		defer app.jobs.Done()
+		job(ctx)
+	}()
 }
 
-func (app *application) run(r *http.Handler) error {
+// Atende até ctx ser cancelado (SIGTERM/SIGINT) e então desliga: o /readyz passa a falhar, a API segue
+// atendendo por DrainDelay, para de aceitar conexões, espera as requisições e os jobs até ShutdownTimeout
+// e fecha o pool do banco
+func (app *application) run(ctx context.Context, r *http.Handler) error {
 
 	server := &http.Server{
-		Addr:         app.config.api_port,
+		Addr:         app.config.Server.Port,
 		Handler:      *r,
-		WriteTimeout: time.Second * 30,
-		ReadTimeout:  time.Second * 10,
-		IdleTimeout:  time.Minute,
+		WriteTimeout: app.config.Server.WriteTimeout,
+		ReadTimeout:  app.config.Server.ReadTimeout,
+		IdleTimeout:  app.config.Server.IdleTimeout,
 	}
 
-	log.Printf("application started at port: %s", app.config.api_port)
-	log.Printf("Swagger UI: http://localhost%s/swagger/index.html", app.config.api_port)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	slog.Info("application started", "port", app.config.Server.Port, "swagger", app.config.Server.PublicURL+"/swagger/index.html")
 
-	if err := server.ListenAndServe(); err != nil {
+	select {
+	case err := <-serverErr:
 		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutdown: not ready, draining", "delay", app.config.Server.DrainDelay.String())
+	app.services.Health.StartDraining()
+	time.Sleep(app.config.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.Server.ShutdownTimeout)
+	defer cancel()
+
+	slog.Info("shutdown: waiting for in-flight requests")
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown", "error", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("shutdown", "error", err)
+	}
+
+	slog.Info("shutdown: waiting for background jobs")
+	jobsDone := make(chan struct{})
+	go func() {
+		app.jobs.Wait()
+		close(jobsDone)
+	}()
+
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("shutdown: deadline reached with jobs still running")
 	}
 
+	db.GetPool().Close()
+	slog.Info("shutdown: complete")
+
 	return nil
 }
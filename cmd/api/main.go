@@ -20,13 +20,30 @@ package main
 
 import (
 	"HareID/config"
+	"HareID/internal/authentication"
 	"HareID/internal/controllers"
 	"HareID/internal/db"
+	"HareID/internal/db/migrations"
+	"HareID/internal/jobs"
+	"HareID/internal/logging"
+	"HareID/internal/metrics"
+	"HareID/internal/middleware"
+	"HareID/internal/payments"
+	"HareID/internal/pii"
 	"HareID/internal/repository"
 	"HareID/internal/services"
+	"HareID/internal/tracing"
 	"HareID/internal/validators"
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
+
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
 )
 
 /*
@@ -45,45 +62,183 @@ func init() {
 */
 
 func main() {
-	config.Load()
-
-	dbConfig := dbConfig{
-		url:          config.SUPABASE_URL,
-		key:          config.SUPABASE_KEY,
-		maxOpenConns: 20,
-		maxIdleConns: 4,
-		maxIdleTime:  2 * time.Minute,
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("error loading configuration", "error", err)
+		os.Exit(1)
 	}
 
-	configs := configs{
-		api_port: config.PORT,
-		db:       dbConfig,
+	slog.SetDefault(logging.New(os.Stdout, cfg.Logging))
+	slog.Info("configuration loaded", "config", cfg.String())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("error initializing tracing", "error", err)
+		os.Exit(1)
 	}
 
-	if err := db.Inicialize(
-		int32(dbConfig.maxOpenConns),
-		int32(dbConfig.maxIdleConns),
-		dbConfig.maxIdleTime,
-	); err != nil {
-		log.Fatalf("error initializing database: %s", err)
+	if err := db.Inicialize(cfg.Database); err != nil {
+		slog.Error("error initializing database", "error", err)
+		os.Exit(1)
 	}
 
 	dbPool := db.GetPool()
 
-	repository := repository.NewRepository(dbPool)
+	// Subcomando de linha de comando: go run ./cmd/api migrate up|down|status
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(runMigrate(dbPool, args[1:]))
+	}
+
+	if cfg.Database.AutoMigrate {
+		applied, err := migrations.Up(context.Background(), dbPool)
+		if err != nil {
+			slog.Error("error applying migrations", "error", err)
+			os.Exit(1)
+		}
+		for _, migration := range applied {
+			slog.Info("migration applied", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	stripeClient := client.New(cfg.Stripe.SecretKey, stripeBackends(cfg.Stripe))
+
+	cipher, err := piiCipher(cfg)
+	if err != nil {
+		slog.Error("error initializing pii encryption", "error", err)
+		os.Exit(1)
+	}
+
+	repository := repository.NewRepository(dbPool, cipher)
 	validators := validators.NewValidator(repository)
-	services := services.NewServices(repository, validators, dbPool)
+	services := services.NewServices(cfg, repository, validators, dbPool, stripeClient, paymentProviders(cfg, stripeClient))
+
+	// Subcomando de linha de comando: go run ./cmd/api reconcile [--fix]
+	if len(args) > 0 && args[0] == "reconcile" {
+		os.Exit(runReconcile(services, args[1:]))
+	}
+
+	authentication.SetKeys(cfg.Auth)
+	middleware.SetAuthConfig(cfg.Auth)
+	middleware.SetTrustedProxies(cfg.Server.TrustedProxies)
+	middleware.SetEntitlementResolver(services.Entitlements)
+	middleware.SetSessionValidator(services.Users)
+	middleware.SetAdminChecker(services.Users)
+
+	if err := services.Users.PromoteAdmins(context.Background()); err != nil {
+		slog.Error("error promoting admin users", "error", err)
+		os.Exit(1)
+	}
+
+	metrics.RegisterPool(dbPool)
+	metrics.RegisterSubscriptions(services.Subscriptions.CountByStatus)
+
 	controllers := controllers.NewControllers(services)
-	router := createRouter(controllers)
+	router := createRouter(controllers, cfg.Server)
 
-	application := application{
-		config:     configs,
+	application := &application{
+		config:     cfg,
 		repository: repository,
 		services:   services,
 	}
 
-	if err := application.run(&router); err != nil {
-		log.Fatal("error on application init: ", err)
+	// Cancelado no SIGTERM (deploy) ou Ctrl+C: os jobs param de agendar e a API inicia o desligamento
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	application.startJob(ctx, func(ctx context.Context) { jobs.ProcessWebhooks(ctx, services) })
+	application.startJob(ctx, func(ctx context.Context) { jobs.ProcessExports(ctx, services) })
+	application.startJob(ctx, func(ctx context.Context) { jobs.ProcessDeletions(ctx, services) })
+
+	if cfg.Billing.LifecycleInterval > 0 {
+		application.startJob(ctx, func(ctx context.Context) {
+			jobs.BillingLifecycle(ctx, services, cfg.Billing.LifecycleInterval)
+		})
 	}
 
+	if cfg.Billing.UsageReportInterval > 0 {
+		application.startJob(ctx, func(ctx context.Context) {
+			jobs.ReportUsage(ctx, services, cfg.Billing.UsageReportInterval)
+		})
+	}
+
+	if cfg.Privacy.PIIRotateInterval > 0 {
+		application.startJob(ctx, func(ctx context.Context) {
+			jobs.RotateEncryptionKeys(ctx, services, cfg.Privacy.PIIRotateInterval)
+		})
+	}
+
+	if cfg.Billing.ReconcileInterval > 0 {
+		application.startJob(ctx, func(ctx context.Context) {
+			jobs.Reconcile(ctx, services, cfg.Billing.ReconcileInterval, cfg.Billing.ReconcileFix)
+		})
+	}
+
+	if err := application.run(ctx, &router); err != nil {
+		slog.Error("error on application init", "error", err)
+		os.Exit(1)
+	}
+
+	// Envia os spans que ainda estão no buffer do exportador
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("error flushing traces", "error", err)
+	}
+
+}
+
+// Provedores de pagamento disponíveis para os planos. O Asaas só é registrado quando configurado
+func paymentProviders(cfg config.Config, stripeClient *client.API) payments.Registry {
+	providers := payments.Registry{
+		payments.STRIPE: payments.NewStripe(stripeClient, cfg.Stripe.WebhookSecret),
+	}
+
+	if cfg.Asaas.APIKey != "" {
+		providers[payments.ASAAS] = payments.NewAsaas(cfg.Asaas.APIURL, cfg.Asaas.APIKey, cfg.Asaas.WebhookToken, cfg.Asaas.Timeout)
+	}
+
+	return providers
+}
+
+// Criptografia dos dados pessoais. Sem PII_MASTER_KEYS/PII_INDEX_KEY as chaves são derivadas da SECRET_KEY,
+// o que amarra os dados a ela e por isso só é aceito fora de produção (ver config.Validate)
+func piiCipher(cfg config.Config) (*pii.Cipher, error) {
+	masterKeys := cfg.Privacy.PIIMasterKeys
+	activeKey := cfg.Privacy.PIIActiveKey
+
+	if len(masterKeys) == 0 {
+		slog.Warn("PII_MASTER_KEYS not set: deriving the pii master key from SECRET_KEY")
+		masterKeys = map[string][]byte{"local": cfg.Auth.DerivedKey("pii")}
+		activeKey = "local"
+	}
+
+	keyring, err := pii.NewLocalKeyring(masterKeys, activeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey := cfg.Privacy.PIIIndexKey
+	if len(indexKey) == 0 {
+		slog.Warn("PII_INDEX_KEY not set: deriving the blind index key from SECRET_KEY")
+		indexKey = cfg.Auth.DerivedKey("pii-index")
+	}
+
+	return pii.NewCipher(keyring, indexKey)
+}
+
+// Cliente HTTP com STRIPE_TIMEOUT, apontado para STRIPE_API_URL (ex: stripe-mock) quando configurado
+func stripeBackends(cfg config.Stripe) *stripe.Backends {
+	backendConfig := &stripe.BackendConfig{
+		HTTPClient: &http.Client{Timeout: cfg.Timeout},
+	}
+
+	if cfg.APIURL != "" {
+		backendConfig.URL = stripe.String(cfg.APIURL)
+	}
+
+	return &stripe.Backends{
+		API:     stripe.GetBackendWithConfig(stripe.APIBackend, backendConfig),
+		Connect: stripe.GetBackendWithConfig(stripe.ConnectBackend, backendConfig),
+		Uploads: stripe.GetBackendWithConfig(stripe.UploadsBackend, backendConfig),
+	}
 }
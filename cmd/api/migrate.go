@@ -0,0 +1,77 @@
+package main
+
+import (
+	"HareID/internal/db/migrations"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Aplica, desfaz ou lista as migrações do esquema. Retorna 1 em caso de erro
+func runMigrate(pool *pgxpool.Pool, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status")
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, pool)
+		if err != nil {
+			slog.Error("migrate failed", "error", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "quantidade de migrações a desfazer")
+
+		if err := flags.Parse(args[1:]); err != nil {
+			return 1
+		}
+
+		reverted, err := migrations.Down(ctx, pool, *steps)
+		if err != nil {
+			slog.Error("migrate failed", "error", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+
+	case "status":
+		statuses, err := migrations.GetStatus(ctx, pool)
+		if err != nil {
+			slog.Error("migrate failed", "error", err)
+			return 1
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-24s %s\n", status.Version, status.Name, appliedAt)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "migrate: unknown command %q (use up, down or status)\n", args[0])
+		return 1
+	}
+
+	return 0
+}
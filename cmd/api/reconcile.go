@@ -0,0 +1,54 @@
+package main
+
+import (
+	"HareID/internal/services"
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+)
+
+// Executa a conciliação com o Stripe e imprime o relatório em JSON.
+// Retorna 1 em caso de erro e 2 quando sobram divergências não corrigidas
+func runReconcile(s services.Services, args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "grava o estado do Stripe nas divergências encontradas")
+	output := flags.String("output", "", "arquivo para o relatório (padrão: stdout)")
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	report, err := s.Reconciliation.Run(context.Background(), *fix)
+	if err != nil {
+		slog.Error("reconcile failed", "error", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			slog.Error("reconcile failed", "error", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("reconcile failed", "error", err)
+		return 1
+	}
+
+	for _, drift := range report.Drifts {
+		if !drift.Fixed {
+			return 2
+		}
+	}
+
+	return 0
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"HareID/config"
 	"HareID/internal/controllers"
+	"HareID/internal/metrics"
 	"HareID/internal/middleware"
 	"net/http"
 
@@ -12,46 +14,92 @@ import (
 	_ "HareID/docs"
 )
 
-func createRouter(controllers controllers.Controller) http.Handler {
+func createRouter(controllers controllers.Controller, cfg config.Server) http.Handler {
 
 	router := chi.NewRouter()
+	router.Use(middleware.Tracing)
+	router.Use(middleware.RequestMetadata)
+	router.Use(middleware.AccessLog)
+	router.Use(middleware.Metrics)
 
 	cors := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:4200"}, // Origem do seu Angular
+		AllowedOrigins:   cfg.CORSOrigins, // CORS_ALLOWED_ORIGINS (padrão: o Angular em http://localhost:4200)
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"X-Request-ID", "X-Trace-ID"},
 		AllowCredentials: true,
 		// Debug: true, // Ative para ver logs de CORS no terminal se der erro
 	})
 
+	// Sondas do orquestrador
+	router.Get("/healthz", controllers.Health.Liveness)
+	router.Get("/readyz", controllers.Health.Readiness)
+	router.Handle("/metrics", metrics.Handler())
+
 	//Rotas de usuários
 	router.Post("/webhook", controllers.Webhook.HandleWebhook)
+	router.Post("/webhook/asaas", controllers.Webhook.HandleAsaasWebhook)
 	router.Post("/login", controllers.Login.Login)
 	router.Post("/users", controllers.Users.Create)
-	router.Get("/users", middleware.Authenticate(controllers.Users.GetAll))
+	router.Get("/users", middleware.AuthenticateAdmin(controllers.Users.GetAll))
 	router.Get("/users/{user_id}", middleware.Authenticate(controllers.Users.GetByID))
 	router.Patch("/users/{user_id}", middleware.Authenticate(controllers.Users.Update))
 	router.Delete("/users/{user_id}", middleware.Authenticate(controllers.Users.Delete))
+	router.Get("/users/{user_id}/deletion", middleware.Authenticate(controllers.Users.GetDeletion))
+	router.Post("/users/{user_id}/deletion/cancel", middleware.Authenticate(controllers.Users.CancelDeletion))
+
+	router.Get("/users/{user_id}/teams", middleware.Authenticate(controllers.Users.GetUserTeam))
+	router.Get("/users/{user_id}/audit-log", middleware.Authenticate(controllers.Audit.GetUserLog))
 
-	router.Get("/users/{user_id}/teams", controllers.Users.GetUserTeam)
+	// Portabilidade de dados (LGPD). O download usa link assinado, sem token
+	router.Post("/users/{user_id}/export", middleware.Authenticate(controllers.Exports.Request))
+	router.Get("/users/{user_id}/exports/{export_id}", middleware.Authenticate(controllers.Exports.GetByID))
+	router.Get("/exports/{export_id}/download", controllers.Exports.Download)
+
+	// Termos e consentimentos (LGPD). POST /me/consents aceita o token restrito devolvido pelo login
+	router.Get("/terms/current", controllers.Consents.GetCurrentTerms)
+	router.Get("/me/consents", middleware.Authenticate(controllers.Consents.GetMine))
+	router.Post("/me/consents", middleware.AuthenticateConsent(controllers.Consents.Record))
 
 	//Rotas de Subscriptions
 	router.Post("/checkout-session", middleware.Authenticate(controllers.Checkout.CreateSession))
-	router.Post("/subscriptions", middleware.Authenticate(controllers.Subscriptions.Create))
-	router.Get("/subscriptions", middleware.Authenticate(controllers.Subscriptions.GetAll))
+	// Gravação direta, fora do fluxo de checkout e dos webhooks: só administradores
+	router.Post("/subscriptions", middleware.AuthenticateAdmin(controllers.Subscriptions.Create))
+	router.Get("/subscriptions", middleware.AuthenticateAdmin(controllers.Subscriptions.GetAll))
 	router.Get("/subscriptions/{subscription_id}", middleware.Authenticate(controllers.Subscriptions.GetBySubscriptionID))
-	router.Patch("/subscriptions/{subscription_id}", middleware.Authenticate(controllers.Subscriptions.Update))
-	router.Delete("/subscriptions/{subscription_id}", middleware.Authenticate(controllers.Subscriptions.Delete))
+	router.Patch("/subscriptions/{subscription_id}", middleware.AuthenticateAdmin(controllers.Subscriptions.Update))
+	router.Delete("/subscriptions/{subscription_id}", middleware.AuthenticateAdmin(controllers.Subscriptions.Delete))
+	router.Post("/subscriptions/{subscription_id}/cancel", middleware.Authenticate(controllers.Subscriptions.Cancel))
+	router.Post("/subscriptions/{subscription_id}/change-plan", middleware.Authenticate(controllers.Subscriptions.ChangePlan))
+
+	//Rotas de cobrança
+	router.Post("/billing/portal-session", middleware.Authenticate(controllers.Billing.CreatePortalSession))
+	router.Get("/billing/invoices", middleware.Authenticate(controllers.Billing.GetInvoices))
+
+	//Rotas do usuário autenticado (equivalentes, restritos a ele, das listagens administrativas)
+	router.Get("/me", middleware.Authenticate(controllers.Me.Get))
+	router.Get("/me/subscriptions", middleware.Authenticate(controllers.Me.GetSubscriptions))
+	router.Get("/me/teams", middleware.Authenticate(controllers.Me.GetTeams))
+	router.Get("/me/entitlements", middleware.Authenticate(controllers.Me.GetEntitlements))
+	router.Get("/me/billing-history", middleware.Authenticate(controllers.Me.GetBillingHistory))
+
+	//Rotas de planos
+	router.Get("/plans", controllers.Plans.GetAll)
 
 	//Rotas de teams
 	router.Post("/teams", middleware.Authenticate(controllers.Teams.Create))
-	router.Get("/teams", middleware.Authenticate(controllers.Teams.GetAll))
+	router.Get("/teams", middleware.AuthenticateAdmin(controllers.Teams.GetAll))
 	router.Get("/teams/{team_id}", middleware.Authenticate(controllers.Teams.GetByID))
 	router.Patch("/teams/{team_id}", middleware.Authenticate(controllers.Teams.Update))
 	router.Delete("/teams/{team_id}", middleware.Authenticate(controllers.Teams.Delete))
 
 	// Rotas de Team Member
 	router.Get("/teams/{team_id}/members", middleware.Authenticate(controllers.Teams.GetTeamMembers))
+	router.Delete("/teams/{team_id}/members/{user_id}", middleware.Authenticate(controllers.TeamMembers.Delete))
+	router.Post("/teams/{team_id}/checkout-session", middleware.Authenticate(controllers.Checkout.CreateTeamSession))
+	router.Get("/teams/{team_id}/usage", middleware.Authenticate(controllers.Usage.GetTeamUsage))
+	// Recurso pago: o plano da própria equipe precisa incluir audit_logs (verificado no serviço)
+	router.Get("/teams/{team_id}/audit-log", middleware.Authenticate(controllers.Audit.GetTeamLog))
 
 	//Rotas de Join Request
 	router.Post("/teams/{team_id}/join", middleware.Authenticate(controllers.JoinRequests.Create))
@@ -66,9 +114,20 @@ func createRouter(controllers controllers.Controller) http.Handler {
 	router.Get("/users/{user_id}/notifications/{notification_id}", middleware.Authenticate(controllers.Notifications.GetByID))
 	router.Delete("/users/{user_id}/notifications/{notification_id}", middleware.Authenticate(controllers.Notifications.Delete))
 
+	// Rotas internas, chamadas por outros serviços
+	router.Post("/internal/usage", middleware.AuthenticateInternal(controllers.Usage.Record))
+
+	//Rotas administrativas (users.is_admin)
+	router.Patch("/admin/users/{user_id}/admin", middleware.AuthenticateAdmin(controllers.Users.SetAdmin))
+	router.Get("/admin/users/lookup", middleware.AuthenticateAdmin(controllers.Users.GetByCpfCnpj))
+	router.Get("/admin/audit-log/verify", middleware.AuthenticateAdmin(controllers.Audit.Verify))
+	router.Post("/admin/terms", middleware.AuthenticateAdmin(controllers.Consents.PublishTerms))
+	router.Get("/admin/webhook-events", middleware.AuthenticateAdmin(controllers.Webhook.ListEvents))
+	router.Post("/admin/webhook-events/{event_id}/replay", middleware.AuthenticateAdmin(controllers.Webhook.ReplayEvent))
+
 	// Swagger
 	router.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"), //The url pointing to API definition
+		httpSwagger.URL(cfg.PublicURL+"/swagger/doc.json"), //The url pointing to API definition
 	))
 
 	handler := cors.Handler(router)
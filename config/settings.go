@@ -0,0 +1,352 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// Uma configuração lida do arquivo, do ambiente ou de uma flag. A flag é o nome em minúsculas com hífens.
+// Valores vazios contam como não definidos e mantêm o padrão
+type setting struct {
+	name   string
+	usage  string
+	secret bool
+	set    func(c *Config, value string) error
+	get    func(c *Config) string
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.name), "_", "-")
+}
+
+var settings = []setting{
+	stringSetting("APP_ENV", "ambiente: development ou production (exige as chaves de dados pessoais)", func(c *Config) *string { return &c.Server.Environment }),
+	stringSetting("API_PORT", "endereço em que a API escuta (ex: :8080)", func(c *Config) *string { return &c.Server.Port }),
+	urlSetting("API_PUBLIC_URL", "URL pública da API, usada nos links enviados aos usuários", func(c *Config) *string { return &c.Server.PublicURL }),
+	listSetting("CORS_ALLOWED_ORIGINS", "origens liberadas no CORS, separadas por vírgula", func(c *Config) *[]string { return &c.Server.CORSOrigins }),
+	setting{
+		name:  "TRUSTED_PROXIES",
+		usage: "IPs ou CIDRs dos proxies reversos cujo X-Forwarded-For é aceito, separados por vírgula",
+		set: func(c *Config, value string) error {
+			var proxies []netip.Prefix
+			for _, item := range splitList(value) {
+				prefix, err := parsePrefix(item)
+				if err != nil {
+					return err
+				}
+				proxies = append(proxies, prefix)
+			}
+			c.Server.TrustedProxies = proxies
+			return nil
+		},
+		get: func(c *Config) string {
+			proxies := make([]string, len(c.Server.TrustedProxies))
+			for i, prefix := range c.Server.TrustedProxies {
+				proxies[i] = prefix.String()
+			}
+			return strings.Join(proxies, ",")
+		},
+	},
+	durationSetting("HTTP_READ_TIMEOUT", "tempo máximo para ler a requisição", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationSetting("HTTP_WRITE_TIMEOUT", "tempo máximo para escrever a resposta", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	durationSetting("HTTP_IDLE_TIMEOUT", "tempo máximo de uma conexão keep-alive ociosa", func(c *Config) *time.Duration { return &c.Server.IdleTimeout }),
+	durationSetting("SHUTDOWN_DRAIN_DELAY", "tempo entre o /readyz falhar e o servidor parar de aceitar conexões", func(c *Config) *time.Duration { return &c.Server.DrainDelay }),
+	durationSetting("SHUTDOWN_TIMEOUT", "prazo para as requisições e jobs em andamento terminarem", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+
+	setting{
+		name:  "LOG_LEVEL",
+		usage: "nível mínimo dos logs: debug, info, warn ou error",
+		set: func(c *Config, value string) error {
+			return c.Logging.Level.UnmarshalText([]byte(value))
+		},
+		get: func(c *Config) string { return c.Logging.Level.String() },
+	},
+	stringSetting("LOG_FORMAT", "formato dos logs: json ou text", func(c *Config) *string { return &c.Logging.Format }),
+	stringSetting("TRACE_EXPORTER", "destino dos traces: none, otlp, stdout ou file", func(c *Config) *string { return &c.Tracing.Exporter }),
+	stringSetting("TRACE_FILE", "arquivo dos traces com TRACE_EXPORTER=file", func(c *Config) *string { return &c.Tracing.File }),
+	stringSetting("TRACE_SERVICE_NAME", "nome do serviço nos traces", func(c *Config) *string { return &c.Tracing.ServiceName }),
+	floatSetting("TRACE_SAMPLE_RATIO", "fração das requisições rastreadas (0 a 1)", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+
+	secret(stringSetting("DATABASE_URL", "DSN do PostgreSQL", func(c *Config) *string { return &c.Database.URL })),
+	int32Setting("DB_MAX_CONNS", "máximo de conexões no pool", func(c *Config) *int32 { return &c.Database.MaxConns }),
+	int32Setting("DB_MIN_CONNS", "conexões mantidas abertas mesmo ociosas", func(c *Config) *int32 { return &c.Database.MinConns }),
+	durationSetting("DB_MAX_CONN_IDLE_TIME", "tempo máximo que uma conexão pode ficar ociosa", func(c *Config) *time.Duration { return &c.Database.MaxConnIdleTime }),
+	durationSetting("DB_CONNECT_TIMEOUT", "tempo máximo para abrir uma conexão", func(c *Config) *time.Duration { return &c.Database.ConnectTimeout }),
+	boolSetting("AUTO_MIGRATE", "aplica as migrações pendentes ao subir a API", func(c *Config) *bool { return &c.Database.AutoMigrate }),
+
+	secret(bytesSetting("SECRET_KEY", "chave dos links assinados e dos hashes de identidade", func(c *Config) *[]byte { return &c.Auth.SecretKey })),
+	secret(bytesSetting("JWT_SECRET", "chave que assina os tokens (padrão: SECRET_KEY)", func(c *Config) *[]byte { return &c.Auth.JWTSecret })),
+	secret(setting{
+		name:  "JWT_PREVIOUS_SECRETS",
+		usage: "chaves anteriores aceitas na validação dos tokens, separadas por vírgula",
+		set: func(c *Config, value string) error {
+			c.Auth.JWTPreviousSecrets = nil
+			for _, key := range splitList(value) {
+				c.Auth.JWTPreviousSecrets = append(c.Auth.JWTPreviousSecrets, []byte(key))
+			}
+			return nil
+		},
+		get: func(c *Config) string { return string(bytes.Join(c.Auth.JWTPreviousSecrets, []byte(","))) },
+	}),
+	secret(stringSetting("INTERNAL_API_TOKEN", "token dos serviços internos (header X-Internal-Token)", func(c *Config) *string { return &c.Auth.InternalAPIToken })),
+	listSetting("ADMIN_USER_IDS", "usuários promovidos a administradores da plataforma ao subir a API, separados por vírgula", func(c *Config) *[]string { return &c.Auth.AdminUserIDs }),
+
+	secret(stringSetting("STRIPE_SECRET_KEY", "chave secreta da API do Stripe", func(c *Config) *string { return &c.Stripe.SecretKey })),
+	secret(stringSetting("STRIPE_WEBHOOK_SECRET", "segredo de assinatura dos webhooks do Stripe", func(c *Config) *string { return &c.Stripe.WebhookSecret })),
+	urlSetting("STRIPE_API_URL", "URL alternativa da API do Stripe (ex: stripe-mock)", func(c *Config) *string { return &c.Stripe.APIURL }),
+	durationSetting("STRIPE_TIMEOUT", "tempo máximo das chamadas ao Stripe", func(c *Config) *time.Duration { return &c.Stripe.Timeout }),
+
+	urlSetting("ASAAS_API_URL", "URL da API do Asaas", func(c *Config) *string { return &c.Asaas.APIURL }),
+	secret(stringSetting("ASAAS_API_KEY", "chave da API do Asaas", func(c *Config) *string { return &c.Asaas.APIKey })),
+	secret(stringSetting("ASAAS_WEBHOOK_TOKEN", "token dos webhooks do Asaas", func(c *Config) *string { return &c.Asaas.WebhookToken })),
+	durationSetting("ASAAS_TIMEOUT", "tempo máximo das chamadas ao Asaas", func(c *Config) *time.Duration { return &c.Asaas.Timeout }),
+
+	intSetting("BILLING_GRACE_DAYS", "dias de carência com pagamento atrasado", func(c *Config) *int { return &c.Billing.GraceDays }),
+	setting{
+		name:  "DUNNING_REMINDER_DAYS",
+		usage: "dias após a entrada em inadimplência em que o lembrete é enviado, separados por vírgula",
+		set: func(c *Config, value string) error {
+			var days []int
+			for _, day := range splitList(value) {
+				parsed, err := strconv.Atoi(day)
+				if err != nil {
+					return err
+				}
+				days = append(days, parsed)
+			}
+			slices.Sort(days)
+			c.Billing.DunningReminderDays = days
+			return nil
+		},
+		get: func(c *Config) string {
+			days := make([]string, len(c.Billing.DunningReminderDays))
+			for i, day := range c.Billing.DunningReminderDays {
+				days[i] = strconv.Itoa(day)
+			}
+			return strings.Join(days, ",")
+		},
+	},
+	stringSetting("DUNNING_ACTION", "fim da carência: lockout ou downgrade", func(c *Config) *string { return &c.Billing.DunningAction }),
+	intSetting("TRIAL_DAYS", "dias de teste gratuito das novas equipes", func(c *Config) *int { return &c.Billing.TrialDays }),
+	stringSetting("TRIAL_PRICE_ID", "preço do teste gratuito (vazio desativa)", func(c *Config) *string { return &c.Billing.TrialPriceID }),
+	intSetting("TRIAL_REMINDER_DAYS", "dias antes do fim do teste em que o lembrete é enviado", func(c *Config) *int { return &c.Billing.TrialReminderDays }),
+	durationSetting("LIFECYCLE_INTERVAL", "intervalo do job de teste e carência (0 desativa)", func(c *Config) *time.Duration { return &c.Billing.LifecycleInterval }),
+	durationSetting("ENTITLEMENT_CACHE_TTL", "cache dos recursos liberados por plano (0 desativa)", func(c *Config) *time.Duration { return &c.Billing.EntitlementCacheTTL }),
+	durationSetting("USAGE_REPORT_INTERVAL", "intervalo do envio do consumo ao Stripe (0 desativa)", func(c *Config) *time.Duration { return &c.Billing.UsageReportInterval }),
+	durationSetting("RECONCILE_INTERVAL", "intervalo da conciliação com o Stripe (0 desativa)", func(c *Config) *time.Duration { return &c.Billing.ReconcileInterval }),
+	boolSetting("RECONCILE_FIX", "a conciliação automática corrige as divergências", func(c *Config) *bool { return &c.Billing.ReconcileFix }),
+
+	stringSetting("EXPORT_DIR", "pasta dos arquivos de exportação de dados", func(c *Config) *string { return &c.Privacy.ExportDir }),
+	durationSetting("EXPORT_TTL", "validade do link de download da exportação", func(c *Config) *time.Duration { return &c.Privacy.ExportTTL }),
+	durationSetting("DELETION_COOLING_OFF", "prazo para desistir da exclusão da conta", func(c *Config) *time.Duration { return &c.Privacy.DeletionCoolingOff }),
+	stringSetting("DELETION_TEAM_POLICY", "destino padrão das equipes do usuário excluído: transfer ou dissolve", func(c *Config) *string { return &c.Privacy.DeletionTeamPolicy }),
+	// Mostra só os ids das chaves
+	setting{
+		name:  "PII_MASTER_KEYS",
+		usage: "chaves mestras da criptografia de dados pessoais (id:base64, separadas por vírgula)",
+		set: func(c *Config, value string) error {
+			keys := map[string][]byte{}
+			for _, entry := range splitList(value) {
+				id, encoded, found := strings.Cut(entry, ":")
+				if !found || id == "" {
+					return fmt.Errorf("entries must be id:base64key, got %q", entry)
+				}
+
+				key, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					return fmt.Errorf("key %q: %w", id, err)
+				}
+				keys[id] = key
+			}
+			c.Privacy.PIIMasterKeys = keys
+			return nil
+		},
+		get: func(c *Config) string {
+			ids := make([]string, 0, len(c.Privacy.PIIMasterKeys))
+			for id := range c.Privacy.PIIMasterKeys {
+				ids = append(ids, id+":"+redacted)
+			}
+			slices.Sort(ids)
+			return strings.Join(ids, ",")
+		},
+	},
+	stringSetting("PII_ACTIVE_KEY", "chave mestra usada nas novas gravações", func(c *Config) *string { return &c.Privacy.PIIActiveKey }),
+	secret(setting{
+		name:  "PII_INDEX_KEY",
+		usage: "chave (base64) do índice cego das buscas por CPF/CNPJ",
+		set: func(c *Config, value string) error {
+			key, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return err
+			}
+			c.Privacy.PIIIndexKey = key
+			return nil
+		},
+		get: func(c *Config) string { return base64.StdEncoding.EncodeToString(c.Privacy.PIIIndexKey) },
+	}),
+	durationSetting("PII_ROTATE_INTERVAL", "intervalo do job de rotação das chaves (0 desativa)", func(c *Config) *time.Duration { return &c.Privacy.PIIRotateInterval }),
+	listSetting("PII_READER_USER_IDS", "usuários que veem o CPF/CNPJ de terceiros sem máscara", func(c *Config) *[]string { return &c.Privacy.PIIReaderUserIDs }),
+}
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+func stringSetting(name, usage string, field func(c *Config) *string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+		get: func(c *Config) string { return *field(c) },
+	}
+}
+
+// URL sem a barra final, para os links montados a partir dela
+func urlSetting(name, usage string, field func(c *Config) *string) setting {
+	s := stringSetting(name, usage, field)
+	s.set = func(c *Config, value string) error {
+		*field(c) = strings.TrimRight(value, "/")
+		return nil
+	}
+	return s
+}
+
+func bytesSetting(name, usage string, field func(c *Config) *[]byte) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			*field(c) = []byte(value)
+			return nil
+		},
+		get: func(c *Config) string { return string(*field(c)) },
+	}
+}
+
+func listSetting(name, usage string, field func(c *Config) *[]string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			*field(c) = splitList(value)
+			return nil
+		},
+		get: func(c *Config) string { return strings.Join(*field(c), ",") },
+	}
+}
+
+func intSetting(name, usage string, field func(c *Config) *int) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = parsed
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+func int32Setting(name, usage string, field func(c *Config) *int32) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return err
+			}
+			*field(c) = int32(parsed)
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatInt(int64(*field(c)), 10) },
+	}
+}
+
+func floatSetting(name, usage string, field func(c *Config) *float64) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			*field(c) = parsed
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatFloat(*field(c), 'g', -1, 64) },
+	}
+}
+
+func boolSetting(name, usage string, field func(c *Config) *bool) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = parsed
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(*field(c)) },
+	}
+}
+
+func durationSetting(name, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = parsed
+			return nil
+		},
+		get: func(c *Config) string { return field(c).String() },
+	}
+}
+
+// Aceita um CIDR ou um IP isolado, tratado como /32 (ou /128)
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
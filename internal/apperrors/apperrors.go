@@ -0,0 +1,135 @@
+package apperrors
+
+import "errors"
+
+// Categoria do erro. Define o status HTTP da resposta (responses.Problem)
+type Kind int
+
+const (
+	// Falha inesperada (banco fora do ar, bug). O detalhe não vai para o cliente
+	KindInternal Kind = iota
+	// Requisição malformada: JSON inválido, id não numérico
+	KindInvalid
+	// Dados que não passam nas regras de negócio, com a mensagem de cada campo
+	KindValidation
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	// Conflito com o estado atual: registro duplicado, pedido já decidido
+	KindConflict
+	// O plano contratado não comporta a operação
+	KindPaymentRequired
+)
+
+// Erro da aplicação. Code é estável e pode ser usado pelos clientes; Message é o texto exibido
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  map[string]string
+	// Causa original, para logs e errors.Is/As
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Dois erros são iguais quando têm a mesma categoria e código, mesmo que um deles carregue uma causa (Wrap)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Code == e.Code
+}
+
+// Cópia do erro com a causa original anexada
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.Err = cause
+	return &wrapped
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Invalid(code, message string) *Error {
+	return New(KindInvalid, code, message)
+}
+
+func Unauthenticated(code, message string) *Error {
+	return New(KindUnauthenticated, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func PaymentRequired(code, message string) *Error {
+	return New(KindPaymentRequired, code, message)
+}
+
+// Erro de validação de um único campo (ex: Field("price_id", "is required"))
+func Field(field, message string) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "validation_failed",
+		Message: field + " " + message,
+		Fields:  map[string]string{field: message},
+	}
+}
+
+// Corpo ou parâmetro da requisição que não pôde ser lido
+func BadRequest(err error) *Error {
+	return Invalid("invalid_request", err.Error()).Wrap(err)
+}
+
+var (
+	// Token ausente, inválido ou sem o usuário
+	ErrUnauthenticated = Unauthenticated("unauthenticated", "authentication required")
+	ErrInternal        = New(KindInternal, "internal_error", "internal server error")
+)
+
+// Converte qualquer erro para *Error. Um *Error embrulhado com contexto (fmt.Errorf("...: %w", err)) mantém
+// categoria e código e usa a mensagem completa. Erros de validação por campo (validators.FieldErrors) viram
+// KindValidation; os demais, sem tipo, viram KindInternal com a causa preservada
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if error(appErr) == err {
+			return appErr
+		}
+		wrapped := appErr.Wrap(err)
+		wrapped.Message = err.Error()
+		return wrapped
+	}
+
+	var fieldErrors interface{ Fields() map[string]string }
+	if errors.As(err, &fieldErrors) {
+		return &Error{
+			Kind:    KindValidation,
+			Code:    "validation_failed",
+			Message: err.Error(),
+			Fields:  fieldErrors.Fields(),
+			Err:     err,
+		}
+	}
+
+	return ErrInternal.Wrap(err)
+}
+
+// Categoria do erro, KindInternal para erros sem tipo
+func KindOf(err error) Kind {
+	return From(err).Kind
+}
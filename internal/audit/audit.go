@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"HareID/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Dados da requisição HTTP que originou a ação
+type Request struct {
+	ActorID   *uint64
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type contextKey struct{}
+
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, request)
+}
+
+// Requisição atual. Vazia fora de uma requisição HTTP (jobs, webhooks processados em segundo plano)
+func FromContext(ctx context.Context) Request {
+	request, _ := ctx.Value(contextKey{}).(Request)
+	return request
+}
+
+// Registra o usuário autenticado na requisição
+func WithActor(ctx context.Context, actorID uint64) context.Context {
+	request := FromContext(ctx)
+	request.ActorID = &actorID
+	return WithRequest(ctx, request)
+}
+
+func NewRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Mantém só os campos que mudaram entre os dois estados. Com before nil, after vai inteiro
+func Diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if before == nil {
+		return nil, marshal(afterFields), nil
+	}
+
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, value := range beforeFields {
+		if next, ok := afterFields[key]; ok && string(next) == string(value) {
+			delete(beforeFields, key)
+			delete(afterFields, key)
+		}
+	}
+
+	return marshal(beforeFields), marshal(afterFields), nil
+}
+
+// Estado completo para ações sem estado anterior (criações, pedidos)
+func Snapshot(value any) json.RawMessage {
+	_, after, _ := Diff(nil, value)
+	return after
+}
+
+// Hash da entrada encadeado com o da anterior. Os campos entram em ordem fixa e a data em UTC com microssegundos,
+// que é a precisão guardada pelo banco
+func Hash(entry models.AuditLog) string {
+	payload, _ := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		ActorID    *uint64         `json:"actor_id"`
+		Action     string          `json:"action"`
+		TargetType string          `json:"target_type"`
+		TargetID   string          `json:"target_id"`
+		TeamID     *uint64         `json:"team_id"`
+		UserID     *uint64         `json:"user_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		IP         string          `json:"ip"`
+		RequestID  string          `json:"request_id"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		PrevHash:   entry.PrevHash,
+		ActorID:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		TeamID:     entry.TeamID,
+		UserID:     entry.UserID,
+		Before:     entry.Before,
+		After:      entry.After,
+		IP:         entry.IP,
+		RequestID:  entry.RequestID,
+		CreatedAt:  entry.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func fields(value any) (map[string]json.RawMessage, error) {
+	if value == nil {
+		return map[string]json.RawMessage{}, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+func marshal(fields map[string]json.RawMessage) json.RawMessage {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	encoded, _ := json.Marshal(fields)
+	return encoded
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"HareID/internal/models"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHash(t *testing.T) {
+	actorID := uint64(7)
+	teamID := uint64(42)
+	createdAt := time.Date(2026, 5, 20, 13, 15, 32, 123456789, time.UTC)
+
+	base := models.AuditLog{
+		ActorID:    &actorID,
+		Action:     models.AUDIT_TEAM_UPDATED,
+		TargetType: models.AUDIT_TARGET_TEAM,
+		TargetID:   "42",
+		TeamID:     &teamID,
+		Before:     json.RawMessage(`{"name":"Old"}`),
+		After:      json.RawMessage(`{"name":"New"}`),
+		IP:         "203.0.113.9",
+		RequestID:  "req-1",
+		CreatedAt:  createdAt,
+		PrevHash:   "abc",
+	}
+	baseHash := Hash(base)
+
+	otherActor := uint64(8)
+
+	tests := []struct {
+		name   string
+		change func(*models.AuditLog)
+		equal  bool
+	}{
+		{"same content", func(*models.AuditLog) {}, true},
+		{"database fields are ignored", func(e *models.AuditLog) { e.ID = 99; e.Hash = "stored" }, true},
+		{"same instant in another zone", func(e *models.AuditLog) { e.CreatedAt = createdAt.In(time.FixedZone("BRT", -3*60*60)) }, true},
+		{"nanoseconds beyond the database precision", func(e *models.AuditLog) { e.CreatedAt = createdAt.Truncate(time.Microsecond) }, true},
+		{"previous hash", func(e *models.AuditLog) { e.PrevHash = "abd" }, false},
+		{"actor", func(e *models.AuditLog) { e.ActorID = &otherActor }, false},
+		{"action", func(e *models.AuditLog) { e.Action = models.AUDIT_TEAM_DELETED }, false},
+		{"target", func(e *models.AuditLog) { e.TargetID = "43" }, false},
+		{"before", func(e *models.AuditLog) { e.Before = json.RawMessage(`{"name":"Other"}`) }, false},
+		{"after", func(e *models.AuditLog) { e.After = nil }, false},
+		{"ip", func(e *models.AuditLog) { e.IP = "" }, false},
+		{"request id", func(e *models.AuditLog) { e.RequestID = "req-2" }, false},
+		{"created at", func(e *models.AuditLog) { e.CreatedAt = createdAt.Add(time.Microsecond) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := base
+			tt.change(&entry)
+
+			if got := Hash(entry) == baseHash; got != tt.equal {
+				t.Errorf("hash equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     any
+		after      any
+		wantBefore string
+		wantAfter  string
+	}{
+		{"creation", nil, map[string]any{"name": "HareID"}, "", `{"name":"HareID"}`},
+		{"only changed fields", map[string]any{"name": "Old", "domain": "hare.id"}, map[string]any{"name": "New", "domain": "hare.id"}, `{"name":"Old"}`, `{"name":"New"}`},
+		{"nothing changed", map[string]any{"name": "Same"}, map[string]any{"name": "Same"}, "", ""},
+		{"removed field", map[string]any{"name": "Old", "domain": "hare.id"}, map[string]any{"name": "Old"}, `{"domain":"hare.id"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(before) != tt.wantBefore || string(after) != tt.wantAfter {
+				t.Errorf("Diff = %s / %s, want %s / %s", before, after, tt.wantBefore, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	ctx := WithRequest(context.Background(), Request{IP: "203.0.113.9", RequestID: "req-1"})
+	ctx = WithActor(ctx, 7)
+
+	request := FromContext(ctx)
+	if request.ActorID == nil || *request.ActorID != 7 || request.IP != "203.0.113.9" || request.RequestID != "req-1" {
+		t.Errorf("request = %+v", request)
+	}
+
+	if empty := FromContext(context.Background()); empty.ActorID != nil || empty.RequestID != "" {
+		t.Errorf("request outside HTTP = %+v, want empty", empty)
+	}
+}
@@ -11,17 +11,50 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	signingKey       []byte
+	verificationKeys []jwt.VerificationKey
+)
+
+// Registra as chaves dos tokens. Chamado no main com a configuração carregada
+func SetKeys(cfg config.Auth) {
+	signingKey = cfg.JWTSecret
+
+	verificationKeys = []jwt.VerificationKey{cfg.JWTSecret}
+	for _, previous := range cfg.JWTPreviousSecrets {
+		verificationKeys = append(verificationKeys, previous)
+	}
+}
+
 // Cria um token
 func CreateToken(Google_Subscription string, userID uint64) (string, error) {
 	permissions := jwt.MapClaims{}
 	permissions["authorized"] = true
 	permissions["exp"] = time.Now().Add(time.Hour * 6).Unix()
+	permissions["iat"] = time.Now().Unix()
 	permissions["User_ID"] = userID
 	permissions["Google_Subscription"] = Google_Subscription
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
 
-	return token.SignedString([]byte(config.SecretKey))
+	return token.SignedString(signingKey)
+}
+
+// Escopo do token emitido enquanto o usuário precisa aceitar novos termos
+const ConsentScope = "consent"
+
+// Cria um token de curta duração que só permite registrar o consentimento
+func CreateConsentToken(userID uint64) (string, error) {
+	permissions := jwt.MapClaims{}
+	permissions["authorized"] = true
+	permissions["exp"] = time.Now().Add(time.Minute * 15).Unix()
+	permissions["iat"] = time.Now().Unix()
+	permissions["User_ID"] = userID
+	permissions["scope"] = ConsentScope
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
+
+	return token.SignedString(signingKey)
 }
 
 // Captura o token
@@ -51,13 +84,13 @@ func ValidateToken(r *http.Request) error {
 	return errors.New("Invalid token!")
 }
 
-// Cria a chave de validação com base na SecretKey da API
+// Chaves de validação: a atual e as anteriores ainda aceitas (JWT_PREVIOUS_SECRETS)
 func validationKey(token *jwt.Token) (interface{}, error) {
 	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 		return nil, fmt.Errorf("Wrong signing method! %v", token.Header["alg"])
 	}
 
-	return []byte(config.SecretKey), nil
+	return jwt.VerificationKeySet{Keys: verificationKeys}, nil
 }
 
 // Captura o ID do usuário inserido no Token da requisição
@@ -81,6 +114,44 @@ func GetTokenUserID(r *http.Request) (string, error) {
 	return "", errors.New("invalid token")
 }
 
+// Captura o momento de emissão do token. Tokens antigos, sem "iat", são tratados como emitidos 6h antes de expirar
+func GetTokenIssuedAt(r *http.Request) (time.Time, error) {
+	tokenString := GetToken(r)
+	token, err := jwt.Parse(tokenString, validationKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if issuedAt, err := token.Claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		return issuedAt.Time, nil
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return time.Time{}, errors.New("invalid token")
+	}
+
+	return expiresAt.Add(-6 * time.Hour), nil
+}
+
+// Captura o escopo do token. Tokens completos não têm escopo
+func GetTokenScope(r *http.Request) (string, error) {
+	tokenString := GetToken(r)
+	token, err := jwt.Parse(tokenString, validationKey)
+	if err != nil {
+		return "", err
+	}
+
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if scope, ok := permissions["scope"]; ok {
+			return fmt.Sprint(scope), nil
+		}
+		return "", nil
+	}
+
+	return "", errors.New("invalid token")
+}
+
 func GetTokenGoogle_Subscription(r *http.Request) (string, error) {
 	tokenString := GetToken(r)
 	token, err := jwt.Parse(tokenString, validationKey)
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/middleware"
+	"HareID/internal/models"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditController struct {
+	services services.Services
+}
+
+// GetTeamLog lists the team's audit trail
+// @Summary      Get team audit log
+// @Description  List who did what in the team (team and membership changes, join request decisions, billing), newest first. Restricted to the team owner and administrators, and requires the team's own plan to include the audit_logs feature
+// @Tags         audit
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        team_id    path      int     true   "Team ID"
+// @Param        action     query     string  false  "Action or action prefix (e.g. team. or join_request.accepted)"
+// @Param        actor_id   query     int     false  "Only actions by this user"
+// @Param        from       query     string  false  "Start time (RFC 3339)"
+// @Param        to         query     string  false  "End time (RFC 3339)"
+// @Param        before_id  query     int     false  "Only entries older than this id"
+// @Param        limit      query     int     false  "Max entries (default 100, max 500)"
+// @Success      200        {array}   models.AuditLog
+// @Failure      400        {object}  responses.ProblemDetails
+// @Failure      401        {object}  responses.ProblemDetails
+// @Failure      402        {object}  responses.ProblemDetails
+// @Failure      403        {object}  responses.ProblemDetails
+// @Failure      500        {object}  responses.ProblemDetails
+// @Router       /teams/{team_id}/audit-log [get]
+func (c *AuditController) GetTeamLog(w http.ResponseWriter, r *http.Request) {
+	userIDToken, _ := r.Context().Value(middleware.UserKey).(string)
+
+	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	entries, err := c.services.Audit.GetByTeamID(r.Context(), requestUserID, teamID, filter)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, entries)
+}
+
+// GetUserLog lists the caller's audit trail
+// @Summary      Get user audit log
+// @Description  List the actions done by the caller or affecting the caller (logins, tokens, profile, consents, memberships), newest first
+// @Tags         audit
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id    path      int     true   "User ID"
+// @Param        action     query     string  false  "Action or action prefix (e.g. auth.)"
+// @Param        actor_id   query     int     false  "Only actions by this user"
+// @Param        from       query     string  false  "Start time (RFC 3339)"
+// @Param        to         query     string  false  "End time (RFC 3339)"
+// @Param        before_id  query     int     false  "Only entries older than this id"
+// @Param        limit      query     int     false  "Max entries (default 100, max 500)"
+// @Success      200        {array}   models.AuditLog
+// @Failure      400        {object}  responses.ProblemDetails
+// @Failure      401        {object}  responses.ProblemDetails
+// @Failure      403        {object}  responses.ProblemDetails
+// @Failure      500        {object}  responses.ProblemDetails
+// @Router       /users/{user_id}/audit-log [get]
+func (c *AuditController) GetUserLog(w http.ResponseWriter, r *http.Request) {
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	entries, err := c.services.Audit.GetByUserID(r.Context(), requestUserID, userID, filter)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, entries)
+}
+
+// Verify checks the audit hash chain
+// @Summary      Verify audit log
+// @Description  Recompute the hash chain of the whole audit log and report the first entry that was changed, removed or reordered. Admin only
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.AuditVerification
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      403  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /admin/audit-log/verify [get]
+func (c *AuditController) Verify(w http.ResponseWriter, r *http.Request) {
+	result, err := c.services.Audit.Verify(r.Context())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, result)
+}
+
+func auditFilter(r *http.Request) (models.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{Action: query.Get("action")}
+
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return models.AuditFilter{}, err
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return models.AuditFilter{}, err
+			}
+			*target = &parsed
+		}
+	}
+
+	if value := query.Get("before_id"); value != "" {
+		beforeID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return models.AuditFilter{}, err
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return models.AuditFilter{}, err
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/authentication"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type BillingController struct {
+	services services.Services
+}
+
+type CreatePortalSessionRequest struct {
+	ReturnURL string `json:"return_url"`
+}
+
+// CreatePortalSession opens the Stripe customer portal
+// @Summary      Create billing portal session
+// @Description  Create a Stripe billing portal session where the caller can update payment methods, download invoices and cancel subscriptions
+// @Tags         billing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      CreatePortalSessionRequest  true  "Portal Request Data"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      409      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /billing/portal-session [post]
+func (c *BillingController) CreatePortalSession(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	var req CreatePortalSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	if req.ReturnURL == "" {
+		responses.Problem(w, r, apperrors.Field("return_url", "is required"))
+		return
+	}
+
+	portalURL, err := c.services.Billing.CreatePortalSession(r.Context(), userID, req.ReturnURL)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, map[string]string{"url": portalURL})
+}
+
+// GetInvoices lists the caller's invoices
+// @Summary      List invoices
+// @Description  Retrieve the invoices of the authenticated user, newest first
+// @Tags         billing
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.Invoice
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /billing/invoices [get]
+func (c *BillingController) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	invoices, err := c.services.Billing.GetInvoices(r.Context(), userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, invoices)
+}
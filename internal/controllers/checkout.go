@@ -1,11 +1,11 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
 	"HareID/internal/authentication"
 	"HareID/internal/responses"
 	"HareID/internal/services"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 )
@@ -29,37 +29,80 @@ type CreateCheckoutRequest struct {
 // @Security     BearerAuth
 // @Param        request  body      CreateCheckoutRequest  true  "Checkout Request Data"
 // @Success      200      {object}  map[string]string
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /checkout-session [post]
 func (c *CheckoutController) CreateSession(w http.ResponseWriter, r *http.Request) {
 	requestUserID, err := authentication.GetTokenUserID(r)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	userID, err := strconv.ParseUint(requestUserID, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	var req CreateCheckoutRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	if req.PriceID == "" || req.SuccessURL == "" || req.CancelURL == "" {
-		responses.Error(w, http.StatusBadRequest, errors.New("price_id, success_url and cancel_url are required"))
+	checkoutURL, err := c.services.Checkout.CreateCheckoutSession(r.Context(), userID, req.PriceID, req.SuccessURL, req.CancelURL)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
-	checkoutURL, err := c.services.Checkout.CreateCheckoutSession(r.Context(), userID, req.PriceID, req.SuccessURL, req.CancelURL)
+	responses.JSON(w, http.StatusOK, map[string]string{"url": checkoutURL})
+}
+
+// CreateTeamSession initiates a per-seat checkout session for a team
+// @Summary      Create team checkout session
+// @Description  Create a Stripe checkout session billing the team, with quantity equal to its current seat count
+// @Tags         checkout
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        team_id  path      int                    true  "Team ID"
+// @Param        request  body      CreateCheckoutRequest  true  "Checkout Request Data"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /teams/{team_id}/checkout-session [post]
+func (c *CheckoutController) CreateTeamSession(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	var req CreateCheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	checkoutURL, err := c.services.Checkout.CreateTeamCheckoutSession(r.Context(), userID, teamID, req.PriceID, req.SuccessURL, req.CancelURL)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
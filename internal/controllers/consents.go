@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/middleware"
+	"HareID/internal/models"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type ConsentsController struct {
+	services services.Services
+}
+
+type RecordConsentsRequest struct {
+	Consents []models.UserConsent `json:"consents"`
+}
+
+// GetCurrentTerms lists the terms versions in force
+// @Summary      Get current terms
+// @Description  List the latest published version of each consent purpose (0 terms of use, 1 marketing, 2 data processing)
+// @Tags         consents
+// @Produce      json
+// @Success      200  {array}   models.TermsVersion
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /terms/current [get]
+func (c *ConsentsController) GetCurrentTerms(w http.ResponseWriter, r *http.Request) {
+	versions, err := c.services.Consents.GetCurrentTerms(r.Context())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, versions)
+}
+
+// PublishTerms publishes a new terms version
+// @Summary      Publish terms version
+// @Description  Publish a new version for a consent purpose. Users who accepted an older required version must consent again on their next login
+// @Tags         consents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.TermsVersion  true  "Terms version"
+// @Success      201      {object}  models.TermsVersion
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Router       /admin/terms [post]
+func (c *ConsentsController) PublishTerms(w http.ResponseWriter, r *http.Request) {
+	var version models.TermsVersion
+	if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	created, err := c.services.Consents.PublishTerms(r.Context(), version)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusCreated, created)
+}
+
+// GetMine shows the caller's consents
+// @Summary      Get my consents
+// @Description  Show the current state of each consent purpose, the full consent history (with timestamp, IP and user agent) and the required terms still pending
+// @Tags         consents
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.ConsentOverview
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me/consents [get]
+func (c *ConsentsController) GetMine(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	overview, err := c.services.Consents.GetByUserID(r.Context(), userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, overview)
+}
+
+// Record grants or withdraws consents
+// @Summary      Record consents
+// @Description  Grant (granted=true, with the current terms_version_id) or withdraw (granted=false) consent per purpose. Accepts the consent_token returned by the login; once nothing required is pending the response carries a full token. Withdrawing a required purpose revokes every session
+// @Tags         consents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      RecordConsentsRequest  true  "Consents"
+// @Success      201      {object}  models.ConsentResult
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Router       /me/consents [post]
+func (c *ConsentsController) Record(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	var req RecordConsentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	result, err := c.services.Consents.Record(r.Context(), userID, req.Consents, consentSource(r))
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusCreated, result)
+}
+
+// Origem da requisição, registrada pelo middleware RequestMetadata
+func consentSource(r *http.Request) models.ConsentSource {
+	request := audit.FromContext(r.Context())
+	return models.ConsentSource{IP: request.IP, UserAgent: request.UserAgent}
+}
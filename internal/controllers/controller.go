@@ -13,9 +13,13 @@ type Controller struct {
 		Create(http.ResponseWriter, *http.Request)
 		GetAll(http.ResponseWriter, *http.Request)
 		GetByID(http.ResponseWriter, *http.Request)
+		GetByCpfCnpj(http.ResponseWriter, *http.Request)
+		SetAdmin(http.ResponseWriter, *http.Request)
 		GetUserTeam(http.ResponseWriter, *http.Request)
 		Update(http.ResponseWriter, *http.Request)
 		Delete(http.ResponseWriter, *http.Request)
+		GetDeletion(http.ResponseWriter, *http.Request)
+		CancelDeletion(http.ResponseWriter, *http.Request)
 	}
 	Subscriptions interface {
 		Create(http.ResponseWriter, *http.Request)
@@ -23,6 +27,8 @@ type Controller struct {
 		GetBySubscriptionID(http.ResponseWriter, *http.Request)
 		Update(http.ResponseWriter, *http.Request)
 		Delete(http.ResponseWriter, *http.Request)
+		Cancel(http.ResponseWriter, *http.Request)
+		ChangePlan(http.ResponseWriter, *http.Request)
 	}
 	Teams interface {
 		Create(http.ResponseWriter, *http.Request)
@@ -34,9 +40,6 @@ type Controller struct {
 		Delete(http.ResponseWriter, *http.Request)
 	}
 	TeamMembers interface {
-		GetAll(http.ResponseWriter, *http.Request)
-		GetByID(http.ResponseWriter, *http.Request)
-		GetByName(http.ResponseWriter, *http.Request)
 		Delete(http.ResponseWriter, *http.Request)
 	}
 	JoinRequests interface {
@@ -54,9 +57,51 @@ type Controller struct {
 	}
 	Webhook interface {
 		HandleWebhook(http.ResponseWriter, *http.Request)
+		HandleAsaasWebhook(http.ResponseWriter, *http.Request)
+		ListEvents(http.ResponseWriter, *http.Request)
+		ReplayEvent(http.ResponseWriter, *http.Request)
 	}
 	Checkout interface {
 		CreateSession(http.ResponseWriter, *http.Request)
+		CreateTeamSession(http.ResponseWriter, *http.Request)
+	}
+	Billing interface {
+		CreatePortalSession(http.ResponseWriter, *http.Request)
+		GetInvoices(http.ResponseWriter, *http.Request)
+	}
+	Plans interface {
+		GetAll(http.ResponseWriter, *http.Request)
+	}
+	Usage interface {
+		Record(http.ResponseWriter, *http.Request)
+		GetTeamUsage(http.ResponseWriter, *http.Request)
+	}
+	Exports interface {
+		Request(http.ResponseWriter, *http.Request)
+		GetByID(http.ResponseWriter, *http.Request)
+		Download(http.ResponseWriter, *http.Request)
+	}
+	Consents interface {
+		GetCurrentTerms(http.ResponseWriter, *http.Request)
+		PublishTerms(http.ResponseWriter, *http.Request)
+		GetMine(http.ResponseWriter, *http.Request)
+		Record(http.ResponseWriter, *http.Request)
+	}
+	Audit interface {
+		GetTeamLog(http.ResponseWriter, *http.Request)
+		GetUserLog(http.ResponseWriter, *http.Request)
+		Verify(http.ResponseWriter, *http.Request)
+	}
+	Health interface {
+		Liveness(http.ResponseWriter, *http.Request)
+		Readiness(http.ResponseWriter, *http.Request)
+	}
+	Me interface {
+		Get(http.ResponseWriter, *http.Request)
+		GetSubscriptions(http.ResponseWriter, *http.Request)
+		GetTeams(http.ResponseWriter, *http.Request)
+		GetEntitlements(http.ResponseWriter, *http.Request)
+		GetBillingHistory(http.ResponseWriter, *http.Request)
 	}
 }
 
@@ -66,9 +111,18 @@ func NewControllers(s services.Services) Controller {
 		Subscriptions: &SubscriptionsController{services: s},
 		Users:         &UsersController{services: s},
 		Teams:         &TeamsController{services: s},
+		TeamMembers:   &TeamMembersController{services: s},
 		JoinRequests:  &JoinRequestsController{services: s},
 		Notifications: &NotificationsController{services: s},
 		Webhook:       &WebhookController{services: s},
 		Checkout:      &CheckoutController{services: s},
+		Billing:       &BillingController{services: s},
+		Plans:         &PlansController{services: s},
+		Usage:         &UsageController{services: s},
+		Exports:       &ExportsController{services: s},
+		Consents:      &ConsentsController{services: s},
+		Audit:         &AuditController{services: s},
+		Health:        &HealthController{services: s},
+		Me:            &MeController{services: s},
 	}
 }
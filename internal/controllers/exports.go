@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/middleware"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type ExportsController struct {
+	services services.Services
+}
+
+type RequestExportRequest struct {
+	// Inclui uma cópia em CSV de cada seção além do JSON
+	IncludeCSV bool `json:"include_csv"`
+}
+
+// Request queues an LGPD data portability export
+// @Summary      Request data export
+// @Description  Queue an asynchronous export of every record tied to the user (profile, consent, teams, join requests, notifications, subscriptions, invoices and billing history) as a zip of JSON files, optionally with CSV copies. The user is notified when the signed download link is ready. Only the user themselves can request it
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id  path      int                   true   "User ID"
+// @Param        request  body      RequestExportRequest  false  "Export options"
+// @Success      202      {object}  models.DataExport
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /users/{user_id}/export [post]
+func (c *ExportsController) Request(w http.ResponseWriter, r *http.Request) {
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req RequestExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	export, err := c.services.Exports.Request(r.Context(), requestUserID, userID, req.IncludeCSV)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusAccepted, export)
+}
+
+// GetByID shows the status of a data export
+// @Summary      Get data export
+// @Description  Retrieve the status of a data export. When it is ready the response carries a signed download link valid until the file expires
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id    path      int  true  "User ID"
+// @Param        export_id  path      int  true  "Export ID"
+// @Success      200        {object}  models.DataExport
+// @Failure      400        {object}  responses.ProblemDetails
+// @Failure      401        {object}  responses.ProblemDetails
+// @Failure      403        {object}  responses.ProblemDetails
+// @Failure      404        {object}  responses.ProblemDetails
+// @Router       /users/{user_id}/exports/{export_id} [get]
+func (c *ExportsController) GetByID(w http.ResponseWriter, r *http.Request) {
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	exportID, err := strconv.ParseUint(r.PathValue("export_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	export, err := c.services.Exports.GetByID(r.Context(), requestUserID, userID, exportID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, export)
+}
+
+// Download serves the export archive through a signed link
+// @Summary      Download data export
+// @Description  Download the export archive. The link is signed and expires with the file, so no bearer token is needed
+// @Tags         users
+// @Produce      application/zip
+// @Param        export_id  path      int     true  "Export ID"
+// @Param        expires    query     int     true  "Link expiry (unix seconds)"
+// @Param        signature  query     string  true  "Link signature"
+// @Success      200        {file}    file
+// @Failure      400        {object}  responses.ProblemDetails
+// @Failure      403        {object}  responses.ProblemDetails
+// @Router       /exports/{export_id}/download [get]
+func (c *ExportsController) Download(w http.ResponseWriter, r *http.Request) {
+	exportID, err := strconv.ParseUint(r.PathValue("export_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	export, err := c.services.Exports.Open(r.Context(), exportID, expires, r.URL.Query().Get("signature"))
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="hareid-export-%d.zip"`, export.UserID))
+	w.Header().Set("Cache-Control", "no-store")
+
+	http.ServeFile(w, r, export.FilePath)
+}
+
+// Lê o usuário do token e o user_id da rota
+func pathUserIDs(w http.ResponseWriter, r *http.Request) (uint64, uint64, bool) {
+	userIDToken, _ := r.Context().Value(middleware.UserKey).(string)
+
+	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return 0, 0, false
+	}
+
+	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return 0, 0, false
+	}
+
+	return requestUserID, userID, true
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"net/http"
+)
+
+type HealthController struct {
+	services services.Services
+}
+
+// Liveness reports that the process is up
+// @Summary      Liveness probe
+// @Description  Always 200 while the process is serving requests. It does not touch the database, so a slow dependency never gets the instance restarted
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /healthz [get]
+func (c *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness reports whether the instance should receive traffic
+// @Summary      Readiness probe
+// @Description  Check the database connection, that the schema is at the migration version embedded in the binary and that Stripe is configured. Answers 503 as soon as a shutdown starts, before the server stops accepting connections
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  models.Readiness
+// @Failure      503  {object}  models.Readiness
+// @Router       /readyz [get]
+func (c *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	readiness := c.services.Health.Readiness(r.Context())
+
+	if !readiness.Ready {
+		responses.JSON(w, http.StatusServiceUnavailable, readiness)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, readiness)
+}
@@ -1,11 +1,11 @@
 package controllers
 
 import (
-	"HareID/internal/enums"
+	"HareID/internal/apperrors"
+	"HareID/internal/listquery"
 	"HareID/internal/middleware"
 	"HareID/internal/responses"
 	"HareID/internal/services"
-	"errors"
 	"net/http"
 	"strconv"
 )
@@ -23,32 +23,32 @@ type JoinRequestsController struct {
 // @Security     BearerAuth
 // @Param        team_id  path      int  true  "Team ID"
 // @Success      201      {object}  map[string]any
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join [post]
 func (c *JoinRequestsController) Create(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("User id not found in context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	newJoinRequest, newNotification, err := c.services.JoinRequests.Create(r.Context(), requestUserID, teamID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -69,33 +69,43 @@ func (c *JoinRequestsController) Create(w http.ResponseWriter, r *http.Request)
 // @Produce      json
 // @Security     BearerAuth
 // @Param        team_id  path      int  true  "Team ID"
-// @Success      200      {array}   models.JoinRequest
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200      {object}  listquery.Page[models.JoinRequest]
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join-requests [get]
 func (j *JoinRequestsController) GetAll(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	requests, err := j.services.JoinRequests.GetAll(r.Context(), requestUserID, teamID)
+	params, err := listquery.Parse(r.URL.Query())
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
+		return
+	}
+
+	requests, err := j.services.JoinRequests.GetAll(r.Context(), requestUserID, teamID, params)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -112,39 +122,39 @@ func (j *JoinRequestsController) GetAll(w http.ResponseWriter, r *http.Request)
 // @Param        team_id     path      int  true  "Team ID"
 // @Param        request_id  path      int  true  "Request ID"
 // @Success      200         {object}  models.JoinRequest
-// @Failure      400         {object}  map[string]string
-// @Failure      401         {object}  map[string]string
-// @Failure      500         {object}  map[string]string
+// @Failure      400         {object}  responses.ProblemDetails
+// @Failure      401         {object}  responses.ProblemDetails
+// @Failure      500         {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join-requests/{request_id} [get]
 func (j *JoinRequestsController) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	requestID, err := strconv.ParseUint(r.PathValue("request_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	request, err := j.services.JoinRequests.GetByID(r.Context(), requestUserID, teamID, requestID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -162,38 +172,38 @@ func (j *JoinRequestsController) GetByID(w http.ResponseWriter, r *http.Request)
 // @Param        team_id     path      int  true  "Team ID"
 // @Param        request_id  path      int  true  "Request ID"
 // @Success      200         {object}  map[string]uint64
-// @Failure      400         {object}  map[string]string
-// @Failure      401         {object}  map[string]string
-// @Failure      500         {object}  map[string]string
+// @Failure      400         {object}  responses.ProblemDetails
+// @Failure      401         {object}  responses.ProblemDetails
+// @Failure      500         {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join-requests/{request_id} [delete]
 func (j *JoinRequestsController) Delete(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	requestID, err := strconv.ParseUint(r.PathValue("request_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := j.services.JoinRequests.Delete(r.Context(), requestUserID, teamID, requestID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -214,55 +224,44 @@ func (j *JoinRequestsController) Delete(w http.ResponseWriter, r *http.Request)
 // @Param        team_id     path      int  true  "Team ID"
 // @Param        request_id  path      int  true  "Request ID"
 // @Success      200         {object}  map[string]interface{}
-// @Failure      400         {object}  map[string]string
-// @Failure      401         {object}  map[string]string
-// @Failure      500         {object}  map[string]string
+// @Failure      400         {object}  responses.ProblemDetails
+// @Failure      401         {object}  responses.ProblemDetails
+// @Failure      402         {object}  responses.ProblemDetails
+// @Failure      403         {object}  responses.ProblemDetails
+// @Failure      500         {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join-requests/{request_id}/accept [patch]
 func (j *JoinRequestsController) Accept(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	requestID, err := strconv.ParseUint(r.PathValue("request_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	affectedRows, err := j.services.JoinRequests.Accept(r.Context(), requestUserID, teamID, requestID)
+	affectedRows, createdTeamMember, err := j.services.JoinRequests.Accept(r.Context(), requestUserID, teamID, requestID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
-	joinRequestData, err := j.services.JoinRequests.GetByID(r.Context(), requestUserID, teamID, requestID)
-	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	createdTeamMember, err := j.services.TeamMembers.Create(
-		r.Context(),
-		enums.MARKETING_MEMBER,
-		teamID,
-		joinRequestData.SenderID,
-	)
-
 	data := map[string]interface{}{
 		"affectedRows": affectedRows,
 		"teamMember":   createdTeamMember,
@@ -281,38 +280,38 @@ func (j *JoinRequestsController) Accept(w http.ResponseWriter, r *http.Request)
 // @Param        team_id     path      int  true  "Team ID"
 // @Param        request_id  path      int  true  "Request ID"
 // @Success      200         {object}  map[string]uint64
-// @Failure      400         {object}  map[string]string
-// @Failure      401         {object}  map[string]string
-// @Failure      500         {object}  map[string]string
+// @Failure      400         {object}  responses.ProblemDetails
+// @Failure      401         {object}  responses.ProblemDetails
+// @Failure      500         {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/join-requests/{request_id}/reject [patch]
 func (j *JoinRequestsController) Reject(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	requestID, err := strconv.ParseUint(r.PathValue("request_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := j.services.JoinRequests.Reject(r.Context(), requestUserID, teamID, requestID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
 	"HareID/internal/models"
 	"HareID/internal/responses"
 	"HareID/internal/services"
@@ -14,32 +15,28 @@ type LoginController struct {
 
 // Login authenticates a user
 // @Summary      User Login
-// @Description  Authenticate user using Google Subject ID and return a JWT token
+// @Description  Authenticate user using Google Subject ID and return a JWT token. When a required terms version has not been accepted yet, consent_required is true and only a short-lived consent_token (valid for POST /me/consents) is returned
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        credentials  body      models.User  true  "User Credentials (only GoogleSub needed)"
-// @Success      200          {object}  map[string]string
-// @Failure      400          {object}  map[string]string
-// @Failure      401          {object}  map[string]string
+// @Success      200          {object}  models.LoginResult
+// @Failure      400          {object}  responses.ProblemDetails
+// @Failure      401          {object}  responses.ProblemDetails
 // @Router       /login [post]
 func (c *LoginController) Login(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	token, err := c.services.Login.Login(r.Context(), user.GoogleSub)
+	result, err := c.services.Login.Login(r.Context(), user.GoogleSub)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
-	data := map[string]string{
-		"token": token,
-	}
-
-	responses.JSON(w, http.StatusOK, data)
+	responses.JSON(w, http.StatusOK, result)
 }
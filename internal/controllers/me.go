@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/listquery"
+	"HareID/internal/middleware"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"net/http"
+	"strconv"
+)
+
+type MeController struct {
+	services services.Services
+}
+
+// Get returns the caller's own user
+// @Summary      Get my user
+// @Description  Return the caller's own record with cpf_cnpj unmasked and is_admin when the caller is a platform administrator
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.User
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me [get]
+func (c *MeController) Get(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	user, err := c.services.Users.GetByID(r.Context(), userID, userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, user)
+}
+
+// GetSubscriptions lists the subscriptions held by the caller
+// @Summary      Get my subscriptions
+// @Description  List the subscriptions the caller holds, including team subscriptions they pay for. Accepts the same sort and filters as GET /subscriptions
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200  {object}  listquery.Page[models.Subscription]
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      422  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me/subscriptions [get]
+func (c *MeController) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	params, err := listquery.Parse(r.URL.Query())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	subscriptions, err := c.services.Subscriptions.GetAllByUserID(r.Context(), userID, params)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, subscriptions)
+}
+
+// GetTeams lists the teams the caller belongs to
+// @Summary      Get my teams
+// @Description  List the teams the caller is a member of, including the ones they own. Accepts the same sort and filters as GET /teams
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200  {object}  listquery.Page[models.Team]
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      422  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me/teams [get]
+func (c *MeController) GetTeams(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	params, err := listquery.Parse(r.URL.Query())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	teams, err := c.services.Teams.GetAllByMemberID(r.Context(), userID, params)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, teams)
+}
+
+// GetEntitlements lists the plan features available to the caller
+// @Summary      Get my entitlements
+// @Description  Resolve the caller's own subscription and the subscriptions of every team they belong to into a plan and feature set, so the frontend can hide locked features
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.UserEntitlements
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me/entitlements [get]
+func (c *MeController) GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	entitlements, err := c.services.Entitlements.GetEffective(r.Context(), userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, entitlements)
+}
+
+// GetBillingHistory lists trial and dunning transitions of the caller's subscriptions
+// @Summary      Get my billing history
+// @Description  List trial start, trial reminders and expiry, payment reminders and grace period end for the caller's subscriptions, newest first
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   models.BillingHistory
+// @Failure      401  {object}  responses.ProblemDetails
+// @Failure      500  {object}  responses.ProblemDetails
+// @Router       /me/billing-history [get]
+func (c *MeController) GetBillingHistory(w http.ResponseWriter, r *http.Request) {
+	requestUserID, _ := r.Context().Value(middleware.UserKey).(string)
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	history, err := c.services.Billing.GetHistory(r.Context(), userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, history)
+}
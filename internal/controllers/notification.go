@@ -1,10 +1,11 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/listquery"
 	"HareID/internal/middleware"
 	"HareID/internal/responses"
 	"HareID/internal/services"
-	"errors"
 	"net/http"
 	"strconv"
 )
@@ -21,39 +22,49 @@ type NotificationsController struct {
 // @Produce      json
 // @Security     BearerAuth
 // @Param        user_id  path      int  true  "User ID"
-// @Success      200      {array}   models.Notification
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200      {object}  listquery.Page[models.Notification]
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /users/{user_id}/notifications [get]
 func (c *NotificationsController) GetAll(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("userkey not found in context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	if requestUserID != userID {
-		responses.Error(w, http.StatusUnauthorized, errors.New("only the notifications owner can se the notifications"))
+		responses.Problem(w, r, services.ErrNotDataOwner)
 		return
 	}
 
-	notifications, err := c.services.Notifications.GetAll(r.Context(), requestUserID, userID)
+	params, err := listquery.Parse(r.URL.Query())
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
+		return
+	}
+
+	notifications, err := c.services.Notifications.GetAll(r.Context(), requestUserID, userID, params)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -70,31 +81,31 @@ func (c *NotificationsController) GetAll(w http.ResponseWriter, r *http.Request)
 // @Param        user_id          path      int  true  "User ID"
 // @Param        notification_id  path      int  true  "Notification ID"
 // @Success      200              {object}  models.Notification
-// @Failure      400              {object}  map[string]string
-// @Failure      401              {object}  map[string]string
-// @Failure      500              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
 // @Router       /users/{user_id}/notifications/{notification_id} [get]
 func (c *NotificationsController) GetByID(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("userkey not found in context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	if requestUserID != userID {
-		responses.Error(w, http.StatusUnauthorized, errors.New("only the notifications owner can se the notifications"))
+		responses.Problem(w, r, services.ErrNotDataOwner)
 		return
 	}
 
@@ -102,7 +113,7 @@ func (c *NotificationsController) GetByID(w http.ResponseWriter, r *http.Request
 
 	notifications, err := c.services.Notifications.GetByID(r.Context(), requestUserID, userID, notificationID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -119,31 +130,31 @@ func (c *NotificationsController) GetByID(w http.ResponseWriter, r *http.Request
 // @Param        user_id          path      int  true  "User ID"
 // @Param        notification_id  path      int  true  "Notification ID"
 // @Success      200              {object}  map[string]int
-// @Failure      400              {object}  map[string]string
-// @Failure      401              {object}  map[string]string
-// @Failure      500              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
 // @Router       /users/{user_id}/notifications/{notification_id} [delete]
 func (c *NotificationsController) Delete(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("userkey not found in context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	if requestUserID != userID {
-		responses.Error(w, http.StatusUnauthorized, errors.New("only the notifications owner can se the notifications"))
+		responses.Problem(w, r, services.ErrNotDataOwner)
 		return
 	}
 
@@ -151,7 +162,7 @@ func (c *NotificationsController) Delete(w http.ResponseWriter, r *http.Request)
 
 	notifications, err := c.services.Notifications.Delete(r.Context(), requestUserID, userID, notificationID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"net/http"
+)
+
+type PlansController struct {
+	services services.Services
+}
+
+// GetAll lists the available plans
+// @Summary      List plans
+// @Description  Retrieve every plan with its seat and team limits, including the free plan
+// @Tags         plans
+// @Accept       json
+// @Produce      json
+// @Success      200   {array}   models.Plan
+// @Failure      500   {object}  responses.ProblemDetails
+// @Router       /plans [get]
+func (c *PlansController) GetAll(w http.ResponseWriter, r *http.Request) {
+
+	plans, err := c.services.Plans.GetAll(r.Context())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, plans)
+}
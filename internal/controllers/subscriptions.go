@@ -1,12 +1,17 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/authentication"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"HareID/internal/responses"
 	"HareID/internal/services"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
 )
 
 type SubscriptionsController struct {
@@ -15,25 +20,27 @@ type SubscriptionsController struct {
 
 // Create creates a new subscription
 // @Summary      Create subscription
-// @Description  Create a new subscription
+// @Description  Create a subscription record directly, bypassing checkout. Admin only
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        subscription  body      models.Subscription  true  "Subscription Data"
 // @Success      201           {object}  models.Subscription
-// @Failure      500           {object}  map[string]string
+// @Failure      401           {object}  responses.ProblemDetails
+// @Failure      403           {object}  responses.ProblemDetails
+// @Failure      500           {object}  responses.ProblemDetails
 // @Router       /subscriptions [post]
 func (c *SubscriptionsController) Create(w http.ResponseWriter, r *http.Request) {
 	var subscription models.Subscription
 	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	newSubscription, err := c.services.Subscriptions.Create(r.Context(), subscription)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -42,18 +49,30 @@ func (c *SubscriptionsController) Create(w http.ResponseWriter, r *http.Request)
 
 // GetAll retrieves all subscriptions
 // @Summary      Get all subscriptions
-// @Description  Retrieve a list of all subscriptions
+// @Description  Retrieve a list of all subscriptions. Admin only; regular users use GET /me/subscriptions
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200   {array}   models.Subscription
-// @Failure      500   {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200   {object}  listquery.Page[models.Subscription]
+// @Failure      401   {object}  responses.ProblemDetails
+// @Failure      403   {object}  responses.ProblemDetails
+// @Failure      500   {object}  responses.ProblemDetails
 // @Router       /subscriptions [get]
 func (c *SubscriptionsController) GetAll(w http.ResponseWriter, r *http.Request) {
-	subscriptions, err := c.services.Subscriptions.GetAll(r.Context())
+	params, err := listquery.Parse(r.URL.Query())
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
+		return
+	}
+
+	subscriptions, err := c.services.Subscriptions.GetAll(r.Context(), params)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -62,26 +81,41 @@ func (c *SubscriptionsController) GetAll(w http.ResponseWriter, r *http.Request)
 
 // GetBySubscriptionID retrieves a subscription by ID
 // @Summary      Get subscription by ID
-// @Description  Retrieve details of a specific subscription
+// @Description  Retrieve details of a specific subscription. Restricted to the subscription owner and platform administrators
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        subscription_id  path      string  true  "Subscription ID"
 // @Success      200              {object}  models.Subscription
-// @Failure      400              {object}  map[string]string
-// @Failure      500              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      403              {object}  responses.ProblemDetails
+// @Failure      404              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
 // @Router       /subscriptions/{subscription_id} [get]
 func (c *SubscriptionsController) GetBySubscriptionID(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
 	subscriptionID := r.PathValue("subscription_id")
 	if subscriptionID == "" {
-		responses.Error(w, http.StatusBadRequest, errors.New("subscription_id is required"))
+		responses.Problem(w, r, apperrors.Field("subscription_id", "is required"))
 		return
 	}
 
-	subscription, err := c.services.Subscriptions.GetBySubscriptionID(r.Context(), subscriptionID)
+	subscription, err := c.services.Subscriptions.GetBySubscriptionID(r.Context(), userID, subscriptionID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -90,7 +124,7 @@ func (c *SubscriptionsController) GetBySubscriptionID(w http.ResponseWriter, r *
 
 // Update modifies an existing subscription
 // @Summary      Update subscription
-// @Description  Update details of an existing subscription
+// @Description  Update the stored subscription record directly; provider changes go through cancel and change-plan. Admin only
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
@@ -98,26 +132,27 @@ func (c *SubscriptionsController) GetBySubscriptionID(w http.ResponseWriter, r *
 // @Param        subscription_id  path      string               true  "Subscription ID"
 // @Param        subscription     body      models.Subscription  true  "Subscription Update Data"
 // @Success      200              {object}  map[string]uint64
-// @Failure      400              {object}  map[string]string
-// @Failure      403              {object}  map[string]string
-// @Failure      500              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      403              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
 // @Router       /subscriptions/{subscription_id} [patch]
 func (c *SubscriptionsController) Update(w http.ResponseWriter, r *http.Request) {
 	subscriptionID := r.PathValue("subscription_id")
 	if subscriptionID == "" {
-		responses.Error(w, http.StatusBadRequest, errors.New("subscription_id is required"))
+		responses.Problem(w, r, apperrors.Field("subscription_id", "is required"))
 		return
 	}
 
 	var subscription models.Subscription
 	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := c.services.Subscriptions.Update(r.Context(), subscriptionID, subscription)
 	if err != nil {
-		responses.Error(w, http.StatusForbidden, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -130,27 +165,28 @@ func (c *SubscriptionsController) Update(w http.ResponseWriter, r *http.Request)
 
 // Delete removes a subscription
 // @Summary      Delete subscription
-// @Description  Remove a subscription from the system
+// @Description  Remove a subscription record from the system. Admin only
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        subscription_id  path      string  true  "Subscription ID"
 // @Success      200              {object}  map[string]uint64
-// @Failure      400              {object}  map[string]string
-// @Failure      403              {object}  map[string]string
-// @Failure      500              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      403              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
 // @Router       /subscriptions/{subscription_id} [delete]
 func (c *SubscriptionsController) Delete(w http.ResponseWriter, r *http.Request) {
 	subscriptionID := r.PathValue("subscription_id")
 	if subscriptionID == "" {
-		responses.Error(w, http.StatusBadRequest, errors.New("subscription_id is required"))
+		responses.Problem(w, r, apperrors.Field("subscription_id", "is required"))
 		return
 	}
 
 	affectedRows, err := c.services.Subscriptions.Delete(r.Context(), subscriptionID)
 	if err != nil {
-		responses.Error(w, http.StatusForbidden, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -160,3 +196,126 @@ func (c *SubscriptionsController) Delete(w http.ResponseWriter, r *http.Request)
 
 	responses.JSON(w, http.StatusOK, data)
 }
+
+type CancelSubscriptionRequest struct {
+	AtPeriodEnd bool `json:"at_period_end"`
+}
+
+type ChangePlanRequest struct {
+	PriceID       string `json:"price_id"`
+	Preview       bool   `json:"preview"`
+	ProrationDate int64  `json:"proration_date"`
+}
+
+// Cancel cancels a subscription in Stripe
+// @Summary      Cancel subscription
+// @Description  Cancel the caller's subscription immediately or at the end of the current period. Local state is updated by the resulting webhook
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        subscription_id  path      string                     true  "Subscription ID"
+// @Param        request          body      CancelSubscriptionRequest  false "Cancel Options"
+// @Success      202              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      403              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
+// @Router       /subscriptions/{subscription_id}/cancel [post]
+func (c *SubscriptionsController) Cancel(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	subscriptionID := r.PathValue("subscription_id")
+	if subscriptionID == "" {
+		responses.Problem(w, r, apperrors.Field("subscription_id", "is required"))
+		return
+	}
+
+	var req CancelSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	if err := c.services.Subscriptions.Cancel(r.Context(), userID, subscriptionID, req.AtPeriodEnd); err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusAccepted, map[string]string{"status": "cancellation requested"})
+}
+
+// ChangePlan switches a subscription to another plan
+// @Summary      Change subscription plan
+// @Description  Switch the caller's subscription to another price with proration. With preview=true nothing changes and the prorated amount of the next invoice is returned; send its proration_date back to charge exactly the previewed amount
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        subscription_id  path      string             true  "Subscription ID"
+// @Param        request          body      ChangePlanRequest  true  "Plan Change Data"
+// @Success      200              {object}  models.PlanChangePreview
+// @Success      202              {object}  map[string]string
+// @Failure      400              {object}  responses.ProblemDetails
+// @Failure      401              {object}  responses.ProblemDetails
+// @Failure      403              {object}  responses.ProblemDetails
+// @Failure      500              {object}  responses.ProblemDetails
+// @Router       /subscriptions/{subscription_id}/change-plan [post]
+func (c *SubscriptionsController) ChangePlan(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	subscriptionID := r.PathValue("subscription_id")
+	if subscriptionID == "" {
+		responses.Problem(w, r, apperrors.Field("subscription_id", "is required"))
+		return
+	}
+
+	var req ChangePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	if req.PriceID == "" {
+		responses.Problem(w, r, apperrors.Field("price_id", "is required"))
+		return
+	}
+
+	if req.Preview {
+		preview, err := c.services.Subscriptions.PreviewPlanChange(r.Context(), userID, subscriptionID, req.PriceID)
+		if err != nil {
+			responses.Problem(w, r, err)
+			return
+		}
+
+		responses.JSON(w, http.StatusOK, preview)
+		return
+	}
+
+	if err := c.services.Subscriptions.ChangePlan(r.Context(), userID, subscriptionID, req.PriceID, req.ProrationDate); err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusAccepted, map[string]string{"status": "plan change requested"})
+}
@@ -1,7 +1,67 @@
 package controllers
 
-import "HareID/internal/services"
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/middleware"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"net/http"
+	"strconv"
+)
 
 type TeamMembersController struct {
 	services services.Services
 }
+
+// Delete removes a member from a team
+// @Summary      Remove team member
+// @Description  Remove a member from the team (owner) or leave the team (the member itself). Per-seat billing is updated automatically
+// @Tags         teams
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        team_id  path      int  true  "Team ID"
+// @Param        user_id  path      int  true  "User ID"
+// @Success      200      {object}  map[string]uint64
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /teams/{team_id}/members/{user_id} [delete]
+func (c *TeamMembersController) Delete(w http.ResponseWriter, r *http.Request) {
+
+	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
+	if !ok {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
+		return
+	}
+
+	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	affectedRows, err := c.services.TeamMembers.Delete(r.Context(), requestUserID, teamID, userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	data := map[string]uint64{
+		"affectedRows": affectedRows,
+	}
+
+	responses.JSON(w, http.StatusOK, data)
+}
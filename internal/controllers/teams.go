@@ -1,13 +1,13 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/listquery"
 	"HareID/internal/middleware"
 	"HareID/internal/models"
 	"HareID/internal/responses"
 	"HareID/internal/services"
 	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
 	"strconv"
 )
@@ -25,35 +25,35 @@ type TeamsController struct {
 // @Security     BearerAuth
 // @Param        team  body      models.Team  true  "Team Creation Data"
 // @Success      201   {object}  map[string]interface{}
-// @Failure      400   {object}  map[string]string
-// @Failure      401   {object}  map[string]string
-// @Failure      500   {object}  map[string]string
+// @Failure      400   {object}  responses.ProblemDetails
+// @Failure      401   {object}  responses.ProblemDetails
+// @Failure      402   {object}  responses.ProblemDetails
+// @Failure      403   {object}  responses.ProblemDetails
+// @Failure      500   {object}  responses.ProblemDetails
 // @Router       /teams [post]
 func (c *TeamsController) Create(w http.ResponseWriter, r *http.Request) {
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("User ID not found in context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
-	log.Printf("Request user id: %d", requestUserID)
-
 	var team models.Team
 
 	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	newTeam, teamMember, err := c.services.Teams.Create(r.Context(), requestUserID, team)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -67,19 +67,31 @@ func (c *TeamsController) Create(w http.ResponseWriter, r *http.Request) {
 
 // GetAll retrieves all teams
 // @Summary      Get all teams
-// @Description  Retrieve a list of all teams
+// @Description  Retrieve a list of all teams. Admin only; regular users use GET /me/teams
 // @Tags         teams
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      302   {array}   models.Team
-// @Failure      500   {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      302   {object}  listquery.Page[models.Team]
+// @Failure      401   {object}  responses.ProblemDetails
+// @Failure      403   {object}  responses.ProblemDetails
+// @Failure      500   {object}  responses.ProblemDetails
 // @Router       /teams [get]
 func (c *TeamsController) GetAll(w http.ResponseWriter, r *http.Request) {
 
-	teams, err := c.services.Teams.GetAll(r.Context())
+	params, err := listquery.Parse(r.URL.Query())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	teams, err := c.services.Teams.GetAll(r.Context(), params)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -95,19 +107,20 @@ func (c *TeamsController) GetAll(w http.ResponseWriter, r *http.Request) {
 // @Security     BearerAuth
 // @Param        team_id  path      int  true  "Team ID"
 // @Success      302      {object}  models.Team
-// @Failure      400      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      404      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id} [get]
 func (c *TeamsController) GetByID(w http.ResponseWriter, r *http.Request) {
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	teams, err := c.services.Teams.GetByID(r.Context(), teamID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -117,7 +130,7 @@ func (c *TeamsController) GetByID(w http.ResponseWriter, r *http.Request) {
 func (c *TeamsController) GetByOwnerID(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
@@ -134,20 +147,30 @@ func (c *TeamsController) GetByOwnerID(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Security     BearerAuth
 // @Param        team_id  path      int  true  "Team ID"
-// @Success      200      {array}   models.TeamMember
-// @Failure      400      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200      {object}  listquery.Page[models.TeamMember]
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id}/members [get]
 func (c *TeamsController) GetTeamMembers(w http.ResponseWriter, r *http.Request) {
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	params, err := listquery.Parse(r.URL.Query())
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
-	teamMembers, err := c.services.TeamMembers.GetAll(r.Context(), teamID)
+	teamMembers, err := c.services.TeamMembers.GetAll(r.Context(), teamID, params)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -164,40 +187,41 @@ func (c *TeamsController) GetTeamMembers(w http.ResponseWriter, r *http.Request)
 // @Param        team_id  path      int          true  "Team ID"
 // @Param        team     body      models.Team  true  "Team Update Data"
 // @Success      200      {object}  map[string]uint64
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id} [patch]
 func (c *TeamsController) Update(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the context"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	var team models.Team
 
 	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := c.services.Teams.Update(r.Context(), teamID, requestUserID, team)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -217,33 +241,33 @@ func (c *TeamsController) Update(w http.ResponseWriter, r *http.Request) {
 // @Security     BearerAuth
 // @Param        team_id  path      int  true  "Team ID"
 // @Success      200      {object}  map[string]uint64
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /teams/{team_id} [delete]
 func (c *TeamsController) Delete(w http.ResponseWriter, r *http.Request) {
 
 	requestUserIDString, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("Userkey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(requestUserIDString, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := c.services.Teams.Delete(r.Context(), teamID, requestUserID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
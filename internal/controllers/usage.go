@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/authentication"
+	"HareID/internal/models"
+	"HareID/internal/responses"
+	"HareID/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type UsageController struct {
+	services services.Services
+}
+
+type RecordUsageRequest struct {
+	Events []models.UsageEvent `json:"events"`
+}
+
+type RecordUsageResponse struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+}
+
+// Record stores usage events reported by internal services
+// @Summary      Record usage events
+// @Description  Store metered usage (team, meter, quantity, timestamp) reported by internal services. Events repeating an idempotency_key are ignored and counted as duplicates. Requires the X-Internal-Token header
+// @Tags         usage
+// @Accept       json
+// @Produce      json
+// @Param        X-Internal-Token  header    string              true  "Internal API token"
+// @Param        request           body      RecordUsageRequest  true  "Usage events"
+// @Success      202               {object}  RecordUsageResponse
+// @Failure      400               {object}  responses.ProblemDetails
+// @Failure      401               {object}  responses.ProblemDetails
+// @Failure      500               {object}  responses.ProblemDetails
+// @Router       /internal/usage [post]
+func (c *UsageController) Record(w http.ResponseWriter, r *http.Request) {
+	var req RecordUsageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	accepted, duplicates, err := c.services.Usage.Record(r.Context(), req.Events)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusAccepted, RecordUsageResponse{Accepted: accepted, Duplicates: duplicates})
+}
+
+// GetTeamUsage shows the team's consumption in the current billing period
+// @Summary      Get team usage
+// @Description  Sum the team's usage per meter in the current billing period and compare it to the plan limits. Restricted to team members
+// @Tags         usage
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        team_id  path      int  true  "Team ID"
+// @Success      200      {object}  models.UsageSummary
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /teams/{team_id}/usage [get]
+func (c *UsageController) GetTeamUsage(w http.ResponseWriter, r *http.Request) {
+	requestUserID, err := authentication.GetTokenUserID(r)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(requestUserID, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	teamID, err := strconv.ParseUint(r.PathValue("team_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	summary, err := c.services.Usage.GetTeamSummary(r.Context(), userID, teamID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, summary)
+}
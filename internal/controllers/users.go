@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/listquery"
 	"HareID/internal/middleware"
 	"HareID/internal/models"
 	"HareID/internal/responses"
 	"HareID/internal/services"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
 )
@@ -17,26 +20,27 @@ type UsersController struct {
 
 // Create creates a new user
 // @Summary      Create a new user
-// @Description  Register a new user in the system
+// @Description  Register a new user in the system. cpf_cnpj is optional; when present it must be a valid CPF or CNPJ (including the alphanumeric CNPJ), with or without punctuation, and not used by another account. It is stored digits-only and returned formatted
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        user  body      models.User  true  "User Registration Data"
 // @Success      201   {object}  models.User
-// @Failure      500   {object}  map[string]string
+// @Failure      422   {object}  responses.ProblemDetails
+// @Failure      500   {object}  responses.ProblemDetails
 // @Router       /users [post]
 func (c *UsersController) Create(w http.ResponseWriter, r *http.Request) {
 
 	var user models.User
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	newUser, err := c.services.Users.Create(r.Context(), user)
+	newUser, err := c.services.Users.Create(r.Context(), user, consentSource(r))
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -45,19 +49,38 @@ func (c *UsersController) Create(w http.ResponseWriter, r *http.Request) {
 
 // GetAll retrieves all users
 // @Summary      Get all users
-// @Description  Retrieve a list of all registered users
+// @Description  Retrieve a list of all registered users. cpf_cnpj is masked (e.g. ***.***.123-45) except for the caller's own record and for users in PII_READER_USER_IDS. Admin only; regular users use GET /me
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200   {array}   models.User
-// @Failure      500   {object}  map[string]string
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200   {object}  listquery.Page[models.User]
+// @Failure      401   {object}  responses.ProblemDetails
+// @Failure      403   {object}  responses.ProblemDetails
+// @Failure      500   {object}  responses.ProblemDetails
 // @Router       /users [get]
 func (c *UsersController) GetAll(w http.ResponseWriter, r *http.Request) {
+	userIDToken, _ := r.Context().Value(middleware.UserKey).(string)
 
-	users, err := c.services.Users.GetAll(r.Context())
+	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	params, err := listquery.Parse(r.URL.Query())
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	users, err := c.services.Users.GetAll(r.Context(), requestUserID, params)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -66,55 +89,157 @@ func (c *UsersController) GetAll(w http.ResponseWriter, r *http.Request) {
 
 // GetByID retrieves a user by ID
 // @Summary      Get user by ID
-// @Description  Retrieve details of a specific user by their ID
+// @Description  Retrieve details of a specific user by their ID. cpf_cnpj is masked unless the caller is the user or is in PII_READER_USER_IDS; stripe_customer_id is only returned to the user
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        user_id  path      int  true  "User ID"
 // @Success      200      {object}  models.User
-// @Failure      400      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      404      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /users/{user_id} [get]
 func (c *UsersController) GetByID(w http.ResponseWriter, r *http.Request) {
 
-	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.services.Users.GetByID(r.Context(), requestUserID, userID)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
-	user, err := c.services.Users.GetByID(r.Context(), userID)
+	responses.JSON(w, http.StatusOK, user)
+}
+
+// GetByCpfCnpj finds a user by exact CPF/CNPJ
+// @Summary      Find user by CPF/CNPJ
+// @Description  Exact lookup through the blind index; punctuation is ignored. cpf_cnpj is masked unless the caller is in PII_READER_USER_IDS. Admin only
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        cpf_cnpj  query     string  true  "CPF or CNPJ"
+// @Success      200       {object}  models.User
+// @Failure      400       {object}  responses.ProblemDetails
+// @Failure      401       {object}  responses.ProblemDetails
+// @Failure      403       {object}  responses.ProblemDetails
+// @Failure      404       {object}  responses.ProblemDetails
+// @Router       /admin/users/lookup [get]
+func (c *UsersController) GetByCpfCnpj(w http.ResponseWriter, r *http.Request) {
+	userIDToken, _ := r.Context().Value(middleware.UserKey).(string)
+
+	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	cpfCnpj := r.URL.Query().Get("cpf_cnpj")
+	if cpfCnpj == "" {
+		responses.Problem(w, r, apperrors.Field("cpf_cnpj", "is required"))
+		return
+	}
+
+	user, err := c.services.Users.GetByCpfCnpj(r.Context(), requestUserID, cpfCnpj)
+	if err != nil {
+		responses.Problem(w, r, err)
 		return
 	}
 
 	responses.JSON(w, http.StatusOK, user)
 }
 
+type SetAdminRequest struct {
+	IsAdmin *bool `json:"is_admin"`
+}
+
+// SetAdmin grants or revokes platform administrator access
+// @Summary      Grant or revoke admin access
+// @Description  Set whether the user is a platform administrator, independent of team roles. Administrators cannot revoke their own access. Admin only
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id  path      int              true  "User ID"
+// @Param        request  body      SetAdminRequest  true  "Admin access"
+// @Success      200      {object}  map[string]uint64
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      404      {object}  responses.ProblemDetails
+// @Failure      409      {object}  responses.ProblemDetails
+// @Failure      422      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
+// @Router       /admin/users/{user_id}/admin [patch]
+func (c *UsersController) SetAdmin(w http.ResponseWriter, r *http.Request) {
+	userIDToken, _ := r.Context().Value(middleware.UserKey).(string)
+
+	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+		return
+	}
+
+	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	var req SetAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		responses.Problem(w, r, apperrors.BadRequest(err))
+		return
+	}
+
+	if req.IsAdmin == nil {
+		responses.Problem(w, r, apperrors.Field("is_admin", "is required"))
+		return
+	}
+
+	affectedRows, err := c.services.Users.SetAdmin(r.Context(), requestUserID, userID, *req.IsAdmin)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	data := map[string]uint64{
+		"affected_rows": affectedRows,
+	}
+
+	responses.JSON(w, http.StatusOK, data)
+}
+
 // GetUserTeam retrieves the team associated with a user
 // @Summary      Get user's team
-// @Description  Retrieve the team information for a specific user
+// @Description  Retrieve the team information for a specific user. Restricted to the user and platform administrators
 // @Tags         users
 // @Accept       json
 // @Produce      json
+// @Security     BearerAuth
 // @Param        user_id  path      int  true  "User ID"
 // @Success      200      {object}  models.TeamMember
-// @Failure      400      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /users/{user_id}/teams [get]
 func (c *UsersController) GetUserTeam(w http.ResponseWriter, r *http.Request) {
 
-	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
-	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
 		return
 	}
 
-	teamMember, err := c.services.TeamMembers.GetByUserID(r.Context(), userID)
+	teamMember, err := c.services.TeamMembers.GetByUserID(r.Context(), requestUserID, userID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -123,7 +248,7 @@ func (c *UsersController) GetUserTeam(w http.ResponseWriter, r *http.Request) {
 
 // Update modifies an existing user
 // @Summary      Update user
-// @Description  Update details of an existing user
+// @Description  Update details of an existing user. cpf_cnpj follows the same validation as the registration; stripe_customer_id is ignored (it is set by checkout)
 // @Tags         users
 // @Accept       json
 // @Produce      json
@@ -131,40 +256,41 @@ func (c *UsersController) GetUserTeam(w http.ResponseWriter, r *http.Request) {
 // @Param        user_id  path      int          true  "User ID"
 // @Param        user     body      models.User  true  "User Update Data"
 // @Success      200      {object}  map[string]uint64
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      403      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      422      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /users/{user_id} [patch]
 func (c *UsersController) Update(w http.ResponseWriter, r *http.Request) {
 
 	userIDToken, ok := r.Context().Value(middleware.UserKey).(string)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("UserKey not found in the request"))
+		responses.Problem(w, r, apperrors.ErrUnauthenticated)
 		return
 	}
 
 	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+		responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
 		return
 	}
 
 	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
 	affectedRows, err := c.services.Users.Update(r.Context(), userID, requestUserID, user)
 	if err != nil {
-		responses.Error(w, http.StatusForbidden, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
@@ -175,48 +301,102 @@ func (c *UsersController) Update(w http.ResponseWriter, r *http.Request) {
 	responses.JSON(w, http.StatusOK, data)
 }
 
-// Delete removes a user
+type DeleteUserRequest struct {
+	// transfer (padrão em DELETION_TEAM_POLICY) ou dissolve
+	TeamPolicy string `json:"team_policy"`
+}
+
+// Delete schedules the account deletion
 // @Summary      Delete user
-// @Description  Remove a user from the system
+// @Description  Schedule the deletion of the caller's account. After the cooling-off period the account is anonymized (name, cpf_cnpj, google_sub), owned teams are transferred or dissolved according to team_policy, subscriptions are canceled, notifications are deleted, sessions are revoked and a tombstone is recorded. Team and billing history is kept
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        user_id  path      int  true  "User ID"
-// @Success      200      {object}  map[string]uint64
-// @Failure      400      {object}  map[string]string
-// @Failure      401      {object}  map[string]string
-// @Failure      500      {object}  map[string]string
+// @Param        user_id  path      int                true   "User ID"
+// @Param        request  body      DeleteUserRequest  false  "Deletion options"
+// @Success      202      {object}  models.AccountDeletion
+// @Failure      400      {object}  responses.ProblemDetails
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      500      {object}  responses.ProblemDetails
 // @Router       /users/{user_id} [delete]
 func (c *UsersController) Delete(w http.ResponseWriter, r *http.Request) {
 
-	userIDToken, ok := r.Context().Value(middleware.UserKey).(string)
+	requestUserID, userID, ok := pathUserIDs(w, r)
 	if !ok {
-		responses.Error(w, http.StatusUnauthorized, errors.New("UserKey not found in the request"))
 		return
 	}
 
-	requestUserID, err := strconv.ParseUint(userIDToken, 10, 64)
-	if err != nil {
-		responses.Error(w, http.StatusUnauthorized, err)
+	var req DeleteUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		responses.Problem(w, r, apperrors.BadRequest(err))
 		return
 	}
 
-	userID, err := strconv.ParseUint(r.PathValue("user_id"), 10, 64)
+	deletion, err := c.services.Deletions.Schedule(r.Context(), requestUserID, userID, req.TeamPolicy)
 	if err != nil {
-		responses.Error(w, http.StatusBadRequest, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
-	affectedRows, err := c.services.Users.Delete(r.Context(), userID, requestUserID)
+	responses.JSON(w, http.StatusAccepted, deletion)
+}
+
+// GetDeletion shows the caller's latest account deletion request
+// @Summary      Get account deletion
+// @Description  Retrieve the latest account deletion request of the caller (status: 0 scheduled, 1 canceled, 2 completed)
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id  path      int  true  "User ID"
+// @Success      200      {object}  models.AccountDeletion
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      404      {object}  responses.ProblemDetails
+// @Router       /users/{user_id}/deletion [get]
+func (c *UsersController) GetDeletion(w http.ResponseWriter, r *http.Request) {
+
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
+	}
+
+	deletion, err := c.services.Deletions.Get(r.Context(), requestUserID, userID)
 	if err != nil {
-		responses.Error(w, http.StatusInternalServerError, err)
+		responses.Problem(w, r, err)
 		return
 	}
 
-	data := map[string]uint64{
-		"affected_rows": affectedRows,
+	responses.JSON(w, http.StatusOK, deletion)
+}
+
+// CancelDeletion cancels a scheduled account deletion
+// @Summary      Cancel account deletion
+// @Description  Cancel the scheduled account deletion while the cooling-off period has not ended
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user_id  path      int  true  "User ID"
+// @Success      200      {object}  models.AccountDeletion
+// @Failure      401      {object}  responses.ProblemDetails
+// @Failure      403      {object}  responses.ProblemDetails
+// @Failure      409      {object}  responses.ProblemDetails
+// @Router       /users/{user_id}/deletion/cancel [post]
+func (c *UsersController) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+
+	requestUserID, userID, ok := pathUserIDs(w, r)
+	if !ok {
+		return
 	}
 
-	responses.JSON(w, http.StatusOK, data)
+	deletion, err := c.services.Deletions.Cancel(r.Context(), requestUserID, userID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, deletion)
 }
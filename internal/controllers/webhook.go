@@ -1,38 +1,55 @@
 package controllers
 
 import (
-	"HareID/internal/enums/subscription"
-	"HareID/internal/models"
+	"HareID/internal/apperrors"
+	"HareID/internal/enums"
+	"HareID/internal/listquery"
+	"HareID/internal/payments"
+	"HareID/internal/responses"
 	"HareID/internal/services"
-	"context"
-	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/stripe/stripe-go/v79"
-	stripeWebhook "github.com/stripe/stripe-go/v79/webhook"
 )
 
 type WebhookController struct {
 	services services.Services
 }
 
-// HandleWebhook processes Stripe webhook events
+// HandleWebhook stores Stripe webhook events for asynchronous processing
 // @Summary      Stripe Webhook
-// @Description  Receive and process Stripe webhook events (e.g. checkout completed, subscription updated)
+// @Description  Verify and persist Stripe webhook events. Events are processed asynchronously with retries; repeated deliveries are acknowledged and ignored
 // @Tags         webhook
 // @Accept       json
 // @Produce      json
 // @Param        Stripe-Signature header string true "Stripe Signature"
 // @Success      200  {string}  string "OK"
 // @Failure      400  {string}  string "Bad Request"
+// @Failure      500  {string}  string "Internal Server Error"
 // @Failure      503  {string}  string "Service Unavailable"
 // @Router       /webhook [post]
 func (c *WebhookController) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	c.receive(w, r, payments.STRIPE)
+}
+
+// HandleAsaasWebhook stores Asaas (PIX/boleto) webhook events for asynchronous processing
+// @Summary      Asaas Webhook
+// @Description  Verify the Asaas access token and persist payment and subscription events. Events are processed asynchronously with retries; repeated deliveries are acknowledged and ignored
+// @Tags         webhook
+// @Accept       json
+// @Produce      json
+// @Param        asaas-access-token header string true "Webhook token configured in Asaas"
+// @Success      200  {string}  string "OK"
+// @Failure      400  {string}  string "Bad Request"
+// @Failure      500  {string}  string "Internal Server Error"
+// @Failure      503  {string}  string "Service Unavailable"
+// @Router       /webhook/asaas [post]
+func (c *WebhookController) HandleAsaasWebhook(w http.ResponseWriter, r *http.Request) {
+	c.receive(w, r, payments.ASAAS)
+}
+
+func (c *WebhookController) receive(w http.ResponseWriter, r *http.Request, provider string) {
 	const MaxBodyBytes = int64(65536)
 	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
 
@@ -42,88 +59,108 @@ func (c *WebhookController) HandleWebhook(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	endpointSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
-
-	event, err := stripeWebhook.ConstructEvent(payload, r.Header.Get("Stripe-Signature"), endpointSecret)
-	if err != nil {
+	created, err := c.services.Webhooks.Receive(r.Context(), provider, payload, r.Header)
+	if errors.Is(err, payments.ErrInvalidSignature) {
 		http.Error(w, "Invalid Signature", http.StatusBadRequest)
 		return
 	}
+	if err != nil {
+		// Sem persistir o evento o provedor precisa reenviar
+		slog.ErrorContext(r.Context(), "error storing webhook event", "provider", provider, "error", err)
+		http.Error(w, "Error storing event", http.StatusInternalServerError)
+		return
+	}
 
-	switch event.Type {
-	case "checkout.session.completed":
-		var session stripe.CheckoutSession
-		err := json.Unmarshal(event.Data.Raw, &session)
-		if err != nil {
-			http.Error(w, "Error parsing webhook JSON", http.StatusBadRequest)
-			return
-		}
-
-		if session.ClientReferenceID != "" {
-			userID, err := strconv.ParseUint(session.ClientReferenceID, 10, 64)
-			if err == nil {
-				user, err := c.services.Users.GetByID(r.Context(), userID)
-				if err == nil {
-					user.StripeCustomerID = session.Customer.ID
-					c.services.Users.Update(r.Context(), userID, userID, user)
-				}
-			}
-		}
-
-	case "customer.subscription.updated", "customer.subscription.created", "customer.subscription.deleted":
-		var stripeSub stripe.Subscription
-		err := json.Unmarshal(event.Data.Raw, &stripeSub)
-		if err != nil {
-			http.Error(w, "Error parsing webhook JSON", http.StatusBadRequest)
-			return
-		}
-
-		if err := c.processSubscriptionEvent(r.Context(), stripeSub); err != nil {
-			http.Error(w, "Error processing event: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if !created {
+		slog.InfoContext(r.Context(), "duplicate webhook delivery", "provider", provider)
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func (c *WebhookController) processSubscriptionEvent(ctx context.Context, stripeSub stripe.Subscription) error {
-	status := mapStripeStatusToEnum(string(stripeSub.Status))
+// ListEvents lists stored Stripe events by processing status
+// @Summary      List webhook events
+// @Description  List stored Stripe events filtered by status (pending, processed or failed). Restricted to administrators
+// @Tags         webhook
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  query     string  false  "pending, processed or failed (default failed)"
+// @Param        limit          query     int     false  "Page size, 1 to 200 (default 50)"
+// @Param        page_token     query     string  false  "next_page_token returned by the previous page"
+// @Param        sort           query     string  false  "Sort field, prefixed with - for descending order"
+// @Param        include_total  query     bool    false  "Also count the items matching the filters"
+// @Success      200     {object}  listquery.Page[models.StripeEvent]
+// @Failure      400     {object}  responses.ProblemDetails
+// @Failure      401     {object}  responses.ProblemDetails
+// @Failure      403     {object}  responses.ProblemDetails
+// @Failure      500     {object}  responses.ProblemDetails
+// @Router       /admin/webhook-events [get]
+func (c *WebhookController) ListEvents(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	var status enums.WebhookEventStatus
+
+	switch query.Get("status") {
+	case "", "failed":
+		status = enums.EVENT_FAILED
+	case "pending":
+		status = enums.EVENT_PENDING
+	case "processed":
+		status = enums.EVENT_PROCESSED
+	default:
+		responses.Problem(w, r, apperrors.Field("status", "must be pending, processed or failed"))
+		return
+	}
+
+	// status é parâmetro próprio da rota, não um filtro da listagem
+	query.Del("status")
 
-	stripeCustomerID := stripeSub.Customer.ID
-	user, err := c.services.Users.GetByStripeCustomerID(ctx, stripeCustomerID)
+	params, err := listquery.Parse(query)
 	if err != nil {
-		return nil
+		responses.Problem(w, r, err)
+		return
 	}
 
-	sub := models.Subscription{
-		UserID:           user.ID,
-		SubscriptionID:   stripeSub.ID,
-		PriceID:          stripeSub.Items.Data[0].Price.ID,
-		Status:           status,
-		CurrentPeriodEnd: time.Unix(stripeSub.CurrentPeriodEnd, 0),
+	events, err := c.services.Webhooks.GetAll(r.Context(), status, params)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
 	}
 
-	return c.services.Subscriptions.UpsertSubscription(ctx, sub)
+	responses.JSON(w, http.StatusOK, events)
 }
 
-func mapStripeStatusToEnum(status string) subscription.Subscription {
-	switch strings.ToLower(status) {
-	case "active":
-		return subscription.ACTIVE
-	case "past_due":
-		return subscription.PAST_DUE
-	case "unpaid":
-		return subscription.UNPAID
-	case "canceled":
-		return subscription.CANCELED
-	case "incomplete":
-		return subscription.INCOMPLETE
-	case "incomplete_expired":
-		return subscription.INCOMPLETE_EXPIRED
-	case "trialing":
-		return subscription.TRIALING
-	default:
-		return subscription.UNKNOWN
+// ReplayEvent queues a stored Stripe event again
+// @Summary      Replay webhook event
+// @Description  Put a processed or failed event back in the queue with its attempts reset; the webhook worker processes it on its next run. Events still pending answer 409. Restricted to administrators
+// @Tags         webhook
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        event_id  path      string  true  "Stripe Event ID"
+// @Success      202       {object}  models.StripeEvent
+// @Failure      400       {object}  responses.ProblemDetails
+// @Failure      401       {object}  responses.ProblemDetails
+// @Failure      403       {object}  responses.ProblemDetails
+// @Failure      404       {object}  responses.ProblemDetails
+// @Failure      409       {object}  responses.ProblemDetails
+// @Failure      500       {object}  responses.ProblemDetails
+// @Router       /admin/webhook-events/{event_id}/replay [post]
+func (c *WebhookController) ReplayEvent(w http.ResponseWriter, r *http.Request) {
+
+	eventID := r.PathValue("event_id")
+	if eventID == "" {
+		responses.Problem(w, r, apperrors.Field("event_id", "is required"))
+		return
 	}
+
+	event, err := c.services.Webhooks.Replay(r.Context(), eventID)
+	if err != nil {
+		responses.Problem(w, r, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusAccepted, event)
 }
@@ -2,11 +2,11 @@ package db
 
 import (
 	"HareID/config"
+	"HareID/internal/tracing"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
-	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -16,7 +16,7 @@ var (
 	once   sync.Once
 )
 
-func Inicialize(maxOpenConns, maxIdleConns int32, maxIdleTime time.Duration) error {
+func Inicialize(cfg config.Database) error {
 
 	var err error
 
@@ -24,17 +24,20 @@ func Inicialize(maxOpenConns, maxIdleConns int32, maxIdleTime time.Duration) err
 
 		var dbConfig *pgxpool.Config
 
-		dbConfig, err = pgxpool.ParseConfig(config.ConnectionString)
+		dbConfig, err = pgxpool.ParseConfig(cfg.URL)
 		if err != nil {
-			log.Println(err)
 			return
 		}
 
-		dbConfig.MaxConns = maxOpenConns // Máximo de conexões
+		dbConfig.MaxConns = cfg.MaxConns // Máximo de conexões
 
-		dbConfig.MinConns = maxIdleConns // Mínimo de conexões - Ficam 2 em standby aguardando
+		dbConfig.MinConns = cfg.MinConns // Mínimo de conexões - Ficam em standby aguardando
 
-		dbConfig.MaxConnIdleTime = maxIdleTime // Tempo maximo que uma conexão pode ficar ociosa
+		dbConfig.MaxConnIdleTime = cfg.MaxConnIdleTime // Tempo maximo que uma conexão pode ficar ociosa
+
+		dbConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+
+		dbConfig.ConnConfig.Tracer = tracing.QueryTracer{} // Span por consulta
 
 		dbPool, err = pgxpool.NewWithConfig(context.Background(), dbConfig)
 	})
@@ -44,11 +47,10 @@ func Inicialize(maxOpenConns, maxIdleConns int32, maxIdleTime time.Duration) err
 	}
 
 	if err := dbPool.Ping(context.Background()); err != nil {
-		log.Println(err)
 		return fmt.Errorf("error db ping: %w", err)
 	}
 
-	fmt.Println("Db Conn OK")
+	slog.Info("database connection ok")
 	return nil
 }
 
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS plans;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS teamjoinrequests;
+DROP TABLE IF EXISTS teammembers;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
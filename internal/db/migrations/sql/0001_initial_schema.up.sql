@@ -0,0 +1,82 @@
+-- Esquema original (antes criado pelo console do Supabase). IF NOT EXISTS permite aplicar em bancos que já o têm
+
+CREATE TABLE IF NOT EXISTS users (
+    id                 BIGSERIAL PRIMARY KEY,
+    google_sub         TEXT NOT NULL UNIQUE,
+    name               TEXT NOT NULL,
+    cpf_cnpj           TEXT NOT NULL DEFAULT '',
+    stripe_customer_id TEXT NOT NULL DEFAULT '',
+    auth_provider      INTEGER NOT NULL DEFAULT 0,
+    consent_terms      BOOLEAN NOT NULL DEFAULT FALSE,
+    data_consent       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    create_date        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    update_date        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS users_stripe_customer_id_idx ON users (stripe_customer_id);
+
+CREATE TABLE IF NOT EXISTS teams (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    domain     TEXT NOT NULL,
+    owner_id   BIGINT NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS teams_owner_id_idx ON teams (owner_id);
+
+CREATE TABLE IF NOT EXISTS teammembers (
+    id         BIGSERIAL PRIMARY KEY,
+    team_id    BIGINT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id    BIGINT NOT NULL REFERENCES users (id),
+    role       INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS teammembers_user_id_idx ON teammembers (user_id);
+
+CREATE TABLE IF NOT EXISTS teamjoinrequests (
+    id            BIGSERIAL PRIMARY KEY,
+    team_id       BIGINT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    team_owner_id BIGINT NOT NULL REFERENCES users (id),
+    sender_id     BIGINT NOT NULL REFERENCES users (id),
+    status        INTEGER NOT NULL DEFAULT 0,
+    decision_at   TIMESTAMPTZ,
+    decision_by   BIGINT REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS teamjoinrequests_team_id_idx ON teamjoinrequests (team_id);
+CREATE INDEX IF NOT EXISTS teamjoinrequests_sender_id_idx ON teamjoinrequests (sender_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id           BIGSERIAL PRIMARY KEY,
+    sender_id    BIGINT NOT NULL REFERENCES users (id),
+    receiver_id  BIGINT NOT NULL REFERENCES users (id),
+    type         INTEGER NOT NULL,
+    reference_id BIGINT NOT NULL DEFAULT 0,
+    seen         BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_receiver_id_idx ON notifications (receiver_id);
+
+CREATE TABLE IF NOT EXISTS plans (
+    id        BIGSERIAL PRIMARY KEY,
+    price_id  TEXT NOT NULL UNIQUE,
+    name      TEXT NOT NULL,
+    max_seats INTEGER NOT NULL,
+    max_teams INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id                 BIGSERIAL PRIMARY KEY,
+    user_id            BIGINT NOT NULL REFERENCES users (id),
+    subscription_id    TEXT NOT NULL UNIQUE,
+    price_id           TEXT NOT NULL,
+    status             INTEGER NOT NULL DEFAULT 0,
+    current_period_end TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_user_id_idx ON subscriptions (user_id);
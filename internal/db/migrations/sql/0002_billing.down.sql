@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS billing_history;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS stripe_events;
+
+DELETE FROM notifications WHERE sender_id IS NULL;
+ALTER TABLE notifications ALTER COLUMN sender_id SET NOT NULL;
+
+DROP INDEX IF EXISTS subscriptions_team_id_idx;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS last_event_at,
+    DROP COLUMN IF EXISTS quantity,
+    DROP COLUMN IF EXISTS provider,
+    DROP COLUMN IF EXISTS team_id;
+
+ALTER TABLE plans
+    DROP COLUMN IF EXISTS meter_limits,
+    DROP COLUMN IF EXISTS features,
+    DROP COLUMN IF EXISTS amount,
+    DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,69 @@
+-- Provedores de pagamento, assinaturas por equipe, webhooks persistidos, faturas e histórico de cobrança
+
+ALTER TABLE plans
+    ADD COLUMN IF NOT EXISTS provider     TEXT NOT NULL DEFAULT 'stripe',
+    ADD COLUMN IF NOT EXISTS amount       BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS features     TEXT[],
+    ADD COLUMN IF NOT EXISTS meter_limits JSONB;
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS team_id       BIGINT REFERENCES teams (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS provider      TEXT NOT NULL DEFAULT 'stripe',
+    ADD COLUMN IF NOT EXISTS quantity      BIGINT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS last_event_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS subscriptions_team_id_idx ON subscriptions (team_id);
+
+-- Notificações do sistema (cobrança, teste gratuito) não têm remetente
+ALTER TABLE notifications ALTER COLUMN sender_id DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS stripe_events (
+    id                TEXT PRIMARY KEY,
+    provider          TEXT NOT NULL DEFAULT 'stripe',
+    type              TEXT NOT NULL,
+    object_id         TEXT NOT NULL DEFAULT '',
+    payload           JSONB NOT NULL,
+    status            INTEGER NOT NULL DEFAULT 0,
+    attempts          INTEGER NOT NULL DEFAULT 0,
+    last_error        TEXT,
+    stripe_created_at TIMESTAMPTZ NOT NULL,
+    next_attempt_at   TIMESTAMPTZ,
+    received_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS stripe_events_status_next_attempt_at_idx ON stripe_events (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id                 BIGSERIAL PRIMARY KEY,
+    invoice_id         TEXT NOT NULL UNIQUE,
+    user_id            BIGINT NOT NULL REFERENCES users (id),
+    team_id            BIGINT REFERENCES teams (id) ON DELETE SET NULL,
+    subscription_id    TEXT NOT NULL DEFAULT '',
+    status             INTEGER NOT NULL DEFAULT 0,
+    currency           TEXT NOT NULL DEFAULT '',
+    amount_due         BIGINT NOT NULL DEFAULT 0,
+    amount_paid        BIGINT NOT NULL DEFAULT 0,
+    amount_refunded    BIGINT NOT NULL DEFAULT 0,
+    hosted_invoice_url TEXT NOT NULL DEFAULT '',
+    period_start       TIMESTAMPTZ NOT NULL,
+    period_end         TIMESTAMPTZ NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_event_at      TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS invoices_user_id_idx ON invoices (user_id);
+
+CREATE TABLE IF NOT EXISTS billing_history (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id TEXT NOT NULL,
+    user_id         BIGINT NOT NULL REFERENCES users (id),
+    team_id         BIGINT REFERENCES teams (id) ON DELETE SET NULL,
+    event           INTEGER NOT NULL,
+    detail          TEXT NOT NULL DEFAULT '',
+    dedupe_key      TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subscription_id, dedupe_key)
+);
+
+CREATE INDEX IF NOT EXISTS billing_history_user_id_idx ON billing_history (user_id);
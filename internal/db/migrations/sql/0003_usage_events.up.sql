@@ -0,0 +1,18 @@
+-- Consumo medido por equipe, enviado ao provedor em lotes
+
+CREATE TABLE IF NOT EXISTS usage_events (
+    id              BIGSERIAL PRIMARY KEY,
+    team_id         BIGINT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    meter           TEXT NOT NULL,
+    quantity        BIGINT NOT NULL,
+    occurred_at     TIMESTAMPTZ NOT NULL,
+    idempotency_key TEXT NOT NULL UNIQUE,
+    status          INTEGER NOT NULL DEFAULT 0,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    reported_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS usage_events_status_next_attempt_at_idx ON usage_events (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS usage_events_team_id_occurred_at_idx ON usage_events (team_id, occurred_at);
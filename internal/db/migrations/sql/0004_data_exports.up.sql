@@ -0,0 +1,17 @@
+-- Exportações de dados pessoais (LGPD)
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users (id),
+    status       INTEGER NOT NULL DEFAULT 0,
+    include_csv  BOOLEAN NOT NULL DEFAULT FALSE,
+    file_path    TEXT,
+    error        TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at   TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_user_id_idx ON data_exports (user_id);
+CREATE INDEX IF NOT EXISTS data_exports_status_idx ON data_exports (status);
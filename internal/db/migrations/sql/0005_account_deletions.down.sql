@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_tombstones;
+DROP TABLE IF EXISTS account_deletions;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS sessions_revoked_at;
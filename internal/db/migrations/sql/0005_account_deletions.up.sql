@@ -0,0 +1,32 @@
+-- Exclusão de conta com prazo de arrependimento, revogação de sessões e registro mínimo do titular excluído
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deleted_at          TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL REFERENCES users (id),
+    status          INTEGER NOT NULL DEFAULT 0,
+    team_policy     TEXT NOT NULL,
+    requested_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    scheduled_for   TIMESTAMPTZ NOT NULL,
+    canceled_at     TIMESTAMPTZ,
+    completed_at    TIMESTAMPTZ,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS account_deletions_user_id_idx ON account_deletions (user_id);
+CREATE INDEX IF NOT EXISTS account_deletions_status_scheduled_for_idx ON account_deletions (status, scheduled_for);
+
+CREATE TABLE IF NOT EXISTS user_tombstones (
+    user_id           BIGINT PRIMARY KEY REFERENCES users (id),
+    identity_hash     TEXT NOT NULL,
+    team_policy       TEXT NOT NULL,
+    teams_transferred INTEGER NOT NULL DEFAULT 0,
+    teams_dissolved   INTEGER NOT NULL DEFAULT 0,
+    requested_at      TIMESTAMPTZ NOT NULL,
+    deleted_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_consents;
+DROP TABLE IF EXISTS terms_versions;
@@ -0,0 +1,24 @@
+-- Versões dos termos e histórico de consentimentos
+
+CREATE TABLE IF NOT EXISTS terms_versions (
+    id           BIGSERIAL PRIMARY KEY,
+    purpose      INTEGER NOT NULL,
+    version      TEXT NOT NULL,
+    content_url  TEXT NOT NULL,
+    summary      TEXT,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (purpose, version)
+);
+
+CREATE TABLE IF NOT EXISTS user_consents (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT NOT NULL REFERENCES users (id),
+    purpose          INTEGER NOT NULL,
+    terms_version_id BIGINT REFERENCES terms_versions (id),
+    granted          BOOLEAN NOT NULL,
+    ip               TEXT,
+    user_agent       TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_consents_user_id_purpose_idx ON user_consents (user_id, purpose, created_at DESC);
@@ -0,0 +1,7 @@
+-- Os valores continuam cifrados em cpf_cnpj: só desfaça depois de descriptografá-los
+DROP INDEX IF EXISTS users_cpf_cnpj_key_id_idx;
+DROP INDEX IF EXISTS users_cpf_cnpj_index_key;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS cpf_cnpj_key_id,
+    DROP COLUMN IF EXISTS cpf_cnpj_index;
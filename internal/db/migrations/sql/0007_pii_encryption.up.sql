@@ -0,0 +1,10 @@
+-- CPF/CNPJ criptografado: cpf_cnpj guarda o texto cifrado, cpf_cnpj_index o índice cego das buscas exatas
+-- e cpf_cnpj_key_id a chave mestra usada (o job de rotação procura as gravadas com chaves antigas)
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS cpf_cnpj_index  TEXT,
+    ADD COLUMN IF NOT EXISTS cpf_cnpj_key_id TEXT;
+
+-- Nome usado em services.cpfCnpjConflict para identificar documento duplicado
+CREATE UNIQUE INDEX IF NOT EXISTS users_cpf_cnpj_index_key ON users (cpf_cnpj_index);
+CREATE INDEX IF NOT EXISTS users_cpf_cnpj_key_id_idx ON users (cpf_cnpj_key_id);
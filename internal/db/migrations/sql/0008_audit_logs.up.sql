@@ -0,0 +1,35 @@
+-- Log de auditoria encadeado por hash. before/after são JSON (e não JSONB) para manter os bytes
+-- exatamente como foram usados no cálculo do hash
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          BIGSERIAL PRIMARY KEY,
+    actor_id    BIGINT,
+    action      TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id   TEXT NOT NULL,
+    team_id     BIGINT,
+    user_id     BIGINT,
+    before      JSON,
+    after       JSON,
+    ip          TEXT,
+    request_id  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL,
+    prev_hash   TEXT NOT NULL,
+    hash        TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_logs_team_id_idx ON audit_logs (team_id, id DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_user_id_idx ON audit_logs (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_actor_id_idx ON audit_logs (actor_id, id DESC);
+
+-- Somente inclusão: alterar ou apagar entradas é rejeitado pelo banco
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+CREATE TRIGGER audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
@@ -0,0 +1,5 @@
+-- Administrador da plataforma (rotas /admin e listagens globais), independente dos papéis nas equipes.
+-- Os usuários de ADMIN_USER_IDS são promovidos ao subir a API
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Início da inadimplência. A carência e os lembretes de cobrança contam a partir daqui, e não do
+-- current_period_end, que o Stripe já avança para o fim do novo período quando a renovação falha
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS past_due_since TIMESTAMPTZ;
+
+-- Assinaturas já inadimplentes mantêm a contagem antiga, a partir do fim do período
+UPDATE subscriptions SET past_due_since = current_period_end
+WHERE status IN (4, 5) AND past_due_since IS NULL;
@@ -0,0 +1,12 @@
+-- Início do período pago, vindo do provedor. O consumo medido é somado entre ele e current_period_end,
+-- o que vale para planos de qualquer intervalo (mensal, anual...)
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS current_period_start TIMESTAMPTZ;
+
+-- Assinaturas existentes eram todas mensais; a reconciliação corrige as que não forem
+UPDATE subscriptions SET current_period_start = current_period_end - INTERVAL '1 month'
+WHERE current_period_start IS NULL;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN current_period_start SET NOT NULL;
@@ -0,0 +1,12 @@
+package enums
+
+// Transições de cobrança registradas no histórico da assinatura
+type BillingEvent int
+
+const (
+	TRIAL_STARTED BillingEvent = iota
+	TRIAL_REMINDER_SENT
+	TRIAL_ENDED
+	PAYMENT_REMINDER_SENT
+	GRACE_ENDED
+)
@@ -0,0 +1,10 @@
+package enums
+
+// Finalidade de um consentimento do usuário
+type ConsentPurpose int
+
+const (
+	CONSENT_TERMS ConsentPurpose = iota
+	CONSENT_MARKETING
+	CONSENT_DATA_PROCESSING
+)
@@ -0,0 +1,11 @@
+package enums
+
+// Situação de um pedido de exclusão de conta
+type DeletionStatus int
+
+const (
+	// Aguardando o fim do prazo de arrependimento
+	DELETION_SCHEDULED DeletionStatus = iota
+	DELETION_CANCELED
+	DELETION_COMPLETED
+)
@@ -0,0 +1,10 @@
+package enums
+
+// Tipo do documento em cpf_cnpj
+type DocumentType int
+
+const (
+	DOCUMENT_NONE DocumentType = iota
+	DOCUMENT_CPF
+	DOCUMENT_CNPJ
+)
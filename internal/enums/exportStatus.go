@@ -0,0 +1,13 @@
+package enums
+
+// Situação de uma exportação de dados pessoais (LGPD)
+type ExportStatus int
+
+const (
+	EXPORT_PENDING ExportStatus = iota
+	EXPORT_PROCESSING
+	EXPORT_READY
+	EXPORT_FAILED
+	// O arquivo passou da validade e foi apagado
+	EXPORT_EXPIRED
+)
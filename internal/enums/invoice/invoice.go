@@ -0,0 +1,14 @@
+package invoice
+
+type Invoice int
+
+const (
+	UNKNOWN Invoice = iota
+	DRAFT
+	OPEN
+	PAID
+	UNCOLLECTIBLE
+	VOID
+	REFUNDED
+	PARTIALLY_REFUNDED
+)
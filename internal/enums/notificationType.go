@@ -5,4 +5,10 @@ type NotificationType int
 const (
 	JOIN_REQUEST NotificationType = iota
 	NOTIFICATION
+	PAYMENT_FAILED
+	TRIAL_WILL_END
+	TRIAL_EXPIRED
+	PAYMENT_REMINDER
+	GRACE_PERIOD_ENDED
+	DATA_EXPORT_READY
 )
@@ -0,0 +1,9 @@
+package enums
+
+type TeamAccess int
+
+const (
+	FULL_ACCESS TeamAccess = iota
+	GRACE_PERIOD
+	READ_ONLY
+)
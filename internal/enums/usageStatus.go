@@ -0,0 +1,14 @@
+package enums
+
+// Situação do envio de um evento de uso ao provedor
+type UsageStatus int
+
+const (
+	USAGE_PENDING UsageStatus = iota
+	USAGE_REPORTED
+	// A equipe não tem assinatura medida no Stripe; o evento só conta para o consumo local
+	USAGE_SKIPPED
+	USAGE_FAILED
+	// O período de cobrança em que o evento ocorreu já fechou no Stripe; conta só para o consumo local
+	USAGE_EXPIRED
+)
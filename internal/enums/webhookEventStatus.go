@@ -0,0 +1,9 @@
+package enums
+
+type WebhookEventStatus int
+
+const (
+	EVENT_PENDING WebhookEventStatus = iota
+	EVENT_PROCESSED
+	EVENT_FAILED
+)
@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"time"
+)
+
+const deletionBatchSize = 10
+
+// Executa as exclusões de conta cujo prazo de arrependimento terminou
+func ProcessDeletions(ctx context.Context, s services.Services) {
+	Run(ctx, "account-deletions", 10*time.Minute, func(ctx context.Context) error {
+		for {
+			claimed, err := s.Deletions.ProcessDue(ctx, deletionBatchSize)
+			if err != nil || claimed < deletionBatchSize || stopping(ctx) {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const rotationBatchSize = 200
+
+// Regrava os dados pessoais ainda cifrados com chaves mestras antigas usando a chave ativa
+func RotateEncryptionKeys(ctx context.Context, s services.Services, interval time.Duration) {
+	Run(ctx, "pii-key-rotation", interval, func(ctx context.Context) error {
+		total := 0
+		defer func() {
+			if total > 0 {
+				slog.InfoContext(ctx, "pii records moved to the active key", "count", total)
+			}
+		}()
+
+		// Percorre a tabela pelo id, para que registros com falha não travem os lotes seguintes
+		var afterID uint64
+		for {
+			batch, err := s.Users.RotateEncryptionKeys(ctx, afterID, rotationBatchSize)
+			total += batch.Rotated
+			if err != nil || batch.Scanned < rotationBatchSize || stopping(ctx) {
+				return err
+			}
+			afterID = batch.LastID
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const exportBatchSize = 5
+
+// Gera as exportações de dados na fila e apaga os arquivos vencidos
+func ProcessExports(ctx context.Context, s services.Services) {
+	Run(ctx, "data-exports", 30*time.Second, func(ctx context.Context) error {
+		purged, err := s.Exports.PurgeExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			slog.InfoContext(ctx, "expired data exports removed", "count", purged)
+		}
+
+		for {
+			claimed, err := s.Exports.ProcessPending(ctx, exportBatchSize)
+			if err != nil || claimed < exportBatchSize || stopping(ctx) {
+				return err
+			}
+		}
+	})
+}
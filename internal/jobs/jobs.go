@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"HareID/internal/tracing"
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+type stopKey struct{}
+
+// Executa a tarefa a cada intervalo até o contexto ser cancelado. A execução em andamento não recebe o
+// cancelamento: ela termina o lote atual (e suas transações) e consulta stopping antes de pegar o próximo
+func Run(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	jobCtx := context.WithValue(context.WithoutCancel(ctx), stopKey{}, ctx.Done())
+
+	for {
+		runOnce(jobCtx, name, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cada execução é um trace próprio, com os spans dos services e do banco abaixo dele
+func runOnce(ctx context.Context, name string, job func(ctx context.Context) error) {
+	ctx, span := tracing.Start(ctx, "job "+name)
+	defer span.End()
+
+	if err := job(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "job failed", "job", name, "error", err)
+	}
+}
+
+// Indica que o desligamento começou e o job não deve pegar mais lotes
+func stopping(ctx context.Context) bool {
+	done, _ := ctx.Value(stopKey{}).(<-chan struct{})
+
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
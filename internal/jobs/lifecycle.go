@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Aplica fim de teste, lembretes de pagamento e fim de carência
+func BillingLifecycle(ctx context.Context, s services.Services, interval time.Duration) {
+	Run(ctx, "billing-lifecycle", interval, func(ctx context.Context) error {
+		applied, err := s.Lifecycle.Run(ctx)
+		if err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			slog.InfoContext(ctx, "billing lifecycle transitions applied", "count", applied)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Concilia periodicamente as assinaturas locais com o Stripe
+func Reconcile(ctx context.Context, s services.Services, interval time.Duration, fix bool) {
+	Run(ctx, "stripe-reconcile", interval, func(ctx context.Context) error {
+		report, err := s.Reconciliation.Run(ctx, fix)
+		if err != nil {
+			return err
+		}
+
+		fixed := 0
+		for _, drift := range report.Drifts {
+			if drift.Fixed {
+				fixed++
+			}
+			slog.WarnContext(ctx, "reconcile drift", "kind", drift.Kind, "id", drift.ID, "field", drift.Field,
+				"local", drift.Local, "stripe", drift.Remote, "fixed", drift.Fixed, "error", drift.Error)
+		}
+
+		slog.InfoContext(ctx, "reconcile finished", "subscriptions", report.SubscriptionsChecked,
+			"customers", report.CustomersChecked, "drifts", len(report.Drifts), "fixed", fixed)
+
+		return nil
+	})
+}
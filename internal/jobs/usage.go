@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"time"
+)
+
+const usageBatchSize = 500
+
+// Envia o consumo pendente ao Stripe até esvaziar a fila e aguarda o próximo intervalo
+func ReportUsage(ctx context.Context, s services.Services, interval time.Duration) {
+	Run(ctx, "usage-report", interval, func(ctx context.Context) error {
+		for {
+			claimed, err := s.Usage.ReportPending(ctx, usageBatchSize)
+			if err != nil || claimed < usageBatchSize || stopping(ctx) {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"HareID/internal/services"
+	"context"
+	"time"
+)
+
+const webhookBatchSize = 50
+
+// Consome a fila de eventos do Stripe até esvaziar e aguarda o próximo intervalo
+func ProcessWebhooks(ctx context.Context, s services.Services) {
+	Run(ctx, "stripe-webhooks", 2*time.Second, func(ctx context.Context) error {
+		for {
+			processed, err := s.Webhooks.ProcessPending(ctx, webhookBatchSize)
+			if err != nil || processed < webhookBatchSize || stopping(ctx) {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package listquery
+
+import (
+	"HareID/internal/apperrors"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var errInvalidToken = apperrors.Field("page_token", "is invalid or was issued for a different sort or filter")
+
+// Página de uma listagem. Sem next_page_token não há mais itens
+type Page[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+	// Preenchido só com include_total=true
+	Total *int64 `json:"total,omitempty"`
+}
+
+// Conteúdo do page_token: os valores do cursor e a assinatura da ordenação e dos filtros
+type token struct {
+	Fingerprint string   `json:"f"`
+	Values      []string `json:"v"`
+}
+
+func encodeToken(fingerprint string, values []string) string {
+	payload, _ := json.Marshal(token{Fingerprint: hashFingerprint(fingerprint), Values: values})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeToken(encoded, fingerprint string) ([]string, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	var t token
+	if err := json.Unmarshal(payload, &t); err != nil || t.Fingerprint != hashFingerprint(fingerprint) {
+		return nil, errInvalidToken
+	}
+
+	return t.Values, nil
+}
+
+func hashFingerprint(fingerprint string) string {
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// Monta a página com as linhas lidas por SQL (limite + 1). A linha extra só indica que há próxima página
+func (q *Query[T]) Page(items []T) Page[T] {
+	page := Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	if len(items) > q.params.Limit {
+		page.Items = items[:q.params.Limit]
+
+		last := page.Items[len(page.Items)-1]
+		fields := q.cursorFields()
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			values[i] = formatValue(field.Value(last))
+		}
+		page.NextPageToken = encodeToken(q.fingerprint(), values)
+	}
+
+	return page
+}
+
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Executa a listagem sobre a consulta base (ver Query.SQL), lendo cada linha com scan, e conta o total quando pedido
+func Fetch[T any](ctx context.Context, db Querier, resource Resource[T], params Params, base string, args []any, scan func(pgx.Row) (T, error)) (Page[T], error) {
+	q, err := resource.Query(params)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	query, queryArgs := q.SQL(base, args...)
+
+	rows, err := db.Query(ctx, query, queryArgs...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	page := q.Page(items)
+
+	if params.WithTotal {
+		var total int64
+		countQuery, countArgs := q.CountSQL(base, args...)
+		if err := db.QueryRow(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+			return Page[T]{}, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// Percorre todas as páginas. Para rotinas internas que precisam da lista completa (jobs, checagens de membros)
+func All[T any](ctx context.Context, fetch func(ctx context.Context, params Params) (Page[T], error)) ([]T, error) {
+	params := Params{Limit: MaxLimit}
+
+	var items []T
+	for {
+		page, err := fetch(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+
+		if page.NextPageToken == "" {
+			return items, nil
+		}
+		params.PageToken = page.NextPageToken
+	}
+}
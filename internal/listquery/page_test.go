@@ -0,0 +1,131 @@
+package listquery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 10, 12, 30, 0, 500, time.UTC)
+	rows := []item{{ID: 1}, {ID: 2}, {ID: 3, CreatedAt: createdAt}, {ID: 4}}
+
+	tests := []struct {
+		name      string
+		params    Params
+		wantAfter []any
+		wantSQL   string
+	}{
+		{"sort by key", Params{Limit: 3},
+			[]any{int64(3)},
+			base + ` AND (id) > ($2) ORDER BY id ASC LIMIT $3`},
+		{"sort by date", Params{Limit: 3, Sort: "-created_at"},
+			[]any{createdAt, int64(3)},
+			base + ` AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4`},
+		{"with filter", Params{Limit: 3, Filters: []Filter{{"active", Eq, "true"}}},
+			[]any{int64(3)},
+			base + ` AND active = $2 AND (id) > ($3) ORDER BY id ASC LIMIT $4`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := items.Query(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page := first.Page(rows)
+			if len(page.Items) != 3 || page.NextPageToken == "" {
+				t.Fatalf("page = %+v, want 3 items and a next page", page)
+			}
+
+			next := tt.params
+			next.PageToken = page.NextPageToken
+
+			q, err := items.Query(next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.after, tt.wantAfter) {
+				t.Errorf("cursor = %#v, want %#v", q.after, tt.wantAfter)
+			}
+
+			if sql, _ := q.SQL(base, 7); sql != tt.wantSQL {
+				t.Errorf("SQL =\n%s\nwant\n%s", sql, tt.wantSQL)
+			}
+		})
+	}
+}
+
+func TestPageTokenBoundToQuery(t *testing.T) {
+	first, err := items.Query(Params{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := first.Page([]item{{ID: 1}, {ID: 2}}).NextPageToken
+
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"other sort", Params{Sort: "-id"}},
+		{"other filter", Params{Filters: []Filter{{"active", Eq, "true"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Limit = 1
+			tt.params.PageToken = token
+			if _, err := items.Query(tt.params); err != errInvalidToken {
+				t.Fatalf("err = %v, want %v", err, errInvalidToken)
+			}
+		})
+	}
+}
+
+func TestPageWithoutNextPage(t *testing.T) {
+	q, err := items.Query(Params{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		rows []item
+	}{
+		{"empty", nil},
+		{"exactly the limit", []item{{ID: 1}, {ID: 2}, {ID: 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := q.Page(tt.rows)
+			if page.Items == nil || len(page.Items) != len(tt.rows) || page.NextPageToken != "" {
+				t.Errorf("page = %+v", page)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	pages := map[string]Page[int]{
+		"":  {Items: []int{1, 2}, NextPageToken: "a"},
+		"a": {Items: []int{3, 4}, NextPageToken: "b"},
+		"b": {Items: []int{5}},
+	}
+
+	got, err := All(context.Background(), func(ctx context.Context, params Params) (Page[int], error) {
+		if params.Limit != MaxLimit {
+			t.Errorf("limit = %d, want %d", params.Limit, MaxLimit)
+		}
+		return pages[params.PageToken], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("All = %v", got)
+	}
+}
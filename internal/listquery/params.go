@@ -0,0 +1,119 @@
+package listquery
+
+import (
+	"HareID/internal/apperrors"
+	"cmp"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Operadores dos filtros: campo=valor (eq) ou campo[op]=valor
+type Operator string
+
+const (
+	Eq  Operator = "eq"
+	Ne  Operator = "ne"
+	Gt  Operator = "gt"
+	Gte Operator = "gte"
+	Lt  Operator = "lt"
+	Lte Operator = "lte"
+	// Busca parcial sem diferenciar maiúsculas, só em campos de texto
+	Contains Operator = "contains"
+)
+
+var operatorSQL = map[Operator]string{
+	Eq:  "=",
+	Ne:  "<>",
+	Gt:  ">",
+	Gte: ">=",
+	Lt:  "<",
+	Lte: "<=",
+}
+
+type Filter struct {
+	Field    string
+	Operator Operator
+	Value    string
+}
+
+// Parâmetros de uma listagem lidos da query string. Os campos de sort e dos filtros só são
+// conferidos contra o recurso (Resource) no repositório
+type Params struct {
+	Limit     int
+	PageToken string
+	// Um único campo, com "-" para ordem decrescente (ex: "-created_at")
+	Sort    string
+	Filters []Filter
+	// Conta o total de itens com os filtros aplicados (include_total=true). Custa uma consulta a mais
+	WithTotal bool
+}
+
+// Lê limit, page_token, sort e include_total. Os demais parâmetros viram filtros
+func Parse(values url.Values) (Params, error) {
+	params := Params{
+		Limit:     DefaultLimit,
+		PageToken: values.Get("page_token"),
+		Sort:      values.Get("sort"),
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return Params{}, apperrors.Field("limit", "must be between 1 and "+strconv.Itoa(MaxLimit))
+		}
+		params.Limit = limit
+	}
+
+	if value := values.Get("include_total"); value != "" {
+		withTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			return Params{}, apperrors.Field("include_total", "must be true or false")
+		}
+		params.WithTotal = withTotal
+	}
+
+	for key, list := range values {
+		switch key {
+		case "limit", "page_token", "sort", "include_total":
+			continue
+		}
+
+		field, operator, err := parseFilterKey(key)
+		if err != nil {
+			return Params{}, err
+		}
+
+		for _, value := range list {
+			params.Filters = append(params.Filters, Filter{Field: field, Operator: operator, Value: value})
+		}
+	}
+
+	// A ordem do mapa é aleatória; ordenados, os mesmos filtros geram sempre o mesmo SQL e o mesmo page_token
+	slices.SortFunc(params.Filters, func(a, b Filter) int {
+		return cmp.Or(cmp.Compare(a.Field, b.Field), cmp.Compare(a.Operator, b.Operator), cmp.Compare(a.Value, b.Value))
+	})
+
+	return params, nil
+}
+
+// "status" vira (status, eq); "created_at[gte]" vira (created_at, gte)
+func parseFilterKey(key string) (string, Operator, error) {
+	field, rest, found := strings.Cut(key, "[")
+	if !found {
+		return key, Eq, nil
+	}
+
+	operator := Operator(strings.TrimSuffix(rest, "]"))
+	if _, ok := operatorSQL[operator]; (!ok && operator != Contains) || !strings.HasSuffix(rest, "]") {
+		return "", "", apperrors.Field(key, "unknown operator, use eq, ne, gt, gte, lt, lte or contains")
+	}
+
+	return field, operator, nil
+}
@@ -0,0 +1,52 @@
+package listquery
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{"defaults", "", Params{Limit: DefaultLimit}, false},
+		{"paging and sort", "limit=10&page_token=abc&sort=-created_at&include_total=true",
+			Params{Limit: 10, PageToken: "abc", Sort: "-created_at", WithTotal: true}, false},
+		{"equality filter", "status=1", Params{Limit: DefaultLimit, Filters: []Filter{{"status", Eq, "1"}}}, false},
+		{"operator filters are sorted", "name[contains]=ana&created_at[lt]=2026-02-01T00:00:00Z&created_at[gte]=2026-01-01T00:00:00Z",
+			Params{Limit: DefaultLimit, Filters: []Filter{
+				{"created_at", Gte, "2026-01-01T00:00:00Z"},
+				{"created_at", Lt, "2026-02-01T00:00:00Z"},
+				{"name", Contains, "ana"},
+			}}, false},
+		{"repeated filter", "status=2&status=1", Params{Limit: DefaultLimit, Filters: []Filter{{"status", Eq, "1"}, {"status", Eq, "2"}}}, false},
+		{"max limit", "limit=200", Params{Limit: MaxLimit}, false},
+		{"limit zero", "limit=0", Params{}, true},
+		{"limit above max", "limit=201", Params{}, true},
+		{"limit not a number", "limit=ten", Params{}, true},
+		{"include_total not a bool", "include_total=maybe", Params{}, true},
+		{"unknown operator", "created_at[like]=x", Params{}, true},
+		{"unclosed operator", "created_at[gte=x", Params{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Parse(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
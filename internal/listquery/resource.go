@@ -0,0 +1,264 @@
+package listquery
+
+import (
+	"HareID/internal/apperrors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tipo do campo, usado para converter os valores dos filtros e do cursor
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Bool
+	// RFC 3339 (ex: 2025-01-31T12:00:00Z)
+	Time
+)
+
+// Campo exposto por uma listagem
+type Field[T any] struct {
+	// Coluna ou expressão SQL, com o alias da tabela quando a consulta tem JOIN (ex: "tm.created_at")
+	Column     string
+	Type       Type
+	Filterable bool
+	// Só colunas NOT NULL: o cursor compara o valor da última linha da página
+	Sortable bool
+	// Valor do campo no item, para montar o cursor. Obrigatório nos campos ordenáveis e na chave
+	Value func(T) any
+}
+
+// Campos que uma listagem aceita em sort e nos filtros. Declarado uma vez por repositório
+type Resource[T any] struct {
+	Fields map[string]Field[T]
+	// Campo único (ex: "id") usado no desempate da ordenação e no cursor
+	Key string
+	// Ordenação quando o cliente não envia sort (ex: "-created_at")
+	DefaultSort string
+}
+
+type condition struct {
+	column   string
+	operator string
+	value    any
+}
+
+// Listagem validada contra o recurso, pronta para gerar o SQL
+type Query[T any] struct {
+	resource   Resource[T]
+	params     Params
+	sortField  string
+	descending bool
+	conditions []condition
+	// Valores de sortField e da chave na última linha da página anterior
+	after []any
+}
+
+func (r Resource[T]) Query(params Params) (*Query[T], error) {
+	q := &Query[T]{resource: r, params: params}
+
+	sort := params.Sort
+	if sort == "" {
+		sort = r.DefaultSort
+	}
+	q.sortField = strings.TrimPrefix(sort, "-")
+	q.descending = strings.HasPrefix(sort, "-")
+
+	if field, ok := r.Fields[q.sortField]; !ok || !field.Sortable {
+		return nil, apperrors.Field("sort", "must be one of: "+strings.Join(r.names(func(f Field[T]) bool { return f.Sortable }), ", "))
+	}
+
+	for _, filter := range params.Filters {
+		field, ok := r.Fields[filter.Field]
+		if !ok || !field.Filterable {
+			return nil, apperrors.Field(filter.Field, "is not a filter of this list, use one of: "+
+				strings.Join(r.names(func(f Field[T]) bool { return f.Filterable }), ", "))
+		}
+
+		c, err := filterCondition(field, filter)
+		if err != nil {
+			return nil, err
+		}
+		q.conditions = append(q.conditions, c)
+	}
+
+	if params.PageToken != "" {
+		after, err := decodeToken(params.PageToken, q.fingerprint())
+		if err != nil {
+			return nil, err
+		}
+
+		fields := q.cursorFields()
+		if len(after) != len(fields) {
+			return nil, errInvalidToken
+		}
+		for i, field := range fields {
+			value, err := parseValue(field.Type, after[i])
+			if err != nil {
+				return nil, errInvalidToken
+			}
+			q.after = append(q.after, value)
+		}
+	}
+
+	return q, nil
+}
+
+// Campos do recurso que atendem ao critério, em ordem alfabética para a mensagem de erro
+func (r Resource[T]) names(match func(Field[T]) bool) []string {
+	var names []string
+	for name, field := range r.Fields {
+		if match(field) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func filterCondition[T any](field Field[T], filter Filter) (condition, error) {
+	if filter.Operator == Contains {
+		if field.Type != String {
+			return condition{}, apperrors.Field(filter.Field, "contains only applies to text fields")
+		}
+		// % e _ digitados pelo cliente são literais
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.Value)
+		return condition{column: field.Column, operator: "ILIKE", value: "%" + escaped + "%"}, nil
+	}
+
+	if field.Type == Bool && filter.Operator != Eq && filter.Operator != Ne {
+		return condition{}, apperrors.Field(filter.Field, "only accepts eq and ne")
+	}
+
+	value, err := parseValue(field.Type, filter.Value)
+	if err != nil {
+		return condition{}, apperrors.Field(filter.Field, err.Error())
+	}
+
+	return condition{column: field.Column, operator: operatorSQL[filter.Operator], value: value}, nil
+}
+
+func parseValue(fieldType Type, value string) (any, error) {
+	switch fieldType {
+	case Int:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return parsed, nil
+	case Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return parsed, nil
+	case Time:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 date-time")
+		}
+		return parsed, nil
+	default:
+		return value, nil
+	}
+}
+
+// Texto do valor para o cursor. Inteiros passam por reflect porque os enums têm String()
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch {
+	case reflected.CanInt():
+		return strconv.FormatInt(reflected.Int(), 10)
+	case reflected.CanUint():
+		return strconv.FormatUint(reflected.Uint(), 10)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Campo de ordenação seguido da chave, que desempata linhas com o mesmo valor
+func (q *Query[T]) cursorFields() []Field[T] {
+	if q.sortField == q.resource.Key {
+		return []Field[T]{q.resource.Fields[q.resource.Key]}
+	}
+	return []Field[T]{q.resource.Fields[q.sortField], q.resource.Fields[q.resource.Key]}
+}
+
+// Completa a consulta base com os filtros, o cursor, a ordenação e o limite. A base já termina em uma
+// cláusula WHERE (use "WHERE TRUE" quando não houver condição) e args são os argumentos dela.
+// Busca uma linha a mais que o limite para saber se há próxima página
+func (q *Query[T]) SQL(base string, args ...any) (string, []any) {
+	query, args := q.where(base, args)
+
+	fields := q.cursorFields()
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.Column
+	}
+
+	direction, comparison := "ASC", ">"
+	if q.descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if q.after != nil {
+		placeholders := make([]string, len(q.after))
+		for i, value := range q.after {
+			args = append(args, value)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += ` AND (` + strings.Join(columns, ", ") + `) ` + comparison + ` (` + strings.Join(placeholders, ", ") + `)`
+	}
+
+	query += ` ORDER BY ` + strings.Join(columns, " "+direction+", ") + ` ` + direction
+
+	args = append(args, q.params.Limit+1)
+	query += ` LIMIT $` + strconv.Itoa(len(args))
+
+	return query, args
+}
+
+// Conta as linhas da consulta base com os filtros, sem cursor nem limite
+func (q *Query[T]) CountSQL(base string, args ...any) (string, []any) {
+	query, args := q.where(base, args)
+	return `SELECT COUNT(*) FROM (` + query + `) AS list`, args
+}
+
+func (q *Query[T]) where(base string, args []any) (string, []any) {
+	query := base
+	args = slices.Clone(args)
+
+	for _, c := range q.conditions {
+		args = append(args, c.value)
+		query += ` AND ` + c.column + ` ` + c.operator + ` $` + strconv.Itoa(len(args))
+	}
+
+	return query, args
+}
+
+// Identifica a ordenação e os filtros da listagem. Um page_token só vale para a mesma combinação
+func (q *Query[T]) fingerprint() string {
+	var b strings.Builder
+	b.WriteString(q.sortField)
+	if q.descending {
+		b.WriteString(" desc")
+	}
+	for _, filter := range q.params.Filters {
+		fmt.Fprintf(&b, "|%s %s %s", filter.Field, filter.Operator, filter.Value)
+	}
+	return b.String()
+}
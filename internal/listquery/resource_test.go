@@ -0,0 +1,111 @@
+package listquery
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type item struct {
+	ID        uint64
+	Name      string
+	Active    bool
+	CreatedAt time.Time
+}
+
+var items = Resource[item]{
+	Key:         "id",
+	DefaultSort: "id",
+	Fields: map[string]Field[item]{
+		"id":         {Column: "id", Type: Int, Filterable: true, Sortable: true, Value: func(i item) any { return i.ID }},
+		"name":       {Column: "name", Type: String, Filterable: true},
+		"active":     {Column: "active", Type: Bool, Filterable: true},
+		"created_at": {Column: "created_at", Type: Time, Filterable: true, Sortable: true, Value: func(i item) any { return i.CreatedAt }},
+	},
+}
+
+const base = `SELECT id, name, active, created_at FROM items WHERE owner_id = $1`
+
+func TestQuerySQL(t *testing.T) {
+	january := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		params   Params
+		wantSQL  string
+		wantArgs []any
+	}{
+		{"default sort", Params{Limit: 10},
+			base + ` ORDER BY id ASC LIMIT $2`,
+			[]any{7, 11}},
+		{"descending sort with tiebreak", Params{Limit: 10, Sort: "-created_at"},
+			base + ` ORDER BY created_at DESC, id DESC LIMIT $2`,
+			[]any{7, 11}},
+		{"typed filters", Params{Limit: 5, Filters: []Filter{
+			{"active", Eq, "true"},
+			{"created_at", Gte, "2026-01-01T00:00:00Z"},
+			{"id", Ne, "3"},
+		}},
+			base + ` AND active = $2 AND created_at >= $3 AND id <> $4 ORDER BY id ASC LIMIT $5`,
+			[]any{7, true, january, int64(3), 6}},
+		{"contains escapes wildcards", Params{Limit: 5, Filters: []Filter{{"name", Contains, `50%_off\`}}},
+			base + ` AND name ILIKE $2 ORDER BY id ASC LIMIT $3`,
+			[]any{7, `%50\%\_off\\%`, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := items.Query(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql, args := q.SQL(base, 7)
+			if sql != tt.wantSQL {
+				t.Errorf("SQL =\n%s\nwant\n%s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestQueryValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"unknown sort", Params{Sort: "email"}},
+		{"sort on a field that is not sortable", Params{Sort: "name"}},
+		{"unknown filter", Params{Filters: []Filter{{"email", Eq, "x"}}}},
+		{"integer filter with text", Params{Filters: []Filter{{"id", Eq, "abc"}}}},
+		{"invalid date", Params{Filters: []Filter{{"created_at", Gte, "yesterday"}}}},
+		{"contains on a number", Params{Filters: []Filter{{"id", Contains, "1"}}}},
+		{"range on a bool", Params{Filters: []Filter{{"active", Gt, "true"}}}},
+		{"malformed page token", Params{PageToken: "not-base64!"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Limit = DefaultLimit
+			if _, err := items.Query(tt.params); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestQueryCountSQL(t *testing.T) {
+	q, err := items.Query(Params{Limit: 10, Filters: []Filter{{"active", Eq, "false"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args := q.CountSQL(base, 7)
+
+	wantSQL := `SELECT COUNT(*) FROM (` + base + ` AND active = $2) AS list`
+	if sql != wantSQL || !reflect.DeepEqual(args, []any{7, false}) {
+		t.Errorf("CountSQL = %s %v", sql, args)
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"HareID/config"
+	"HareID/internal/audit"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// Atributos cujo valor nunca vai para o log, qualquer que seja o conteúdo
+var sensitiveKeys = map[string]bool{
+	"authorization":    true,
+	"cookie":           true,
+	"token":            true,
+	"consent_token":    true,
+	"access_token":     true,
+	"password":         true,
+	"secret":           true,
+	"api_key":          true,
+	"signature":        true,
+	"cpf_cnpj":         true,
+	"cpf":              true,
+	"cnpj":             true,
+	"x-internal-token": true,
+}
+
+// Padrões apagados de qualquer texto: tokens JWT/Bearer, CPF e CNPJ (inclusive alfanumérico), com ou sem pontuação
+var sensitivePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)bearer\s+[\w\-.~+/=]+`),
+	regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]+`),
+	regexp.MustCompile(`\b\d{3}\.\d{3}\.\d{3}-\d{2}\b|\b\d{11}\b`),
+	regexp.MustCompile(`\b[A-Z0-9]{2}\.[A-Z0-9]{3}\.[A-Z0-9]{3}/[A-Z0-9]{4}-\d{2}\b|\b[A-Z0-9]{12}\d{2}\b`),
+}
+
+// Cria o logger da aplicação: JSON (produção) ou texto, com request_id das requisições e dados sensíveis apagados
+func New(w io.Writer, cfg config.Logging) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       cfg.Level,
+		ReplaceAttr: redact,
+	}
+
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// Apaga tokens e documentos antes de o atributo ser escrito. Vale também para a mensagem e os erros
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+
+	return attr
+}
+
+// Apaga os padrões sensíveis de um texto livre
+func Redact(text string) string {
+	for _, pattern := range sensitivePatterns {
+		text = pattern.ReplaceAllString(text, redacted)
+	}
+	return text
+}
+
+// Acrescenta o request_id da requisição HTTP e o trace_id/span_id do span atual aos logs feitos com o
+// contexto (slog.InfoContext etc.)
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := audit.FromContext(ctx).RequestID; requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Métricas da aplicação expostas em /metrics. Os services registram eventos pelas funções deste pacote
+// e não importam o cliente do Prometheus
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hareid_http_requests_total",
+		Help: "Requisições HTTP por rota, método e status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hareid_http_request_duration_seconds",
+		Help:    "Latência das requisições HTTP por rota e método.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hareid_logins_total",
+		Help: "Tentativas de login por provedor e resultado (success ou failure).",
+	}, []string{"provider", "outcome"})
+
+	webhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hareid_webhook_events_total",
+		Help: "Eventos de webhook por provedor, tipo e resultado.",
+	}, []string{"provider", "type", "outcome"})
+
+	joinRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hareid_join_request_transitions_total",
+		Help: "Mudanças de status dos pedidos de entrada em equipes.",
+	}, []string{"from", "to"})
+
+	usageDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hareid_usage_events_dropped_total",
+		Help: "Eventos de uso não enviados ao provedor, por medidor e motivo.",
+	}, []string{"meter", "reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		logins,
+		webhookEvents,
+		joinRequests,
+		usageDropped,
+	)
+}
+
+// Handler do /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Registra uma requisição HTTP. route é o padrão da rota no chi (ex: /teams/{team_id}), para não criar
+// uma série por id
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+func Login(provider string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	logins.WithLabelValues(provider, outcome).Inc()
+}
+
+// Resultados: received, duplicate, rejected (assinatura inválida), processed, retry e failed
+func WebhookEvent(provider, eventType, outcome string) {
+	webhookEvents.WithLabelValues(provider, eventType, outcome).Inc()
+}
+
+func JoinRequestTransition(from, to string) {
+	joinRequests.WithLabelValues(from, to).Inc()
+}
+
+// Motivos: closed_period (o período de cobrança do evento já fechou)
+func UsageDropped(meter, reason string, count int) {
+	usageDropped.WithLabelValues(meter, reason).Add(float64(count))
+}
+
+// Expõe as estatísticas do pool do banco, lidas a cada coleta
+func RegisterPool(pool *pgxpool.Pool) {
+	registry.MustRegister(poolCollector{pool: pool})
+}
+
+// Expõe a quantidade de assinaturas por status. count é consultado a cada coleta
+func RegisterSubscriptions(count func(ctx context.Context) (map[string]uint64, error)) {
+	registry.MustRegister(subscriptionCollector{count: count})
+}
+
+var (
+	poolAcquired     = prometheus.NewDesc("hareid_db_pool_acquired_conns", "Conexões em uso.", nil, nil)
+	poolIdle         = prometheus.NewDesc("hareid_db_pool_idle_conns", "Conexões ociosas.", nil, nil)
+	poolTotal        = prometheus.NewDesc("hareid_db_pool_total_conns", "Conexões abertas.", nil, nil)
+	poolMax          = prometheus.NewDesc("hareid_db_pool_max_conns", "Limite de conexões do pool.", nil, nil)
+	poolAcquires     = prometheus.NewDesc("hareid_db_pool_acquires_total", "Conexões obtidas do pool.", nil, nil)
+	poolWaits        = prometheus.NewDesc("hareid_db_pool_empty_acquires_total", "Aquisições que esperaram por falta de conexão livre.", nil, nil)
+	poolWaitDuration = prometheus.NewDesc("hareid_db_pool_empty_acquire_wait_seconds_total", "Tempo total de espera por uma conexão livre.", nil, nil)
+)
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolWaits, poolWaitDuration} {
+		ch <- desc
+	}
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaits, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaitDuration, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+}
+
+var subscriptionsByStatus = prometheus.NewDesc("hareid_subscriptions", "Assinaturas por status.", []string{"status"}, nil)
+
+type subscriptionCollector struct {
+	count func(ctx context.Context) (map[string]uint64, error)
+}
+
+func (c subscriptionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- subscriptionsByStatus
+}
+
+func (c subscriptionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		slog.Error("error counting subscriptions for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(subscriptionsByStatus, err)
+		return
+	}
+
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(subscriptionsByStatus, prometheus.GaugeValue, float64(count), status)
+	}
+}
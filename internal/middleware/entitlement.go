@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/models"
+	"HareID/internal/responses"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Resolve os recursos liberados para o usuário. Registrado no main com SetEntitlementResolver
+type EntitlementResolver interface {
+	CheckFeature(ctx context.Context, userID uint64, feature string) (models.FeatureCheck, error)
+}
+
+var entitlements EntitlementResolver
+
+func SetEntitlementResolver(resolver EntitlementResolver) {
+	entitlements = resolver
+}
+
+// Autentica e só libera a rota se alguma assinatura do usuário (própria ou de equipe) incluir o recurso.
+// Caso contrário responde 402 com o motivo em "code"
+func RequireEntitlement(feature string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(request http.HandlerFunc) http.HandlerFunc {
+		return Authenticate(func(w http.ResponseWriter, r *http.Request) {
+			if entitlements == nil {
+				responses.Problem(w, r, errors.New("entitlement resolver not configured"))
+				return
+			}
+
+			requestUserID, _ := r.Context().Value(UserKey).(string)
+
+			userID, err := strconv.ParseUint(requestUserID, 10, 64)
+			if err != nil {
+				responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+				return
+			}
+
+			check, err := entitlements.CheckFeature(r.Context(), userID, feature)
+			if err != nil {
+				responses.Problem(w, r, err)
+				return
+			}
+
+			// O motivo (no_subscription, feature_not_in_plan, subscription_inactive) vira o código do erro
+			if !check.Allowed {
+				responses.Problem(w, r, apperrors.PaymentRequired(check.Reason, "your plan does not include "+feature))
+				return
+			}
+
+			request(w, r)
+		})
+	}
+}
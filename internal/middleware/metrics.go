@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"HareID/internal/metrics"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Conta as requisições e mede a latência pelo padrão da rota do chi, que só é conhecido depois do roteamento
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+
+		metrics.ObserveRequest(route, r.Method, recorder.status, time.Since(start))
+	})
+}
@@ -1,42 +1,163 @@
 package middleware
 
 import (
+	"HareID/config"
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
 	"HareID/internal/authentication"
 	"HareID/internal/responses"
 	"context"
+	"crypto/subtle"
 	"errors"
-	"fmt"
-	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type key uint64
 
+// Verifica se a sessão do token ainda vale. Registrado no main com SetSessionValidator
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID uint64, issuedAt time.Time) error
+}
+
+var sessions SessionValidator
+
+func SetSessionValidator(validator SessionValidator) {
+	sessions = validator
+}
+
+// Consulta se o usuário é administrador da plataforma. Registrado no main com SetAdminChecker
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID uint64) (bool, error)
+}
+
+var admins AdminChecker
+
+func SetAdminChecker(checker AdminChecker) {
+	admins = checker
+}
+
+var authConfig config.Auth
+
+// Registra o token interno. Chamado no main com a configuração carregada
+func SetAuthConfig(cfg config.Auth) {
+	authConfig = cfg
+}
+
 const UserKey key = 0
 
+// Token ausente, com assinatura inválida, vencido ou sem as claims esperadas
+var ErrInvalidToken = apperrors.Unauthenticated("invalid_token", "invalid or expired token")
+
+// A rota é restrita aos administradores da plataforma
+var ErrAdminOnly = apperrors.Forbidden("admin_only", "this route is restricted to administrators")
+
 func Authenticate(request http.HandlerFunc) http.HandlerFunc {
+	return authenticate(request, false)
+}
+
+// Aceita também o token restrito emitido no login enquanto há termos pendentes
+func AuthenticateConsent(request http.HandlerFunc) http.HandlerFunc {
+	return authenticate(request, true)
+}
+
+func authenticate(request http.HandlerFunc, allowConsentScope bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := authentication.ValidateToken(r); err != nil {
-			responses.Error(w, http.StatusUnauthorized, err)
+			responses.Problem(w, r, ErrInvalidToken.Wrap(err))
+			return
+		}
+
+		scope, err := authentication.GetTokenScope(r)
+		if err != nil {
+			responses.Problem(w, r, ErrInvalidToken.Wrap(err))
+			return
+		}
+
+		if scope != "" && !(allowConsentScope && scope == authentication.ConsentScope) {
+			responses.Problem(w, r, apperrors.Unauthenticated("consent_required", "token is restricted to accepting the pending terms"))
 			return
 		}
 
 		userID, err := authentication.GetTokenUserID(r)
 		if err != nil {
-			responses.Error(w, http.StatusUnauthorized, errors.New("Error creating context"))
+			responses.Problem(w, r, ErrInvalidToken.Wrap(err))
 			return
 		}
 
-		log.Printf("User id token: %s", userID)
+		if sessions != nil {
+			if err := validateSession(r, userID); err != nil {
+				responses.Problem(w, r, err)
+				return
+			}
+		}
 
 		ctx := context.WithValue(r.Context(), UserKey, userID)
-
-		if userID, ok := ctx.Value(UserKey).(uint64); ok {
-			fmt.Println("This is the user id: ", userID)
-		} else {
-			fmt.Println("this is a protected route - no user id found")
+		if actorID, err := strconv.ParseUint(userID, 10, 64); err == nil {
+			ctx = audit.WithActor(ctx, actorID)
 		}
 
 		request(w, r.WithContext(ctx))
 	}
 }
+
+func validateSession(r *http.Request, userID string) error {
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return ErrInvalidToken.Wrap(err)
+	}
+
+	issuedAt, err := authentication.GetTokenIssuedAt(r)
+	if err != nil {
+		return ErrInvalidToken.Wrap(err)
+	}
+
+	return sessions.ValidateSession(r.Context(), id, issuedAt)
+}
+
+// Restringe a rota aos serviços internos que conhecem o INTERNAL_API_TOKEN
+func AuthenticateInternal(request http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Internal-Token")
+
+		if authConfig.InternalAPIToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(authConfig.InternalAPIToken)) != 1 {
+			responses.Problem(w, r, apperrors.Unauthenticated("invalid_internal_token", "invalid internal token"))
+			return
+		}
+
+		request(w, r)
+	}
+}
+
+// Restringe a rota aos administradores da plataforma (users.is_admin), conferidos a cada requisição
+// para que a revogação valha sem esperar o token vencer
+func AuthenticateAdmin(request http.HandlerFunc) http.HandlerFunc {
+	return Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		if admins == nil {
+			responses.Problem(w, r, errors.New("admin checker not configured"))
+			return
+		}
+
+		requestUserID, _ := r.Context().Value(UserKey).(string)
+
+		userID, err := strconv.ParseUint(requestUserID, 10, 64)
+		if err != nil {
+			responses.Problem(w, r, apperrors.ErrUnauthenticated.Wrap(err))
+			return
+		}
+
+		isAdmin, err := admins.IsAdmin(r.Context(), userID)
+		if err != nil {
+			responses.Problem(w, r, err)
+			return
+		}
+
+		if !isAdmin {
+			responses.Problem(w, r, ErrAdminOnly)
+			return
+		}
+
+		request(w, r)
+	})
+}
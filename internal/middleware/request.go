@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"HareID/internal/audit"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Identifica a requisição (X-Request-ID recebido ou um novo) e guarda IP e user agent para a auditoria
+func RequestMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 64 {
+			requestID = audit.NewRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := audit.WithRequest(r.Context(), audit.Request{
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+var trustedProxies []netip.Prefix
+
+// Registra os proxies reversos cujo X-Forwarded-For é aceito. Chamado no main com TRUSTED_PROXIES
+func SetTrustedProxies(proxies []netip.Prefix) {
+	trustedProxies = proxies
+}
+
+// Endereço da conexão, a menos que ela venha de um proxy confiável. Nesse caso vale o salto mais à direita
+// do X-Forwarded-For que não é um proxy confiável: os valores à esquerda podem ter sido forjados pelo cliente
+func clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+
+	if !trustedProxy(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !trustedProxy(hop) {
+			return hop
+		}
+		remote = hop
+	}
+
+	// Todos os saltos são proxies confiáveis: vale o mais distante
+	return remote
+}
+
+func trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
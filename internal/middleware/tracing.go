@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"HareID/internal/tracing"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Abre o span da requisição, continuando o trace recebido em traceparent (W3C). O span recebe o nome da rota
+// do chi depois do roteamento e o trace_id volta no header X-Trace-ID
+func Tracing(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if traceID := tracing.TraceID(r.Context()); traceID != "" {
+			w.Header().Set("X-Trace-ID", traceID)
+		}
+
+		next.ServeHTTP(w, r)
+
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + routeContext.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(routeContext.RoutePattern()))
+		}
+	})
+
+	return otelhttp.NewHandler(named, "http request")
+}
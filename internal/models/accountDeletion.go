@@ -0,0 +1,39 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"time"
+)
+
+// Políticas para as equipes de quem exclui a conta
+const (
+	// Passa a equipe para um administrador (ou o membro mais antigo); sem outros membros ela é dissolvida
+	TEAM_POLICY_TRANSFER = "transfer"
+	// Dissolve todas as equipes do usuário
+	TEAM_POLICY_DISSOLVE = "dissolve"
+)
+
+// Pedido de exclusão da conta, executado ao fim do prazo de arrependimento
+type AccountDeletion struct {
+	ID           uint64               `json:"id"`
+	UserID       uint64               `json:"user_id"`
+	Status       enums.DeletionStatus `json:"status"`
+	TeamPolicy   string               `json:"team_policy"`
+	RequestedAt  time.Time            `json:"requested_at"`
+	ScheduledFor time.Time            `json:"scheduled_for"`
+	CanceledAt   *time.Time           `json:"canceled_at,omitempty"`
+	CompletedAt  *time.Time           `json:"completed_at,omitempty"`
+	Attempts     int                  `json:"-"`
+	LastError    string               `json:"last_error,omitempty"`
+}
+
+// Registro mínimo que sobra de uma conta excluída. Guarda apenas o hash da identidade
+type UserTombstone struct {
+	UserID           uint64    `json:"user_id"`
+	IdentityHash     string    `json:"-"`
+	TeamPolicy       string    `json:"team_policy"`
+	TeamsTransferred int       `json:"teams_transferred"`
+	TeamsDissolved   int       `json:"teams_dissolved"`
+	RequestedAt      time.Time `json:"requested_at"`
+	DeletedAt        time.Time `json:"deleted_at"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Ações registradas na trilha de auditoria
+const (
+	AUDIT_LOGIN           = "auth.login"
+	AUDIT_TOKEN_ISSUED    = "auth.token_issued"
+	AUDIT_SESSIONS_REVOKE = "auth.sessions_revoked"
+
+	AUDIT_USER_CREATED        = "user.created"
+	AUDIT_USER_UPDATED        = "user.updated"
+	AUDIT_USER_ANONYMIZED     = "user.anonymized"
+	AUDIT_DELETION_SCHEDULED  = "user.deletion_scheduled"
+	AUDIT_DELETION_CANCELED   = "user.deletion_canceled"
+	AUDIT_CONSENT_RECORDED    = "user.consent_recorded"
+	AUDIT_ADMIN_GRANTED       = "user.admin_granted"
+	AUDIT_ADMIN_REVOKED       = "user.admin_revoked"
+	AUDIT_TERMS_PUBLISHED     = "terms.published"
+	AUDIT_TEAM_CREATED        = "team.created"
+	AUDIT_TEAM_UPDATED        = "team.updated"
+	AUDIT_TEAM_DELETED        = "team.deleted"
+	AUDIT_TEAM_OWNER_CHANGED  = "team.owner_changed"
+	AUDIT_MEMBER_ADDED        = "team.member_added"
+	AUDIT_MEMBER_REMOVED      = "team.member_removed"
+	AUDIT_JOIN_REQUESTED      = "join_request.created"
+	AUDIT_JOIN_ACCEPTED       = "join_request.accepted"
+	AUDIT_JOIN_REJECTED       = "join_request.rejected"
+	AUDIT_JOIN_DELETED        = "join_request.deleted"
+	AUDIT_CHECKOUT_STARTED    = "billing.checkout_started"
+	AUDIT_SUBSCRIPTION_SYNCED = "billing.subscription_updated"
+	AUDIT_CANCEL_REQUESTED    = "billing.cancel_requested"
+	AUDIT_PLAN_CHANGED        = "billing.plan_change_requested"
+)
+
+// Tipos de alvo das ações
+const (
+	AUDIT_TARGET_USER         = "user"
+	AUDIT_TARGET_TEAM         = "team"
+	AUDIT_TARGET_MEMBER       = "team_member"
+	AUDIT_TARGET_JOIN_REQUEST = "join_request"
+	AUDIT_TARGET_SUBSCRIPTION = "subscription"
+	AUDIT_TARGET_TERMS        = "terms_version"
+)
+
+// Entrada da trilha de auditoria. Cada entrada guarda o hash da anterior, então alterar ou apagar
+// qualquer registro quebra a cadeia a partir dele
+type AuditLog struct {
+	ID uint64 `json:"id"`
+	// Nil quando a ação foi feita pelo sistema (webhooks e jobs)
+	ActorID    *uint64 `json:"actor_id"`
+	Action     string  `json:"action"`
+	TargetType string  `json:"target_type"`
+	TargetID   string  `json:"target_id"`
+	TeamID     *uint64 `json:"team_id,omitempty"`
+	// Usuário afetado pela ação, usado na visão de auditoria do usuário
+	UserID    *uint64         `json:"user_id,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	IP        string          `json:"ip,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// Filtros das consultas. Action aceita prefixo (ex: "team." traz todas as ações de equipe)
+type AuditFilter struct {
+	TeamID   *uint64
+	UserID   *uint64
+	ActorID  *uint64
+	Action   string
+	From     *time.Time
+	To       *time.Time
+	BeforeID uint64
+	Limit    int
+}
+
+// Resultado da verificação da cadeia de hashes
+type AuditVerification struct {
+	Valid    bool    `json:"valid"`
+	Checked  int     `json:"checked"`
+	BrokenAt *uint64 `json:"broken_at,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+}
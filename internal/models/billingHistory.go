@@ -0,0 +1,17 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"time"
+)
+
+// Transição de trial ou cobrança aplicada a uma assinatura
+type BillingHistory struct {
+	ID             uint64             `json:"id,omitempty"`
+	SubscriptionID string             `json:"subscription_id,omitempty"`
+	UserID         uint64             `json:"user_id,omitempty"`
+	TeamID         *uint64            `json:"team_id,omitempty"`
+	Event          enums.BillingEvent `json:"event"`
+	Detail         string             `json:"detail,omitempty"`
+	CreatedAt      time.Time          `json:"created_at,omitempty"`
+}
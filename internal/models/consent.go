@@ -0,0 +1,66 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"time"
+)
+
+// Versão publicada de um termo (termos de uso, política de marketing ou de tratamento de dados)
+type TermsVersion struct {
+	ID          uint64               `json:"id,omitempty"`
+	Purpose     enums.ConsentPurpose `json:"purpose"`
+	Version     string               `json:"version"`
+	ContentURL  string               `json:"content_url"`
+	Summary     string               `json:"summary,omitempty"`
+	PublishedAt time.Time            `json:"published_at"`
+}
+
+// Registro imutável de um aceite ou de uma revogação
+type UserConsent struct {
+	ID             uint64               `json:"id"`
+	UserID         uint64               `json:"user_id"`
+	Purpose        enums.ConsentPurpose `json:"purpose"`
+	TermsVersionID *uint64              `json:"terms_version_id,omitempty"`
+	Granted        bool                 `json:"granted"`
+	IP             string               `json:"ip,omitempty"`
+	UserAgent      string               `json:"user_agent,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+// Origem da requisição em que o consentimento foi dado
+type ConsentSource struct {
+	IP        string
+	UserAgent string
+}
+
+// Situação atual de uma finalidade para o usuário
+type ConsentStatus struct {
+	Purpose        enums.ConsentPurpose `json:"purpose"`
+	Granted        bool                 `json:"granted"`
+	TermsVersionID *uint64              `json:"terms_version_id,omitempty"`
+	// Falso quando o aceite é de uma versão anterior à vigente
+	UpToDate  bool      `json:"up_to_date"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ConsentOverview struct {
+	Current []ConsentStatus `json:"current"`
+	History []UserConsent   `json:"history"`
+	// Versões obrigatórias que ainda precisam ser aceitas
+	Pending []TermsVersion `json:"pending"`
+}
+
+// Resultado do registro de consentimentos. O token completo só vem quando nada obrigatório ficou pendente
+type ConsentResult struct {
+	Consents []UserConsent  `json:"consents"`
+	Pending  []TermsVersion `json:"pending"`
+	Token    string         `json:"token,omitempty"`
+}
+
+// Resultado do login. Com termos pendentes, só é emitido um token restrito ao registro do consentimento
+type LoginResult struct {
+	Token           string         `json:"token,omitempty"`
+	ConsentRequired bool           `json:"consent_required"`
+	ConsentToken    string         `json:"consent_token,omitempty"`
+	PendingTerms    []TermsVersion `json:"pending_terms,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"time"
+)
+
+// Cópia dos dados pessoais solicitada pelo titular (portabilidade da LGPD)
+type DataExport struct {
+	ID         uint64             `json:"id"`
+	UserID     uint64             `json:"user_id"`
+	Status     enums.ExportStatus `json:"status"`
+	IncludeCSV bool               `json:"include_csv"`
+	FilePath   string             `json:"-"`
+	Error      string             `json:"error,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	// Preenchidos quando o arquivo fica pronto
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// Link assinado, gerado a cada consulta enquanto o arquivo estiver disponível
+	DownloadURL string `json:"download_url,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"time"
+)
+
+// Direitos resolvidos a partir da assinatura do dono da equipe
+type Entitlement struct {
+	Plan           Plan                      `json:"plan"`
+	SubscriptionID string                    `json:"subscription_id,omitempty"`
+	Status         subscription.Subscription `json:"status"`
+	Access         enums.TeamAccess          `json:"access"`
+	GraceEndsAt    *time.Time                `json:"grace_ends_at,omitempty"`
+}
+
+// Direitos de uma equipe de que o usuário participa
+type TeamEntitlement struct {
+	TeamID      uint64      `json:"team_id"`
+	TeamName    string      `json:"team_name,omitempty"`
+	Entitlement Entitlement `json:"entitlement"`
+}
+
+// Direitos efetivos do usuário: a assinatura própria somada às das suas equipes
+type UserEntitlements struct {
+	UserID   uint64            `json:"user_id"`
+	Personal Entitlement       `json:"personal"`
+	Teams    []TeamEntitlement `json:"teams"`
+	// Recursos liberados por alguma assinatura que não esteja somente leitura
+	Features []string `json:"features"`
+}
+
+// Resultado da verificação de um recurso para o usuário
+type FeatureCheck struct {
+	Feature string `json:"feature"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+	Plan    string `json:"plan,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Resultado do /readyz. Ready só é true quando todas as verificações passam
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
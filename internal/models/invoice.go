@@ -0,0 +1,24 @@
+package models
+
+import (
+	"HareID/internal/enums/invoice"
+	"time"
+)
+
+// Cópia local de uma fatura do provedor de pagamento
+type Invoice struct {
+	ID               uint64          `json:"id,omitempty"`
+	InvoiceID        string          `json:"invoice_id,omitempty"`
+	UserID           uint64          `json:"user_id,omitempty"`
+	TeamID           *uint64         `json:"team_id,omitempty"`
+	SubscriptionID   string          `json:"subscription_id,omitempty"`
+	Status           invoice.Invoice `json:"status"`
+	Currency         string          `json:"currency,omitempty"`
+	AmountDue        int64           `json:"amount_due"`
+	AmountPaid       int64           `json:"amount_paid"`
+	AmountRefunded   int64           `json:"amount_refunded"`
+	HostedInvoiceURL string          `json:"hosted_invoice_url,omitempty"`
+	PeriodStart      time.Time       `json:"period_start,omitempty"`
+	PeriodEnd        time.Time       `json:"period_end,omitempty"`
+	CreatedAt        time.Time       `json:"created_at,omitempty"`
+}
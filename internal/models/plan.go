@@ -0,0 +1,31 @@
+package models
+
+// Plano de assinatura identificado pelo price_id do provedor de pagamento
+type Plan struct {
+	ID       uint64 `json:"id,omitempty"`
+	PriceID  string `json:"price_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// Valor mensal por assento em centavos, para provedores sem catálogo de preços
+	Amount   int64  `json:"amount,omitempty"`
+	MaxSeats uint64 `json:"max_seats"`
+	MaxTeams uint64 `json:"max_teams"`
+	// Recursos liberados pelo plano, verificados por middleware.RequireEntitlement ou, nos recursos de uma equipe,
+	// por EntitlementServices.CheckTeamFeature
+	Features []string `json:"features"`
+	// Consumo máximo por período de cada medidor de uso
+	MeterLimits map[string]int64 `json:"meter_limits,omitempty"`
+}
+
+// Recursos pagos exigidos pelas rotas; os planos os liberam em plans.features
+const (
+	FEATURE_AUDIT_LOGS = "audit_logs"
+)
+
+// Plano aplicado a quem não possui assinatura ativa
+var FreePlan = Plan{
+	Name:     "free",
+	MaxSeats: 3,
+	MaxTeams: 1,
+	Features: []string{},
+}
@@ -0,0 +1,10 @@
+package models
+
+// Prévia do valor cobrado ao trocar o plano de uma assinatura
+type PlanChangePreview struct {
+	SubscriptionID string `json:"subscription_id"`
+	PriceID        string `json:"price_id"`
+	Currency       string `json:"currency"`
+	AmountDue      int64  `json:"amount_due"`
+	ProrationDate  int64  `json:"proration_date"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Diferença encontrada entre o registro local e o Stripe
+type ReconciliationDrift struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Field  string `json:"field"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	Fixed  bool   `json:"fixed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Resultado de uma execução da conciliação com o Stripe
+type ReconciliationReport struct {
+	StartedAt            time.Time             `json:"started_at"`
+	FinishedAt           time.Time             `json:"finished_at"`
+	Fix                  bool                  `json:"fix"`
+	SubscriptionsChecked int                   `json:"subscriptions_checked"`
+	CustomersChecked     int                   `json:"customers_checked"`
+	Drifts               []ReconciliationDrift `json:"drifts"`
+}
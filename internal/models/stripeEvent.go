@@ -0,0 +1,26 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Evento de webhook já verificado, guardado para processamento assíncrono.
+// Guarda eventos de todos os provedores de pagamento, apesar do nome
+type StripeEvent struct {
+	ID              string                   `json:"id,omitempty"`
+	Provider        string                   `json:"provider,omitempty"`
+	Type            string                   `json:"type,omitempty"`
+	ObjectID        string                   `json:"object_id,omitempty"`
+	Payload         json.RawMessage          `json:"payload,omitempty" swaggertype:"object"`
+	Status          enums.WebhookEventStatus `json:"status"`
+	Attempts        int                      `json:"attempts"`
+	LastError       string                   `json:"last_error,omitempty"`
+	StripeCreatedAt time.Time                `json:"stripe_created_at,omitempty"`
+	NextAttemptAt   pq.NullTime              `json:"next_attempt_at" swaggertype:"string" format:"date-time"`
+	ReceivedAt      time.Time                `json:"received_at,omitempty"`
+	ProcessedAt     pq.NullTime              `json:"processed_at" swaggertype:"string" format:"date-time"`
+}
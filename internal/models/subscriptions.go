@@ -7,10 +7,22 @@ import (
 
 // ID, PriceID, Status, CurrentPeriodEnd
 type Subscription struct {
-	ID               uint64                    `json:"id,omitempty"`
-	UserID           uint64                    `json:"user_id,omitempty"`
-	SubscriptionID   string                    `json:"subscription_id,omitempty"`
-	PriceID          string                    `json:"price_id,omitempty"`
-	Status           subscription.Subscription `json:"status,omitempty"`
-	CurrentPeriodEnd time.Time                 `json:"current_period_end,omitempty"`
+	ID                 uint64                    `json:"id,omitempty"`
+	UserID             uint64                    `json:"user_id,omitempty"`
+	TeamID             *uint64                   `json:"team_id,omitempty"`
+	SubscriptionID     string                    `json:"subscription_id,omitempty"`
+	Provider           string                    `json:"provider,omitempty"`
+	PriceID            string                    `json:"price_id,omitempty"`
+	Quantity           int64                     `json:"quantity,omitempty"`
+	Status             subscription.Subscription `json:"status,omitempty"`
+	CurrentPeriodStart time.Time                 `json:"current_period_start,omitempty"`
+	CurrentPeriodEnd   time.Time                 `json:"current_period_end,omitempty"`
+	// Quando entrou em PAST_DUE/UNPAID; início da carência e dos lembretes de cobrança
+	PastDueSince *time.Time `json:"past_due_since,omitempty"`
+}
+
+// Período pago informado por um evento do provedor
+type BillingPeriod struct {
+	Start time.Time
+	End   time.Time
 }
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"errors"
+	"HareID/internal/apperrors"
 	"time"
 )
 
@@ -23,10 +23,10 @@ func (team *Team) ValidateTeam(step string) error {
 
 func (team *Team) ValidateData(step string) error {
 	if team.Name == "" {
-		return errors.New("name is required")
+		return apperrors.Field("name", "is required")
 	}
 	if team.Domain == "" {
-		return errors.New("domain is required")
+		return apperrors.Field("domain", "is required")
 	}
 	return nil
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"HareID/internal/enums"
+	"time"
+)
+
+// Consumo de um medidor (chamadas de API, assentos ativos...) informado por serviços internos
+type UsageEvent struct {
+	ID             uint64            `json:"id,omitempty"`
+	TeamID         uint64            `json:"team_id"`
+	Meter          string            `json:"meter"`
+	Quantity       int64             `json:"quantity"`
+	OccurredAt     time.Time         `json:"occurred_at"`
+	IdempotencyKey string            `json:"idempotency_key"`
+	Status         enums.UsageStatus `json:"status"`
+	Attempts       int               `json:"attempts,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+}
+
+// Consumo somado de um medidor no período
+type MeterUsage struct {
+	Meter    string `json:"meter"`
+	Quantity int64  `json:"quantity"`
+	// Limite do plano; nil quando o medidor não tem limite
+	Limit *int64 `json:"limit,omitempty"`
+}
+
+// Consumo da equipe no período de cobrança atual
+type UsageSummary struct {
+	TeamID      uint64       `json:"team_id"`
+	Plan        string       `json:"plan"`
+	PeriodStart time.Time    `json:"period_start"`
+	PeriodEnd   time.Time    `json:"period_end"`
+	Meters      []MeterUsage `json:"meters"`
+}
@@ -1,8 +1,8 @@
 package models
 
 import (
+	"HareID/internal/apperrors"
 	"HareID/internal/enums"
-	"errors"
 	"strings"
 	"time"
 )
@@ -12,13 +12,17 @@ type User struct {
 	GoogleSub string `json:"google_sub,omitempty"`
 	Name      string `json:"name,omitempty"`
 	CpfCnpj   string `json:"cpf_cnpj,omitempty"`
+	// Preenchido nas respostas: 1 CPF, 2 CNPJ
+	CpfCnpjType enums.DocumentType `json:"cpf_cnpj_type,omitempty"`
 	// Provedor Autenticação - Google - Senha
 	StripeCustomerID string             `json:"stripe_customer_id,omitempty"`
 	AuthProvider     enums.AuthProvider `json:"auth_provider,omitempty"`
-	ConsentTerms     bool               `json:"consent_terms,omitempty"`
-	DataConsent      time.Time          `json:"data_consent,omitempty"`
-	CreateDate       time.Time          `json:"create_date,omitempty"`
-	UpdateDate       time.Time          `json:"update_date,omitempty"`
+	// Administrador da plataforma. Ignorado no cadastro e na edição: muda só em PATCH /admin/users/{user_id}/admin
+	IsAdmin      bool      `json:"is_admin,omitempty"`
+	ConsentTerms bool      `json:"consent_terms,omitempty"`
+	DataConsent  time.Time `json:"data_consent,omitempty"`
+	CreateDate   time.Time `json:"create_date,omitempty"`
+	UpdateDate   time.Time `json:"update_date,omitempty"`
 }
 
 // Valida os dados do usuário
@@ -34,21 +38,21 @@ func (user *User) ValidateUser(step string) error {
 func (user *User) ValidateData(step string) error {
 	if step != "update" {
 		if user.GoogleSub == "" {
-			return errors.New("google_sub is required")
+			return apperrors.Field("google_sub", "is required")
 		}
 
 		if user.Name == "" {
-			return errors.New("name is required")
+			return apperrors.Field("name", "is required")
 		}
 
 		if user.AuthProvider != 0 && user.AuthProvider != 1 {
-			return errors.New("auth_provider is required")
+			return apperrors.Field("auth_provider", "is required")
 		}
 	}
 
 	if step == "login" {
 		if !user.ConsentTerms {
-			return errors.New("consent_terms is required and cannot be refused")
+			return apperrors.Field("consent_terms", "is required and cannot be refused")
 		}
 	}
 
@@ -60,3 +64,13 @@ func (user *User) Format() {
 	user.Name = strings.TrimSpace(user.Name)
 	user.CpfCnpj = strings.TrimSpace(user.CpfCnpj)
 }
+
+// Resultado de um lote da rotação de chaves do CPF/CNPJ
+type KeyRotationBatch struct {
+	// Maior id lido no lote; o próximo lote começa depois dele
+	LastID  uint64
+	Scanned int
+	Rotated int
+	// Registros que não puderam ser regravados e ficaram para a próxima execução, com o motivo
+	Failed map[uint64]error
+}
@@ -0,0 +1,293 @@
+package payments
+
+import (
+	"HareID/internal/enums/subscription"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datas do Asaas chegam sem fuso, no horário de Brasília
+var asaasLocation = time.FixedZone("BRT", -3*60*60)
+
+const asaasDateFormat = "2006-01-02"
+
+// Gateway brasileiro com cobrança por PIX e boleto
+type AsaasProvider struct {
+	baseURL      string
+	apiKey       string
+	webhookToken string
+	http         *http.Client
+}
+
+// baseURL permite apontar para o sandbox ou para um servidor falso local
+func NewAsaas(baseURL, apiKey, webhookToken string, timeout time.Duration) *AsaasProvider {
+	return &AsaasProvider{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       apiKey,
+		webhookToken: webhookToken,
+		http:         &http.Client{Timeout: timeout},
+	}
+}
+
+type AsaasPayment struct {
+	ID           string `json:"id"`
+	Customer     string `json:"customer"`
+	Subscription string `json:"subscription"`
+	Status       string `json:"status"`
+	DueDate      string `json:"dueDate"`
+	InvoiceURL   string `json:"invoiceUrl"`
+}
+
+type AsaasSubscription struct {
+	ID          string `json:"id"`
+	Customer    string `json:"customer"`
+	Status      string `json:"status"`
+	NextDueDate string `json:"nextDueDate"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// Corpo dos webhooks do Asaas
+type AsaasEvent struct {
+	ID           string             `json:"id"`
+	Event        string             `json:"event"`
+	DateCreated  string             `json:"dateCreated"`
+	Payment      *AsaasPayment      `json:"payment"`
+	Subscription *AsaasSubscription `json:"subscription"`
+}
+
+// Assinatura afetada pelo evento, seja ele de pagamento ou de assinatura
+func (e AsaasEvent) SubscriptionID() string {
+	if e.Subscription != nil {
+		return e.Subscription.ID
+	}
+	if e.Payment != nil {
+		return e.Payment.Subscription
+	}
+	return ""
+}
+
+func ParseAsaasEvent(payload []byte) (AsaasEvent, error) {
+	var event AsaasEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return AsaasEvent{}, err
+	}
+
+	if event.ID == "" || event.Event == "" {
+		return AsaasEvent{}, errors.New("asaas event without id or type")
+	}
+
+	return event, nil
+}
+
+func ParseAsaasDate(value string) (time.Time, error) {
+	return time.ParseInLocation(asaasDateFormat, value, asaasLocation)
+}
+
+func (p *AsaasProvider) Name() string {
+	return ASAAS
+}
+
+// Cria (ou reaproveita) o cliente e uma assinatura mensal em que o cliente escolhe PIX ou boleto.
+// A URL retornada é a fatura da primeira cobrança
+func (p *AsaasProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (CheckoutSession, error) {
+	if req.Amount <= 0 {
+		return CheckoutSession{}, errors.New("plan has no amount configured")
+	}
+	if req.CpfCnpj == "" {
+		return CheckoutSession{}, errors.New("cpf_cnpj is required to pay with PIX or boleto")
+	}
+
+	customerID := req.CustomerID
+	if customerID == "" {
+		var err error
+		if customerID, err = p.findOrCreateCustomer(ctx, req); err != nil {
+			return CheckoutSession{}, err
+		}
+	}
+
+	body := map[string]any{
+		"customer":          customerID,
+		"billingType":       "UNDEFINED",
+		"value":             float64(req.Amount*req.Quantity) / 100,
+		"nextDueDate":       time.Now().In(asaasLocation).Format(asaasDateFormat),
+		"cycle":             "MONTHLY",
+		"description":       req.PriceID,
+		"externalReference": strconv.FormatUint(req.UserID, 10),
+	}
+
+	var created AsaasSubscription
+	if err := p.do(ctx, http.MethodPost, "/v3/subscriptions", body, &created); err != nil {
+		return CheckoutSession{}, err
+	}
+
+	var payments struct {
+		Data []AsaasPayment `json:"data"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/v3/payments?subscription="+url.QueryEscape(created.ID), nil, &payments); err != nil {
+		return CheckoutSession{}, err
+	}
+
+	if len(payments.Data) == 0 {
+		return CheckoutSession{}, errors.New("asaas did not generate the first payment")
+	}
+
+	return CheckoutSession{
+		URL:            payments.Data[0].InvoiceURL,
+		CustomerID:     customerID,
+		SubscriptionID: created.ID,
+	}, nil
+}
+
+// O Asaas autentica webhooks com o token cadastrado no painel, enviado no header asaas-access-token
+func (p *AsaasProvider) ParseWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+	token := header.Get("asaas-access-token")
+	if p.webhookToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.webhookToken)) != 1 {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	event, err := ParseAsaasEvent(payload)
+	if err != nil {
+		return WebhookEvent{}, err
+	}
+
+	createdAt, err := time.ParseInLocation("2006-01-02 15:04:05", event.DateCreated, asaasLocation)
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	return WebhookEvent{
+		ID:        event.ID,
+		Type:      event.Event,
+		ObjectID:  event.SubscriptionID(),
+		CreatedAt: createdAt,
+		Payload:   payload,
+	}, nil
+}
+
+// Converte status de assinatura e de cobrança do Asaas
+func (p *AsaasProvider) MapStatus(status string) subscription.Subscription {
+	switch strings.ToUpper(status) {
+	case "ACTIVE", "RECEIVED", "CONFIRMED", "RECEIVED_IN_CASH":
+		return subscription.ACTIVE
+	case "PENDING", "AWAITING_RISK_ANALYSIS":
+		return subscription.INCOMPLETE
+	case "OVERDUE":
+		return subscription.PAST_DUE
+	case "INACTIVE", "EXPIRED", "DELETED":
+		return subscription.CANCELED
+	default:
+		// REFUNDED e estornos valem para a cobrança, não para a assinatura: o cancelamento chega como SUBSCRIPTION_*
+		return subscription.UNKNOWN
+	}
+}
+
+func (p *AsaasProvider) Cancel(ctx context.Context, subscriptionID string, atPeriodEnd bool) error {
+	path := "/v3/subscriptions/" + url.PathEscape(subscriptionID)
+
+	if !atPeriodEnd {
+		return p.do(ctx, http.MethodDelete, path, nil, nil)
+	}
+
+	// Encerra a assinatura antes do próximo vencimento, mantendo o período já pago
+	var current AsaasSubscription
+	if err := p.do(ctx, http.MethodGet, path, nil, &current); err != nil {
+		return err
+	}
+
+	nextDueDate, err := ParseAsaasDate(current.NextDueDate)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]any{
+		"endDate": nextDueDate.AddDate(0, 0, -1).Format(asaasDateFormat),
+	}
+
+	return p.do(ctx, http.MethodPost, path, body, nil)
+}
+
+func (p *AsaasProvider) findOrCreateCustomer(ctx context.Context, req CheckoutRequest) (string, error) {
+	reference := strconv.FormatUint(req.UserID, 10)
+
+	var found struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/v3/customers?externalReference="+url.QueryEscape(reference), nil, &found); err != nil {
+		return "", err
+	}
+
+	if len(found.Data) > 0 {
+		return found.Data[0].ID, nil
+	}
+
+	body := map[string]any{
+		"name":              req.Name,
+		"cpfCnpj":           req.CpfCnpj,
+		"externalReference": reference,
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v3/customers", body, &created); err != nil {
+		return "", err
+	}
+
+	return created.ID, nil
+}
+
+func (p *AsaasProvider) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("access_token", p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HareID")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Errors []struct {
+				Description string `json:"description"`
+			} `json:"errors"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("asaas %s %s: %s", method, path, apiErr.Errors[0].Description)
+		}
+		return fmt.Errorf("asaas %s %s: status %d", method, path, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
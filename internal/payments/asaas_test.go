@@ -0,0 +1,278 @@
+package payments
+
+import (
+	"HareID/internal/enums/subscription"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadAsaasFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	payload, err := os.ReadFile(filepath.Join("testdata", "asaas", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+func TestAsaasMapStatus(t *testing.T) {
+	provider := NewAsaas("", "", "", time.Second)
+
+	tests := []struct {
+		status string
+		want   subscription.Subscription
+	}{
+		{"ACTIVE", subscription.ACTIVE},
+		{"RECEIVED", subscription.ACTIVE},
+		{"CONFIRMED", subscription.ACTIVE},
+		{"RECEIVED_IN_CASH", subscription.ACTIVE},
+		{"received", subscription.ACTIVE},
+		{"PENDING", subscription.INCOMPLETE},
+		{"AWAITING_RISK_ANALYSIS", subscription.INCOMPLETE},
+		{"OVERDUE", subscription.PAST_DUE},
+		{"INACTIVE", subscription.CANCELED},
+		{"EXPIRED", subscription.CANCELED},
+		{"DELETED", subscription.CANCELED},
+		{"REFUNDED", subscription.UNKNOWN},
+		{"CHARGEBACK_REQUESTED", subscription.UNKNOWN},
+		{"", subscription.UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		if got := provider.MapStatus(tt.status); got != tt.want {
+			t.Errorf("MapStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAsaasParseWebhook(t *testing.T) {
+	provider := NewAsaas("", "", "whsec", time.Second)
+
+	tests := []struct {
+		name         string
+		fixture      string
+		token        string
+		wantErr      error
+		wantType     string
+		wantObjectID string
+	}{
+		{"payment event", "payment_received.json", "whsec", nil, "PAYMENT_RECEIVED", "sub_VXJBYgP2u0eO"},
+		{"subscription event", "subscription_deleted.json", "whsec", nil, "SUBSCRIPTION_DELETED", "sub_VXJBYgP2u0eO"},
+		{"single payment", "payment_single.json", "whsec", nil, "PAYMENT_RECEIVED", ""},
+		{"wrong token", "payment_received.json", "other", ErrInvalidSignature, "", ""},
+		{"missing token", "payment_received.json", "", ErrInvalidSignature, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.token != "" {
+				header.Set("asaas-access-token", tt.token)
+			}
+
+			event, err := provider.ParseWebhook(loadAsaasFixture(t, tt.fixture), header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if event.Type != tt.wantType || event.ObjectID != tt.wantObjectID {
+				t.Errorf("event = %s/%s, want %s/%s", event.Type, event.ObjectID, tt.wantType, tt.wantObjectID)
+			}
+			if event.CreatedAt.Location() != asaasLocation {
+				t.Errorf("created_at location = %v, want %v", event.CreatedAt.Location(), asaasLocation)
+			}
+		})
+	}
+}
+
+func TestAsaasParseWebhookWithoutToken(t *testing.T) {
+	// Sem token configurado nenhum webhook é aceito
+	provider := NewAsaas("", "", "", time.Second)
+
+	header := http.Header{}
+	header.Set("asaas-access-token", "")
+
+	if _, err := provider.ParseWebhook(loadAsaasFixture(t, "payment_received.json"), header); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+// Servidor falso que responde às rotas usadas pelo provedor
+func newFakeAsaas(t *testing.T, routes map[string]http.HandlerFunc) *AsaasProvider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("access_token") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		handler, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewAsaas(server.URL+"/", "key", "whsec", time.Second)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, body any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAsaasCreateCheckout(t *testing.T) {
+	var subscriptionBody map[string]any
+
+	provider := newFakeAsaas(t, map[string]http.HandlerFunc{
+		"GET /v3/customers": func(w http.ResponseWriter, r *http.Request) {
+			if got := r.URL.Query().Get("externalReference"); got != "42" {
+				t.Errorf("externalReference = %q, want 42", got)
+			}
+			writeJSON(t, w, http.StatusOK, map[string]any{"data": []any{}})
+		},
+		"POST /v3/customers": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, map[string]any{"id": "cus_000005219613"})
+		},
+		"POST /v3/subscriptions": func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&subscriptionBody); err != nil {
+				t.Error(err)
+			}
+			writeJSON(t, w, http.StatusOK, map[string]any{"id": "sub_VXJBYgP2u0eO", "status": "ACTIVE"})
+		},
+		"GET /v3/payments": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, map[string]any{
+				"data": []map[string]any{{"id": "pay_080225913252", "invoiceUrl": "https://sandbox.asaas.com/i/080225913252"}},
+			})
+		},
+	})
+
+	session, err := provider.CreateCheckout(context.Background(), CheckoutRequest{
+		UserID:   42,
+		Name:     "Maria",
+		CpfCnpj:  "52998224725",
+		PriceID:  "pro_monthly",
+		Amount:   4990,
+		Quantity: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := CheckoutSession{
+		URL:            "https://sandbox.asaas.com/i/080225913252",
+		CustomerID:     "cus_000005219613",
+		SubscriptionID: "sub_VXJBYgP2u0eO",
+	}
+	if session != want {
+		t.Errorf("session = %+v, want %+v", session, want)
+	}
+
+	if subscriptionBody["customer"] != "cus_000005219613" || subscriptionBody["value"] != 99.8 || subscriptionBody["cycle"] != "MONTHLY" {
+		t.Errorf("subscription body = %v", subscriptionBody)
+	}
+}
+
+func TestAsaasCreateCheckoutValidation(t *testing.T) {
+	provider := NewAsaas("http://127.0.0.1:0", "key", "whsec", time.Second)
+
+	tests := []struct {
+		name string
+		req  CheckoutRequest
+	}{
+		{"without amount", CheckoutRequest{CpfCnpj: "52998224725", Quantity: 1}},
+		{"without cpf_cnpj", CheckoutRequest{Amount: 4990, Quantity: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.CreateCheckout(context.Background(), tt.req); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestAsaasCancel(t *testing.T) {
+	tests := []struct {
+		name        string
+		atPeriodEnd bool
+		wantEndDate string
+	}{
+		{"immediately", false, ""},
+		{"at period end", true, "2026-08-31"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted bool
+			var endDate string
+
+			provider := newFakeAsaas(t, map[string]http.HandlerFunc{
+				"DELETE /v3/subscriptions/sub_VXJBYgP2u0eO": func(w http.ResponseWriter, r *http.Request) {
+					deleted = true
+					writeJSON(t, w, http.StatusOK, map[string]any{"deleted": true})
+				},
+				"GET /v3/subscriptions/sub_VXJBYgP2u0eO": func(w http.ResponseWriter, r *http.Request) {
+					writeJSON(t, w, http.StatusOK, map[string]any{"id": "sub_VXJBYgP2u0eO", "nextDueDate": "2026-09-01"})
+				},
+				"POST /v3/subscriptions/sub_VXJBYgP2u0eO": func(w http.ResponseWriter, r *http.Request) {
+					var body map[string]string
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Error(err)
+					}
+					endDate = body["endDate"]
+					writeJSON(t, w, http.StatusOK, map[string]any{"id": "sub_VXJBYgP2u0eO"})
+				},
+			})
+
+			if err := provider.Cancel(context.Background(), "sub_VXJBYgP2u0eO", tt.atPeriodEnd); err != nil {
+				t.Fatal(err)
+			}
+
+			if deleted == tt.atPeriodEnd {
+				t.Errorf("deleted = %v, want %v", deleted, !tt.atPeriodEnd)
+			}
+			if endDate != tt.wantEndDate {
+				t.Errorf("endDate = %q, want %q", endDate, tt.wantEndDate)
+			}
+		})
+	}
+}
+
+func TestAsaasAPIError(t *testing.T) {
+	provider := newFakeAsaas(t, map[string]http.HandlerFunc{
+		"DELETE /v3/subscriptions/sub_missing": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusBadRequest, map[string]any{
+				"errors": []map[string]string{{"code": "invalid_action", "description": "Assinatura inexistente."}},
+			})
+		},
+	})
+
+	err := provider.Cancel(context.Background(), "sub_missing", false)
+	if err == nil || err.Error() != "asaas DELETE /v3/subscriptions/sub_missing: Assinatura inexistente." {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package payments
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/enums/subscription"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Provedores aceitos em plans.provider e subscriptions.provider
+const (
+	STRIPE = "stripe"
+	ASAAS  = "asaas"
+	// Assinaturas geridas pelo próprio HareID, como o período de teste das equipes
+	LOCAL = "hareid"
+)
+
+var (
+	// A operação não existe no provedor da assinatura
+	ErrUnsupported = apperrors.Invalid("unsupported_operation", "operation not supported by the payment provider")
+	// O webhook não foi enviado pelo provedor
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+type CheckoutRequest struct {
+	UserID  uint64
+	TeamID  *uint64
+	Name    string
+	CpfCnpj string
+	// Cliente já cadastrado no provedor, quando houver
+	CustomerID string
+	PriceID    string
+	// Valor unitário em centavos, usado por provedores sem catálogo de preços
+	Amount     int64
+	Quantity   int64
+	SuccessURL string
+	CancelURL  string
+}
+
+type CheckoutSession struct {
+	URL string
+	// Preenchidos quando o provedor já cria a assinatura no checkout
+	CustomerID     string
+	SubscriptionID string
+}
+
+// Evento de webhook já verificado, no formato gravado na fila
+type WebhookEvent struct {
+	ID        string
+	Type      string
+	ObjectID  string
+	CreatedAt time.Time
+	Payload   []byte
+}
+
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (CheckoutSession, error)
+	ParseWebhook(payload []byte, header http.Header) (WebhookEvent, error)
+	MapStatus(status string) subscription.Subscription
+	Cancel(ctx context.Context, subscriptionID string, atPeriodEnd bool) error
+}
+
+// Provedores configurados, indexados pelo nome
+type Registry map[string]PaymentProvider
+
+// Busca o provedor pelo nome. Registros antigos, sem provedor, são do Stripe
+func (r Registry) Get(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = STRIPE
+	}
+
+	provider, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not configured", name)
+	}
+
+	return provider, nil
+}
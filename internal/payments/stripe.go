@@ -0,0 +1,138 @@
+package payments
+
+import (
+	"HareID/internal/enums/subscription"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
+	stripeWebhook "github.com/stripe/stripe-go/v79/webhook"
+)
+
+type StripeProvider struct {
+	client        *client.API
+	webhookSecret string
+}
+
+func NewStripe(sc *client.API, webhookSecret string) *StripeProvider {
+	return &StripeProvider{client: sc, webhookSecret: webhookSecret}
+}
+
+func (p *StripeProvider) Name() string {
+	return STRIPE
+}
+
+func (p *StripeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (CheckoutSession, error) {
+	params := &stripe.CheckoutSessionParams{
+		PaymentMethodTypes: stripe.StringSlice([]string{
+			"card",
+		}),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(req.PriceID),
+				Quantity: stripe.Int64(req.Quantity),
+			},
+		},
+		Mode:              stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		SuccessURL:        stripe.String(req.SuccessURL),
+		CancelURL:         stripe.String(req.CancelURL),
+		ClientReferenceID: stripe.String(fmt.Sprintf("%d", req.UserID)),
+	}
+	params.Context = ctx
+
+	// Reaproveita o cliente do Stripe para não duplicar cadastros
+	if req.CustomerID != "" {
+		params.Customer = stripe.String(req.CustomerID)
+	}
+
+	// Assinaturas de equipe levam o team_id para o webhook
+	if req.TeamID != nil {
+		params.SubscriptionData = &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: map[string]string{
+				"team_id": fmt.Sprintf("%d", *req.TeamID),
+			},
+		}
+	}
+
+	sess, err := p.client.CheckoutSessions.New(params)
+	if err != nil {
+		return CheckoutSession{}, err
+	}
+
+	return CheckoutSession{URL: sess.URL}, nil
+}
+
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+
+	event, err := stripeWebhook.ConstructEvent(payload, header.Get("Stripe-Signature"), p.webhookSecret)
+	if err != nil {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	parsed := WebhookEvent{
+		ID:        event.ID,
+		Type:      string(event.Type),
+		CreatedAt: time.Unix(event.Created, 0),
+		Payload:   payload,
+	}
+
+	if event.Data != nil {
+		if id, ok := event.Data.Object["id"].(string); ok {
+			parsed.ObjectID = id
+		}
+	}
+
+	return parsed, nil
+}
+
+func (p *StripeProvider) MapStatus(status string) subscription.Subscription {
+	return MapStripeStatus(status)
+}
+
+func (p *StripeProvider) Cancel(ctx context.Context, subscriptionID string, atPeriodEnd bool) error {
+
+	if atPeriodEnd {
+		params := &stripe.SubscriptionParams{
+			CancelAtPeriodEnd: stripe.Bool(true),
+		}
+		params.Context = ctx
+
+		_, err := p.client.Subscriptions.Update(subscriptionID, params)
+		return err
+	}
+
+	params := &stripe.SubscriptionCancelParams{
+		Prorate: stripe.Bool(true),
+	}
+	params.Context = ctx
+
+	_, err := p.client.Subscriptions.Cancel(subscriptionID, params)
+	return err
+}
+
+func MapStripeStatus(status string) subscription.Subscription {
+	switch strings.ToLower(status) {
+	case "active":
+		return subscription.ACTIVE
+	case "canceled":
+		return subscription.CANCELED
+	case "incomplete":
+		return subscription.INCOMPLETE
+	case "incomplete_expired":
+		return subscription.INCOMPLETE_EXPIRED
+	case "past_due":
+		return subscription.PAST_DUE
+	case "unpaid":
+		return subscription.UNPAID
+	case "trialing":
+		return subscription.TRIALING
+	case "paused":
+		return subscription.INACTIVE
+	default:
+		return subscription.UNKNOWN
+	}
+}
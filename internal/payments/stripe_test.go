@@ -0,0 +1,31 @@
+package payments
+
+import (
+	"HareID/internal/enums/subscription"
+	"testing"
+)
+
+func TestMapStripeStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   subscription.Subscription
+	}{
+		{"active", subscription.ACTIVE},
+		{"ACTIVE", subscription.ACTIVE},
+		{"canceled", subscription.CANCELED},
+		{"incomplete", subscription.INCOMPLETE},
+		{"incomplete_expired", subscription.INCOMPLETE_EXPIRED},
+		{"past_due", subscription.PAST_DUE},
+		{"unpaid", subscription.UNPAID},
+		{"trialing", subscription.TRIALING},
+		{"paused", subscription.INACTIVE},
+		{"", subscription.UNKNOWN},
+		{"something_new", subscription.UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		if got := MapStripeStatus(tt.status); got != tt.want {
+			t.Errorf("MapStripeStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
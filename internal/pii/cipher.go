@@ -0,0 +1,185 @@
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Prefixo dos valores cifrados. Valores sem ele são texto puro anterior à criptografia
+const prefix = "enc:v1:"
+
+// Criptografia envelope: cada valor ganha uma chave de dados própria, embrulhada pela chave mestra.
+// Formato gravado: enc:v1:<id da chave mestra>:<chave de dados embrulhada>:<valor cifrado>
+type Cipher struct {
+	keys     KeyProvider
+	indexKey []byte
+}
+
+func NewCipher(keys KeyProvider, indexKey []byte) (*Cipher, error) {
+	if len(indexKey) < 32 {
+		return nil, errors.New("pii: blind index key must have at least 32 bytes")
+	}
+
+	return &Cipher{keys: keys, indexKey: indexKey}, nil
+}
+
+func (c *Cipher) ActiveKeyID() string {
+	return c.keys.ActiveKeyID()
+}
+
+// Cifra o valor com uma nova chave de dados. Vazio continua vazio
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	keyID := c.keys.ActiveKeyID()
+
+	wrapped, err := c.keys.Wrap(keyID, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return format(keyID, wrapped, sealed), nil
+}
+
+// Decifra o valor gravado. Texto puro legado é devolvido como está
+func (c *Cipher) Decrypt(stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+
+	keyID, wrapped, sealed, err := parse(stored)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := c.keys.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataKey, sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Reembrulha a chave de dados com a chave mestra ativa, sem tocar no valor cifrado.
+// Texto puro legado é cifrado. Retorna false quando o valor já está na chave ativa
+func (c *Cipher) Rotate(stored string) (string, bool, error) {
+	if stored == "" {
+		return "", false, nil
+	}
+
+	if !IsEncrypted(stored) {
+		encrypted, err := c.Encrypt(stored)
+		return encrypted, err == nil, err
+	}
+
+	keyID, wrapped, sealed, err := parse(stored)
+	if err != nil {
+		return "", false, err
+	}
+
+	active := c.keys.ActiveKeyID()
+	if keyID == active {
+		return stored, false, nil
+	}
+
+	dataKey, err := c.keys.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+
+	rewrapped, err := c.keys.Wrap(active, dataKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	return format(active, rewrapped, sealed), true, nil
+}
+
+// Índice cego para buscas exatas: HMAC do valor normalizado, sem revelar o valor
+func (c *Cipher) BlindIndex(plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(Normalize(plaintext)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Chave mestra usada no valor gravado. Vazio para texto puro
+func KeyID(stored string) string {
+	if !IsEncrypted(stored) {
+		return ""
+	}
+
+	keyID, _, _, err := parse(stored)
+	if err != nil {
+		return ""
+	}
+
+	return keyID
+}
+
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+// Remove pontuação e espaços e deixa em maiúsculas, para "123.456.789-09" e "12345678909" terem o mesmo índice
+func Normalize(value string) string {
+	var normalized strings.Builder
+
+	for _, r := range strings.ToUpper(value) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') {
+			normalized.WriteRune(r)
+		}
+	}
+
+	return normalized.String()
+}
+
+func format(keyID string, wrapped, sealed []byte) string {
+	return prefix + keyID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+func parse(stored string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(stored, prefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", nil, nil, errors.New("pii: malformed encrypted value")
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return parts[0], wrapped, sealed, nil
+}
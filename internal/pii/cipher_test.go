@@ -0,0 +1,210 @@
+package pii
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, active string) *Cipher {
+	t.Helper()
+
+	keyring, err := NewLocalKeyring(map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 32),
+		"v2": bytes.Repeat([]byte{2}, 32),
+	}, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err := NewCipher(keyring, bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cipher
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	cipher := newTestCipher(t, "v1")
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"cpf", "52998224725"},
+		{"formatted cnpj", "11.222.333/0001-81"},
+		{"alphanumeric cnpj", "12ABC34501DE35"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := cipher.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.plaintext == "" {
+				if stored != "" {
+					t.Fatalf("Encrypt(\"\") = %q, want empty", stored)
+				}
+				return
+			}
+
+			if !IsEncrypted(stored) || strings.Contains(stored, tt.plaintext) {
+				t.Fatalf("stored value %q is not encrypted", stored)
+			}
+			if got := KeyID(stored); got != "v1" {
+				t.Errorf("KeyID = %q, want v1", got)
+			}
+
+			got, err := cipher.Decrypt(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.plaintext {
+				t.Errorf("Decrypt = %q, want %q", got, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestCipherDecrypt(t *testing.T) {
+	cipher := newTestCipher(t, "v1")
+
+	stored, err := cipher.Encrypt("52998224725")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(stored, ":")
+
+	tests := []struct {
+		name    string
+		stored  string
+		want    string
+		wantErr bool
+	}{
+		{"legacy plaintext", "52998224725", "52998224725", false},
+		{"malformed", prefix + "v1:abc", "", true},
+		{"unknown master key", prefix + "v9:" + parts[3] + ":" + parts[4], "", true},
+		{"tampered ciphertext", stored[:len(stored)-2] + "AA", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cipher.Decrypt(tt.stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCipherRotate(t *testing.T) {
+	old := newTestCipher(t, "v1")
+	current := newTestCipher(t, "v2")
+
+	onOldKey, err := old.Encrypt("52998224725")
+	if err != nil {
+		t.Fatal(err)
+	}
+	onActiveKey, err := current.Encrypt("52998224725")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		stored      string
+		wantChanged bool
+		wantKeyID   string
+		wantErr     bool
+	}{
+		{"old key is rewrapped", onOldKey, true, "v2", false},
+		{"active key is kept", onActiveKey, false, "v2", false},
+		{"legacy plaintext is encrypted", "52998224725", true, "v2", false},
+		{"empty stays empty", "", false, "", false},
+		{"malformed fails", prefix + "v1", false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rotated, changed, err := current.Rotate(tt.stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := KeyID(rotated); got != tt.wantKeyID {
+				t.Errorf("KeyID = %q, want %q", got, tt.wantKeyID)
+			}
+
+			if tt.stored == "" {
+				return
+			}
+
+			plaintext, err := current.Decrypt(rotated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext != "52998224725" {
+				t.Errorf("Decrypt = %q", plaintext)
+			}
+		})
+	}
+
+	// Só a chave de dados muda: o valor cifrado é o mesmo
+	rotated, _, err := current.Rotate(onOldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldParts, newParts := strings.Split(onOldKey, ":"), strings.Split(rotated, ":"); oldParts[4] != newParts[4] {
+		t.Error("rotation changed the sealed value")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	cipher := newTestCipher(t, "v1")
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"punctuation is ignored", "529.982.247-25", "52998224725", true},
+		{"case is ignored", "12abc34501de35", "12.ABC.345/01DE-35", true},
+		{"different documents", "52998224725", "11144477735", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cipher.BlindIndex(tt.a) == cipher.BlindIndex(tt.b); got != tt.equal {
+				t.Errorf("BlindIndex(%q) == BlindIndex(%q) is %v, want %v", tt.a, tt.b, got, tt.equal)
+			}
+		})
+	}
+
+	if cipher.BlindIndex("") != "" {
+		t.Error("BlindIndex of empty value should be empty")
+	}
+}
+
+func TestNewCipherRejectsShortIndexKey(t *testing.T) {
+	keyring, err := NewLocalKeyring(map[string][]byte{"v1": bytes.Repeat([]byte{1}, 32)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewCipher(keyring, []byte("short")); err == nil {
+		t.Fatal("expected an error for a short index key")
+	}
+}
@@ -0,0 +1,117 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Guarda as chaves mestras e embrulha as chaves de dados. Um KMS externo pode implementar a mesma interface
+type KeyProvider interface {
+	// Chave usada para embrulhar as novas chaves de dados
+	ActiveKeyID() string
+	Wrap(keyID string, dataKey []byte) ([]byte, error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Substituto local do KMS: chaves mestras AES-256 carregadas da configuração
+type LocalKeyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+func NewLocalKeyring(keys map[string][]byte, active string) (*LocalKeyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("pii: no master key configured")
+	}
+
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("pii: invalid master key id %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("pii: master key %q must have 32 bytes, got %d", id, len(key))
+		}
+	}
+
+	if active == "" {
+		// Sem chave ativa explícita, vale a última em ordem alfabética (v1, v2, ...)
+		ids := make([]string, 0, len(keys))
+		for id := range keys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		active = ids[len(ids)-1]
+	}
+
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("pii: active key %q is not configured", active)
+	}
+
+	return &LocalKeyring{keys: keys, active: active}, nil
+}
+
+func (k *LocalKeyring) ActiveKeyID() string {
+	return k.active
+}
+
+func (k *LocalKeyring) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("pii: unknown master key %q", keyID)
+	}
+
+	return seal(master, dataKey)
+}
+
+func (k *LocalKeyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("pii: unknown master key %q", keyID)
+	}
+
+	return open(master, wrapped)
+}
+
+// AES-256-GCM com o nonce no início do resultado
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("pii: ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
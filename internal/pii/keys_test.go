@@ -0,0 +1,82 @@
+package pii
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewLocalKeyring(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name       string
+		keys       map[string][]byte
+		active     string
+		wantActive string
+		wantErr    bool
+	}{
+		{"explicit active key", map[string][]byte{"v1": key, "v2": key}, "v1", "v1", false},
+		{"last id is the default", map[string][]byte{"v1": key, "v2": key}, "", "v2", false},
+		{"no keys", map[string][]byte{}, "", "", true},
+		{"short key", map[string][]byte{"v1": key[:16]}, "", "", true},
+		{"id with colon", map[string][]byte{"v:1": key}, "", "", true},
+		{"unknown active key", map[string][]byte{"v1": key}, "v2", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := NewLocalKeyring(tt.keys, tt.active)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && keyring.ActiveKeyID() != tt.wantActive {
+				t.Errorf("ActiveKeyID = %q, want %q", keyring.ActiveKeyID(), tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestLocalKeyringWrap(t *testing.T) {
+	keyring, err := NewLocalKeyring(map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 32),
+		"v2": bytes.Repeat([]byte{2}, 32),
+	}, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey := bytes.Repeat([]byte{7}, 32)
+
+	wrapped, err := keyring.Wrap("v1", dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyID   string
+		wrapped []byte
+		wantErr bool
+	}{
+		{"same key", "v1", wrapped, false},
+		{"other key", "v2", wrapped, true},
+		{"unknown key", "v9", wrapped, true},
+		{"too short", "v1", wrapped[:4], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.Unwrap(tt.keyID, tt.wrapped)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, dataKey) {
+				t.Error("unwrapped key differs from the original")
+			}
+		})
+	}
+
+	if _, err := keyring.Wrap("v9", dataKey); err == nil {
+		t.Error("expected an error wrapping with an unknown key")
+	}
+}
@@ -0,0 +1,24 @@
+package pii
+
+import "strings"
+
+// Mascara CPF/CNPJ mantendo só o final visível: ***.***.123-45 e **.***.***/0001-95.
+// Outros formatos ficam com os 4 últimos caracteres
+func MaskCpfCnpj(value string) string {
+	normalized := Normalize(value)
+
+	switch len(normalized) {
+	case 0:
+		return ""
+	case 11:
+		return "***.***." + normalized[6:9] + "-" + normalized[9:]
+	case 14:
+		return "**.***.***/" + normalized[8:12] + "-" + normalized[12:]
+	}
+
+	if len(normalized) <= 4 {
+		return strings.Repeat("*", len(normalized))
+	}
+
+	return strings.Repeat("*", len(normalized)-4) + normalized[len(normalized)-4:]
+}
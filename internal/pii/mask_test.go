@@ -0,0 +1,42 @@
+package pii
+
+import "testing"
+
+func TestMaskCpfCnpj(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"52998224725", "***.***.247-25"},
+		{"529.982.247-25", "***.***.247-25"},
+		{"11222333000181", "**.***.***/0001-81"},
+		{"11.222.333/0001-81", "**.***.***/0001-81"},
+		{"12.ABC.345/01DE-35", "**.***.***/01DE-35"},
+		{"123456", "**3456"},
+		{"123", "***"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := MaskCpfCnpj(tt.value); got != tt.want {
+			t.Errorf("MaskCpfCnpj(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"529.982.247-25", "52998224725"},
+		{" 12.abc.345/01de-35 ", "12ABC34501DE35"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.value); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"HareID/internal/enums"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"context"
 	"errors"
@@ -29,51 +31,47 @@ func (r *TeamMembersRepository) Create(ctx context.Context, tx pgx.Tx, teamMembe
 		&teamMember.ID,
 		&teamMember.CreatedAt,
 	); err != nil {
-		return models.TeamMember{}, err
+		return models.TeamMember{}, translate(err)
 	}
 
 	return teamMember, nil
 
 }
 
-func (r *TeamMembersRepository) GetAll(ctx context.Context, teamID uint64) ([]models.TeamMember, error) {
+// Campos aceitos em GET /teams/{team_id}/members
+var teamMemberList = listquery.Resource[models.TeamMember]{
+	Fields: map[string]listquery.Field[models.TeamMember]{
+		"id":         {Column: "tm.id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(m models.TeamMember) any { return m.ID }},
+		"user_id":    {Column: "tm.user_id", Type: listquery.Int, Filterable: true},
+		"role":       {Column: "tm.role", Type: listquery.Int, Filterable: true},
+		"name":       {Column: "u.name", Type: listquery.String, Filterable: true, Sortable: true, Value: func(m models.TeamMember) any { return m.Name }},
+		"created_at": {Column: "tm.created_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(m models.TeamMember) any { return m.CreatedAt }},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+func (r *TeamMembersRepository) GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.TeamMember], error) {
 
 	query := `
 		SELECT tm.id, tm.role, tm.user_id, tm.created_at, u.name FROM teammembers tm
 		INNER JOIN users u on u.id = tm.user_id
-		INNER JOIN teams t on t.id = tm.team_id
-		WHERE team_id = $1
+		WHERE tm.team_id = $1
 	`
 
-	rows, err := r.db.Query(
-		ctx,
-		query,
-		teamID,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var members []models.TeamMember
-
-	for rows.Next() {
+	return listquery.Fetch(ctx, r.db, teamMemberList, params, query, []any{teamID}, func(row pgx.Row) (models.TeamMember, error) {
 		var member models.TeamMember
 
-		if err := rows.Scan(
+		err := row.Scan(
 			&member.ID,
 			&member.Role,
 			&member.UserID,
 			&member.CreatedAt,
 			&member.Name,
-		); err != nil {
-			return nil, err
-		}
+		)
 
-		members = append(members, member)
-	}
-
-	return members, nil
+		return member, err
+	})
 }
 
 func (r *TeamMembersRepository) GetByUserID(ctx context.Context, userID uint64) (models.TeamMember, error) {
@@ -96,7 +94,7 @@ func (r *TeamMembersRepository) GetByUserID(ctx context.Context, userID uint64)
 		&teamMember.TeamName,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.TeamMember{}, errors.New("team member not found")
+			return models.TeamMember{}, ErrTeamMemberNotFound
 		}
 		return models.TeamMember{}, err
 	}
@@ -104,3 +102,110 @@ func (r *TeamMembersRepository) GetByUserID(ctx context.Context, userID uint64)
 	return teamMember, nil
 
 }
+
+// Lista as equipes de que o usuário participa
+func (r *TeamMembersRepository) GetAllByUserID(ctx context.Context, userID uint64) ([]models.TeamMember, error) {
+
+	query := `
+		SELECT tm.id, tm.team_id, tm.role, tm.user_id, tm.created_at, t.name FROM teammembers tm
+		INNER JOIN teams t on t.id = tm.team_id
+		WHERE tm.user_id = $1
+		ORDER BY tm.created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.TeamMember
+
+	for rows.Next() {
+		var teamMember models.TeamMember
+
+		if err := rows.Scan(
+			&teamMember.ID,
+			&teamMember.TeamID,
+			&teamMember.Role,
+			&teamMember.UserID,
+			&teamMember.CreatedAt,
+			&teamMember.TeamName,
+		); err != nil {
+			return nil, err
+		}
+
+		members = append(members, teamMember)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *TeamMembersRepository) Exists(ctx context.Context, teamID, userID uint64) (bool, error) {
+
+	query := `
+		SELECT EXISTS(SELECT 1 FROM teammembers WHERE team_id = $1 AND user_id = $2)
+	`
+
+	var exists bool
+
+	if err := r.db.QueryRow(ctx, query, teamID, userID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r *TeamMembersRepository) CountByTeamID(ctx context.Context, teamID uint64) (uint64, error) {
+
+	query := `
+		SELECT COUNT(*) FROM teammembers WHERE team_id = $1
+	`
+
+	var count uint64
+
+	if err := r.db.QueryRow(ctx, query, teamID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *TeamMembersRepository) UpdateRole(ctx context.Context, tx pgx.Tx, teamID, userID uint64, role enums.TeamRole) (uint64, error) {
+
+	query := `
+		UPDATE teammembers
+		SET role = $1
+		WHERE team_id = $2 AND user_id = $3
+	`
+
+	result, err := tx.Exec(ctx, query, role, teamID, userID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrTeamMemberNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+func (r *TeamMembersRepository) Delete(ctx context.Context, tx pgx.Tx, teamID, userID uint64) (uint64, error) {
+
+	query := `
+		DELETE FROM teammembers
+		WHERE team_id = $1 AND user_id = $2
+	`
+
+	result, err := tx.Exec(ctx, query, teamID, userID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrTeamMemberNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/models"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AccountDeletionRepository struct {
+	db *pgxpool.Pool
+}
+
+const accountDeletionColumns = `
+	id, user_id, status, team_policy, requested_at, scheduled_for, canceled_at, completed_at, attempts, COALESCE(last_error, '')
+`
+
+func (r *AccountDeletionRepository) Create(ctx context.Context, tx pgx.Tx, deletion models.AccountDeletion) (models.AccountDeletion, error) {
+
+	query := `
+		INSERT INTO account_deletions (user_id, status, team_policy, scheduled_for)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, status, requested_at
+	`
+
+	if err := tx.QueryRow(ctx, query,
+		deletion.UserID,
+		enums.DELETION_SCHEDULED,
+		deletion.TeamPolicy,
+		deletion.ScheduledFor,
+	).Scan(
+		&deletion.ID,
+		&deletion.Status,
+		&deletion.RequestedAt,
+	); err != nil {
+		return models.AccountDeletion{}, translate(err)
+	}
+
+	return deletion, nil
+}
+
+// Pedido mais recente do usuário, em qualquer situação
+func (r *AccountDeletionRepository) GetLatestByUserID(ctx context.Context, userID uint64) (models.AccountDeletion, error) {
+
+	query := `
+		SELECT ` + accountDeletionColumns + ` FROM account_deletions
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+	defer rows.Close()
+
+	deletions, err := scanAccountDeletions(rows)
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	if len(deletions) == 0 {
+		return models.AccountDeletion{}, ErrAccountDeletionNotFound
+	}
+
+	return deletions[0], nil
+}
+
+// Cancela o pedido agendado. Depois do prazo a exclusão já pode estar em andamento e não é mais cancelada
+func (r *AccountDeletionRepository) Cancel(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error) {
+
+	query := `
+		UPDATE account_deletions
+		SET status = $1, canceled_at = NOW()
+		WHERE user_id = $2 AND status = $3 AND scheduled_for > NOW()
+	`
+
+	result, err := tx.Exec(ctx, query, enums.DELETION_CANCELED, userID, enums.DELETION_SCHEDULED)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+// Reserva os pedidos com prazo vencido por um tempo de lease
+func (r *AccountDeletionRepository) ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.AccountDeletion, error) {
+
+	query := `
+		UPDATE account_deletions
+		SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM account_deletions
+			WHERE status = $2 AND scheduled_for <= NOW() AND COALESCE(next_attempt_at, scheduled_for) <= NOW()
+			ORDER BY scheduled_for
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + accountDeletionColumns
+
+	rows, err := tx.Query(ctx, query, lease.Seconds(), enums.DELETION_SCHEDULED, limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	return scanAccountDeletions(rows)
+}
+
+func (r *AccountDeletionRepository) MarkRetry(ctx context.Context, tx pgx.Tx, deletionID uint64, lastError string, nextAttemptAt time.Time) error {
+
+	query := `
+		UPDATE account_deletions
+		SET last_error = $1, next_attempt_at = $2
+		WHERE id = $3
+	`
+
+	_, err := tx.Exec(ctx, query, lastError, nextAttemptAt, deletionID)
+	return err
+}
+
+func (r *AccountDeletionRepository) Complete(ctx context.Context, tx pgx.Tx, deletionID uint64) (bool, error) {
+
+	query := `
+		UPDATE account_deletions
+		SET status = $1, completed_at = NOW(), last_error = NULL, next_attempt_at = NULL
+		WHERE id = $2 AND status = $3
+	`
+
+	result, err := tx.Exec(ctx, query, enums.DELETION_COMPLETED, deletionID, enums.DELETION_SCHEDULED)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *AccountDeletionRepository) CreateTombstone(ctx context.Context, tx pgx.Tx, tombstone models.UserTombstone) error {
+
+	query := `
+		INSERT INTO user_tombstones (user_id, identity_hash, team_policy, teams_transferred, teams_dissolved, requested_at, deleted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	_, err := tx.Exec(ctx, query,
+		tombstone.UserID,
+		tombstone.IdentityHash,
+		tombstone.TeamPolicy,
+		tombstone.TeamsTransferred,
+		tombstone.TeamsDissolved,
+		tombstone.RequestedAt,
+	)
+	return err
+}
+
+func scanAccountDeletions(rows pgx.Rows) ([]models.AccountDeletion, error) {
+
+	var deletions []models.AccountDeletion
+
+	for rows.Next() {
+		var deletion models.AccountDeletion
+
+		if err := rows.Scan(
+			&deletion.ID,
+			&deletion.UserID,
+			&deletion.Status,
+			&deletion.TeamPolicy,
+			&deletion.RequestedAt,
+			&deletion.ScheduledFor,
+			&deletion.CanceledAt,
+			&deletion.CompletedAt,
+			&deletion.Attempts,
+			&deletion.LastError,
+		); err != nil {
+			return nil, err
+		}
+
+		deletions = append(deletions, deletion)
+	}
+
+	return deletions, rows.Err()
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"HareID/internal/audit"
+	"HareID/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditLogRepository struct {
+	db *pgxpool.Pool
+}
+
+const auditLogColumns = `
+	id, actor_id, action, target_type, target_id, team_id, user_id, before, after,
+	COALESCE(ip, ''), COALESCE(request_id, ''), created_at, prev_hash, hash
+`
+
+// Acrescenta a entrada ao fim da cadeia. O lock da transação serializa as gravações para que cada entrada
+// aponte para a anterior; ele só é liberado no commit ou rollback, por isso os serviços chamam Create
+// no fim da transação auditada (ver auditedTx), e não no meio da alteração
+func (r *AuditLogRepository) Create(ctx context.Context, tx pgx.Tx, entry models.AuditLog) (models.AuditLog, error) {
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_logs'))`); err != nil {
+		return models.AuditLog{}, translate(err)
+	}
+
+	err := tx.QueryRow(ctx, `SELECT hash FROM audit_logs ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return models.AuditLog{}, err
+	}
+
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.Hash = audit.Hash(entry)
+
+	query := `
+		INSERT INTO audit_logs (actor_id, action, target_type, target_id, team_id, user_id, before, after, ip, request_id, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13)
+		RETURNING id
+	`
+
+	err = tx.QueryRow(ctx, query,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.TeamID,
+		entry.UserID,
+		nullableJSON(entry.Before),
+		nullableJSON(entry.After),
+		entry.IP,
+		entry.RequestID,
+		entry.CreatedAt,
+		entry.PrevHash,
+		entry.Hash,
+	).Scan(&entry.ID)
+	if err != nil {
+		return models.AuditLog{}, translate(err)
+	}
+
+	return entry, nil
+}
+
+// Entradas mais recentes primeiro. UserID traz as ações feitas pelo usuário ou que o afetaram
+func (r *AuditLogRepository) GetAll(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, error) {
+
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE TRUE`
+	var args []any
+
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.TeamID != nil {
+		query += ` AND team_id = ` + arg(*filter.TeamID)
+	}
+	if filter.UserID != nil {
+		placeholder := arg(*filter.UserID)
+		query += ` AND (user_id = ` + placeholder + ` OR actor_id = ` + placeholder + `)`
+	}
+	if filter.ActorID != nil {
+		query += ` AND actor_id = ` + arg(*filter.ActorID)
+	}
+	if filter.Action != "" {
+		// Prefixo literal: "_" e "%" no filtro não viram curingas
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.Action)
+		query += ` AND action LIKE ` + arg(escaped+"%")
+	}
+	if filter.From != nil {
+		query += ` AND created_at >= ` + arg(*filter.From)
+	}
+	if filter.To != nil {
+		query += ` AND created_at < ` + arg(*filter.To)
+	}
+	if filter.BeforeID > 0 {
+		query += ` AND id < ` + arg(filter.BeforeID)
+	}
+
+	query += ` ORDER BY id DESC LIMIT ` + arg(filter.Limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditLogs(rows)
+}
+
+// Trecho da cadeia em ordem de gravação, para a verificação dos hashes
+func (r *AuditLogRepository) GetChain(ctx context.Context, afterID uint64, limit int) ([]models.AuditLog, error) {
+
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE id > $1 ORDER BY id LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditLogs(rows)
+}
+
+func scanAuditLogs(rows pgx.Rows) ([]models.AuditLog, error) {
+
+	var entries []models.AuditLog
+
+	for rows.Next() {
+		var entry models.AuditLog
+
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.TeamID,
+			&entry.UserID,
+			&entry.Before,
+			&entry.After,
+			&entry.IP,
+			&entry.RequestID,
+			&entry.CreatedAt,
+			&entry.PrevHash,
+			&entry.Hash,
+		); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// A coluna é json (não jsonb) para guardar o texto exato usado no hash
+func nullableJSON(value json.RawMessage) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"HareID/internal/models"
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type BillingHistoryRepository struct {
+	db *pgxpool.Pool
+}
+
+// Registra a transição uma única vez por chave. Retorna false se ela já havia sido aplicada
+func (r *BillingHistoryRepository) Create(ctx context.Context, tx pgx.Tx, history models.BillingHistory, dedupeKey string) (bool, error) {
+
+	query := `
+		INSERT INTO billing_history (subscription_id, user_id, team_id, event, detail, dedupe_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (subscription_id, dedupe_key) DO NOTHING
+	`
+
+	result, err := tx.Exec(ctx, query,
+		history.SubscriptionID,
+		history.UserID,
+		history.TeamID,
+		history.Event,
+		history.Detail,
+		dedupeKey,
+	)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *BillingHistoryRepository) GetByUserID(ctx context.Context, userID uint64) ([]models.BillingHistory, error) {
+
+	query := `
+		SELECT id, subscription_id, user_id, team_id, event, detail, created_at
+		FROM billing_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.BillingHistory
+
+	for rows.Next() {
+		var entry models.BillingHistory
+
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.SubscriptionID,
+			&entry.UserID,
+			&entry.TeamID,
+			&entry.Event,
+			&entry.Detail,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
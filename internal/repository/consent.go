@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"HareID/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TermsVersionRepository struct {
+	db *pgxpool.Pool
+}
+
+type ConsentRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *TermsVersionRepository) Create(ctx context.Context, tx pgx.Tx, version models.TermsVersion) (models.TermsVersion, error) {
+
+	query := `
+		INSERT INTO terms_versions (purpose, version, content_url, summary, published_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	if err := tx.QueryRow(ctx, query,
+		version.Purpose,
+		version.Version,
+		version.ContentURL,
+		version.Summary,
+		version.PublishedAt,
+	).Scan(&version.ID); err != nil {
+		return models.TermsVersion{}, translate(err)
+	}
+
+	return version, nil
+}
+
+// Versão vigente de cada finalidade: a mais recente já publicada
+func (r *TermsVersionRepository) GetCurrent(ctx context.Context) ([]models.TermsVersion, error) {
+
+	query := `
+		SELECT DISTINCT ON (purpose) id, purpose, version, content_url, COALESCE(summary, ''), published_at
+		FROM terms_versions
+		WHERE published_at <= NOW()
+		ORDER BY purpose, published_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []models.TermsVersion
+
+	for rows.Next() {
+		var version models.TermsVersion
+
+		if err := rows.Scan(
+			&version.ID,
+			&version.Purpose,
+			&version.Version,
+			&version.ContentURL,
+			&version.Summary,
+			&version.PublishedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+func (r *TermsVersionRepository) GetByID(ctx context.Context, versionID uint64) (models.TermsVersion, error) {
+
+	query := `
+		SELECT id, purpose, version, content_url, COALESCE(summary, ''), published_at
+		FROM terms_versions
+		WHERE id = $1
+	`
+
+	var version models.TermsVersion
+
+	if err := r.db.QueryRow(ctx, query, versionID).Scan(
+		&version.ID,
+		&version.Purpose,
+		&version.Version,
+		&version.ContentURL,
+		&version.Summary,
+		&version.PublishedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.TermsVersion{}, ErrTermsVersionNotFound
+		}
+		return models.TermsVersion{}, err
+	}
+
+	return version, nil
+}
+
+func (r *ConsentRepository) Create(ctx context.Context, tx pgx.Tx, consent models.UserConsent) (models.UserConsent, error) {
+
+	query := `
+		INSERT INTO user_consents (user_id, purpose, terms_version_id, granted, ip, user_agent)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+		RETURNING id, created_at
+	`
+
+	if err := tx.QueryRow(ctx, query,
+		consent.UserID,
+		consent.Purpose,
+		consent.TermsVersionID,
+		consent.Granted,
+		consent.IP,
+		consent.UserAgent,
+	).Scan(&consent.ID, &consent.CreatedAt); err != nil {
+		return models.UserConsent{}, translate(err)
+	}
+
+	return consent, nil
+}
+
+// Último registro de cada finalidade, que define a situação atual do usuário
+func (r *ConsentRepository) GetLatestByUserID(ctx context.Context, userID uint64) ([]models.UserConsent, error) {
+
+	query := `
+		SELECT DISTINCT ON (purpose) id, user_id, purpose, terms_version_id, granted, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at
+		FROM user_consents
+		WHERE user_id = $1
+		ORDER BY purpose, created_at DESC, id DESC
+	`
+
+	return r.query(ctx, query, userID)
+}
+
+// Histórico completo, do mais recente para o mais antigo
+func (r *ConsentRepository) GetAllByUserID(ctx context.Context, userID uint64) ([]models.UserConsent, error) {
+
+	query := `
+		SELECT id, user_id, purpose, terms_version_id, granted, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at
+		FROM user_consents
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	return r.query(ctx, query, userID)
+}
+
+func (r *ConsentRepository) query(ctx context.Context, query string, args ...any) ([]models.UserConsent, error) {
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consents []models.UserConsent
+
+	for rows.Next() {
+		var consent models.UserConsent
+
+		if err := rows.Scan(
+			&consent.ID,
+			&consent.UserID,
+			&consent.Purpose,
+			&consent.TermsVersionID,
+			&consent.Granted,
+			&consent.IP,
+			&consent.UserAgent,
+			&consent.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/models"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DataExportRepository struct {
+	db *pgxpool.Pool
+}
+
+const dataExportColumns = `
+	id, user_id, status, include_csv, COALESCE(file_path, ''), COALESCE(error, ''), created_at, completed_at, expires_at
+`
+
+func (r *DataExportRepository) Create(ctx context.Context, tx pgx.Tx, export models.DataExport) (models.DataExport, error) {
+
+	query := `
+		INSERT INTO data_exports (user_id, status, include_csv)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at
+	`
+
+	if err := tx.QueryRow(ctx, query, export.UserID, enums.EXPORT_PENDING, export.IncludeCSV).Scan(
+		&export.ID,
+		&export.Status,
+		&export.CreatedAt,
+	); err != nil {
+		return models.DataExport{}, translate(err)
+	}
+
+	return export, nil
+}
+
+func (r *DataExportRepository) GetByID(ctx context.Context, exportID uint64) (models.DataExport, error) {
+
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, query, exportID)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	defer rows.Close()
+
+	exports, err := scanDataExports(rows)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if len(exports) == 0 {
+		return models.DataExport{}, ErrDataExportNotFound
+	}
+
+	return exports[0], nil
+}
+
+// Exportação ainda na fila ou em andamento, para não duplicar pedidos do mesmo usuário
+func (r *DataExportRepository) GetActiveByUserID(ctx context.Context, userID uint64) (models.DataExport, error) {
+
+	query := `
+		SELECT ` + dataExportColumns + ` FROM data_exports
+		WHERE user_id = $1 AND status IN ($2, $3)
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, enums.EXPORT_PENDING, enums.EXPORT_PROCESSING)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	defer rows.Close()
+
+	exports, err := scanDataExports(rows)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if len(exports) == 0 {
+		return models.DataExport{}, ErrDataExportNotFound
+	}
+
+	return exports[0], nil
+}
+
+// Reserva as exportações pendentes. As que ficaram em andamento além do lease (worker interrompido) voltam para a fila
+func (r *DataExportRepository) ClaimPending(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.DataExport, error) {
+
+	query := `
+		UPDATE data_exports
+		SET status = $1, started_at = NOW()
+		WHERE id IN (
+			SELECT id FROM data_exports
+			WHERE status = $2 OR (status = $1 AND started_at < NOW() - make_interval(secs => $3))
+			ORDER BY created_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + dataExportColumns
+
+	rows, err := tx.Query(ctx, query, enums.EXPORT_PROCESSING, enums.EXPORT_PENDING, lease.Seconds(), limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	return scanDataExports(rows)
+}
+
+func (r *DataExportRepository) MarkReady(ctx context.Context, tx pgx.Tx, exportID uint64, filePath string, expiresAt time.Time) error {
+
+	query := `
+		UPDATE data_exports
+		SET status = $1, file_path = $2, error = NULL, completed_at = NOW(), expires_at = $3
+		WHERE id = $4
+	`
+
+	result, err := tx.Exec(ctx, query, enums.EXPORT_READY, filePath, expiresAt, exportID)
+	if err != nil {
+		return err
+	}
+
+	// Apagada pela exclusão da conta enquanto o arquivo era gerado
+	if result.RowsAffected() == 0 {
+		return ErrDataExportNotFound
+	}
+
+	return nil
+}
+
+func (r *DataExportRepository) MarkFailed(ctx context.Context, tx pgx.Tx, exportID uint64, lastError string) error {
+
+	query := `
+		UPDATE data_exports
+		SET status = $1, error = $2, completed_at = NOW()
+		WHERE id = $3
+	`
+
+	_, err := tx.Exec(ctx, query, enums.EXPORT_FAILED, lastError, exportID)
+	return err
+}
+
+// Lista os arquivos prontos cuja validade já passou
+func (r *DataExportRepository) GetExpired(ctx context.Context) ([]models.DataExport, error) {
+
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE status = $1 AND expires_at <= NOW()`
+
+	rows, err := r.db.Query(ctx, query, enums.EXPORT_READY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDataExports(rows)
+}
+
+func (r *DataExportRepository) MarkExpired(ctx context.Context, tx pgx.Tx, exportID uint64) error {
+
+	query := `
+		UPDATE data_exports
+		SET status = $1, file_path = NULL
+		WHERE id = $2
+	`
+
+	_, err := tx.Exec(ctx, query, enums.EXPORT_EXPIRED, exportID)
+	return err
+}
+
+// Apaga todas as exportações do usuário (na fila, prontas ou vencidas) e devolve os arquivos a remover
+func (r *DataExportRepository) DeleteByUserID(ctx context.Context, tx pgx.Tx, userID uint64) ([]string, error) {
+
+	query := `DELETE FROM data_exports WHERE user_id = $1 RETURNING COALESCE(file_path, '')`
+
+	rows, err := tx.Query(ctx, query, userID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var files []string
+
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, err
+		}
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files, rows.Err()
+}
+
+func scanDataExports(rows pgx.Rows) ([]models.DataExport, error) {
+
+	var exports []models.DataExport
+
+	for rows.Next() {
+		var export models.DataExport
+
+		if err := rows.Scan(
+			&export.ID,
+			&export.UserID,
+			&export.Status,
+			&export.IncludeCSV,
+			&export.FilePath,
+			&export.Error,
+			&export.CreatedAt,
+			&export.CompletedAt,
+			&export.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
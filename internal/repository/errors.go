@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"HareID/internal/apperrors"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrUserNotFound            = apperrors.NotFound("user_not_found", "user not found")
+	ErrTeamNotFound            = apperrors.NotFound("team_not_found", "team not found")
+	ErrTeamMemberNotFound      = apperrors.NotFound("team_member_not_found", "team member not found")
+	ErrJoinRequestNotFound     = apperrors.NotFound("join_request_not_found", "join request not found")
+	ErrNotificationNotFound    = apperrors.NotFound("notification_not_found", "notification not found")
+	ErrSubscriptionNotFound    = apperrors.NotFound("subscription_not_found", "subscription not found")
+	ErrInvoiceNotFound         = apperrors.NotFound("invoice_not_found", "invoice not found")
+	ErrPlanNotFound            = apperrors.NotFound("plan_not_found", "plan not found")
+	ErrDataExportNotFound      = apperrors.NotFound("data_export_not_found", "data export not found")
+	ErrAccountDeletionNotFound = apperrors.NotFound("account_deletion_not_found", "account deletion not found")
+	ErrTermsVersionNotFound    = apperrors.NotFound("terms_version_not_found", "terms version not found")
+	ErrWebhookEventNotFound    = apperrors.NotFound("webhook_event_not_found", "webhook event not found")
+
+	// Pedido de entrada que já foi aceito ou recusado
+	ErrJoinRequestDecided = apperrors.Conflict("join_request_decided", "request already accepted or rejected")
+
+	// Violação de restrição única: outro registro já tem o mesmo valor
+	ErrAlreadyExists = apperrors.Conflict("already_exists", "a record with the same values already exists")
+	// Violação de chave estrangeira: o registro referenciado não existe ou ainda está em uso
+	ErrReferenceViolation = apperrors.Conflict("reference_violation", "the related record does not exist or is still in use")
+)
+
+// Traduz as violações de restrição do PostgreSQL para erros da aplicação. O erro original fica como causa,
+// para quem precisar do nome da restrição (ex: services.cpfCnpjConflict)
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case "23505":
+		return ErrAlreadyExists.Wrap(err)
+	case "23503":
+		return ErrReferenceViolation.Wrap(err)
+	default:
+		return err
+	}
+}
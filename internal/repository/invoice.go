@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"HareID/internal/enums/invoice"
+	"HareID/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type InvoiceRepository struct {
+	db *pgxpool.Pool
+}
+
+// Grava a fatura vinda de um evento. Eventos mais antigos que o último aplicado são descartados
+func (r *InvoiceRepository) Upsert(ctx context.Context, tx pgx.Tx, inv models.Invoice, eventAt time.Time) (models.Invoice, bool, error) {
+
+	query := `
+		INSERT INTO invoices (invoice_id, user_id, team_id, subscription_id, status, currency, amount_due, amount_paid,
+			hosted_invoice_url, period_start, period_end, created_at, last_event_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (invoice_id) DO UPDATE
+		SET status = EXCLUDED.status,
+			amount_due = EXCLUDED.amount_due,
+			amount_paid = EXCLUDED.amount_paid,
+			hosted_invoice_url = EXCLUDED.hosted_invoice_url,
+			last_event_at = EXCLUDED.last_event_at
+		WHERE invoices.last_event_at <= EXCLUDED.last_event_at
+		RETURNING id
+	`
+
+	err := tx.QueryRow(ctx, query,
+		inv.InvoiceID,
+		inv.UserID,
+		inv.TeamID,
+		inv.SubscriptionID,
+		inv.Status,
+		inv.Currency,
+		inv.AmountDue,
+		inv.AmountPaid,
+		inv.HostedInvoiceURL,
+		inv.PeriodStart,
+		inv.PeriodEnd,
+		inv.CreatedAt,
+		eventAt,
+	).Scan(&inv.ID)
+
+	if err != nil {
+		// Sem linha retornada o evento era antigo e a fatura já está atualizada
+		if errors.Is(err, pgx.ErrNoRows) {
+			return inv, false, nil
+		}
+		return models.Invoice{}, false, err
+	}
+
+	return inv, true, nil
+}
+
+func (r *InvoiceRepository) SetRefund(ctx context.Context, tx pgx.Tx, invoiceID string, amountRefunded int64, status invoice.Invoice) (uint64, error) {
+
+	query := `
+		UPDATE invoices
+		SET amount_refunded = $1, status = $2
+		WHERE invoice_id = $3
+	`
+
+	result, err := tx.Exec(ctx, query, amountRefunded, status, invoiceID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrInvoiceNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+func (r *InvoiceRepository) GetByUserID(ctx context.Context, userID uint64) ([]models.Invoice, error) {
+
+	query := `
+		SELECT id, invoice_id, user_id, team_id, subscription_id, status, currency, amount_due, amount_paid,
+			amount_refunded, hosted_invoice_url, period_start, period_end, created_at
+		FROM invoices
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []models.Invoice
+
+	for rows.Next() {
+		var inv models.Invoice
+
+		if err := rows.Scan(
+			&inv.ID,
+			&inv.InvoiceID,
+			&inv.UserID,
+			&inv.TeamID,
+			&inv.SubscriptionID,
+			&inv.Status,
+			&inv.Currency,
+			&inv.AmountDue,
+			&inv.AmountPaid,
+			&inv.AmountRefunded,
+			&inv.HostedInvoiceURL,
+			&inv.PeriodStart,
+			&inv.PeriodEnd,
+			&inv.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, nil
+}
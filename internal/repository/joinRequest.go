@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"context"
 	"errors"
@@ -31,13 +32,26 @@ func (r *JoinRequestRepository) Create(ctx context.Context, tx pgx.Tx, joinReque
 	).Scan(
 		&joinRequest.ID,
 	); err != nil {
-		return models.JoinRequest{}, err
+		return models.JoinRequest{}, translate(err)
 	}
 
 	return joinRequest, nil
 }
 
-func (r *JoinRequestRepository) GetAll(ctx context.Context, teamID uint64) ([]models.JoinRequest, error) {
+// Campos aceitos em GET /teams/{team_id}/join-requests
+var joinRequestList = listquery.Resource[models.JoinRequest]{
+	Fields: map[string]listquery.Field[models.JoinRequest]{
+		"id":          {Column: "id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(j models.JoinRequest) any { return j.ID }},
+		"sender_id":   {Column: "sender_id", Type: listquery.Int, Filterable: true},
+		"status":      {Column: "status", Type: listquery.Int, Filterable: true},
+		"decision_at": {Column: "decision_at", Type: listquery.Time, Filterable: true},
+		"decision_by": {Column: "decision_by", Type: listquery.Int, Filterable: true},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+func (r *JoinRequestRepository) GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.JoinRequest], error) {
 
 	query := `
 		SELECT 	id, team_id, team_owner_id, sender_id, status, decision_at, decision_by 
@@ -45,7 +59,33 @@ func (r *JoinRequestRepository) GetAll(ctx context.Context, teamID uint64) ([]mo
 		WHERE team_id = $1
 	`
 
-	rows, err := r.db.Query(ctx, query, teamID)
+	return listquery.Fetch(ctx, r.db, joinRequestList, params, query, []any{teamID}, func(row pgx.Row) (models.JoinRequest, error) {
+		var request models.JoinRequest
+
+		err := row.Scan(
+			&request.ID,
+			&request.TeamID,
+			&request.TeamOwnerID,
+			&request.SenderID,
+			&request.Status,
+			&request.DecisionAt,
+			&request.DecisionBy,
+		)
+
+		return request, err
+	})
+}
+
+// Lista os pedidos de entrada enviados pelo usuário, em qualquer equipe
+func (r *JoinRequestRepository) GetAllBySenderID(ctx context.Context, senderID uint64) ([]models.JoinRequest, error) {
+
+	query := `
+		SELECT 	id, team_id, team_owner_id, sender_id, status, decision_at, decision_by
+		FROM teamjoinrequests
+		WHERE sender_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, senderID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +111,7 @@ func (r *JoinRequestRepository) GetAll(ctx context.Context, teamID uint64) ([]mo
 		requests = append(requests, request)
 	}
 
-	return requests, nil
+	return requests, rows.Err()
 }
 
 func (r *JoinRequestRepository) GetByID(ctx context.Context, joinRequestID, teamID uint64) (models.JoinRequest, error) {
@@ -99,7 +139,7 @@ func (r *JoinRequestRepository) GetByID(ctx context.Context, joinRequestID, team
 		&request.DecisionBy,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.JoinRequest{}, errors.New("join request not found")
+			return models.JoinRequest{}, ErrJoinRequestNotFound
 		}
 		return models.JoinRequest{}, err
 	}
@@ -116,11 +156,11 @@ func (r *JoinRequestRepository) Delete(ctx context.Context, tx pgx.Tx, requestID
 
 	result, err := tx.Exec(ctx, query, requestID, teamID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no team deleted")
+		return 0, ErrJoinRequestNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -129,16 +169,17 @@ func (r *JoinRequestRepository) Delete(ctx context.Context, tx pgx.Tx, requestID
 
 func (r *JoinRequestRepository) Accept(ctx context.Context, tx pgx.Tx, userID, teamID, joinRequestID uint64) (uint64, error) {
 	query := `
-		UPDATE teamjoinrequests SET status = 1, decision_at = NOW(), decision_by = $1 WHERE id = $2 AND team_id = $3
+		UPDATE teamjoinrequests SET status = 1, decision_at = NOW(), decision_by = $1 WHERE id = $2 AND team_id = $3 AND status = 0
 	`
 
 	result, err := tx.Exec(ctx, query, userID, joinRequestID, teamID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
+	// O serviço já encontrou o pedido; nenhuma linha alterada significa que outra decisão chegou antes
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no request accepted")
+		return 0, ErrJoinRequestDecided
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -146,16 +187,17 @@ func (r *JoinRequestRepository) Accept(ctx context.Context, tx pgx.Tx, userID, t
 
 func (r *JoinRequestRepository) Reject(ctx context.Context, tx pgx.Tx, userID, teamID, joinRequestID uint64) (uint64, error) {
 	query := `
-		UPDATE teamjoinrequests SET status = 2, decision_at = NOW(), decision_by = $1 WHERE id = $2 AND team_id = $3
+		UPDATE teamjoinrequests SET status = 2, decision_at = NOW(), decision_by = $1 WHERE id = $2 AND team_id = $3 AND status = 0
 	`
 
 	result, err := tx.Exec(ctx, query, userID, joinRequestID, teamID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
+	// O serviço já encontrou o pedido; nenhuma linha alterada significa que outra decisão chegou antes
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no request accepted")
+		return 0, ErrJoinRequestDecided
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"HareID/internal/enums"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"context"
 	"errors"
@@ -42,33 +43,65 @@ func (r *NotificationRepository) CreateByJoinRequest(ctx context.Context, tx pgx
 		&notification.ID,
 		&notification.CreatedAt,
 	); err != nil {
-		return models.Notification{}, err
+		return models.Notification{}, translate(err)
 	}
 
 	return notification, nil
 }
 
-func (r *NotificationRepository) GetAll(ctx context.Context, userID uint64) ([]models.Notification, error) {
+// Cria uma notificação do sistema. SenderID zero indica que não há remetente
+func (r *NotificationRepository) Create(ctx context.Context, tx pgx.Tx, notification models.Notification) (models.Notification, error) {
 
 	query := `
-		SELECT id, sender_id, receiver_id, type, reference_id, seen, created_at
-		FROM notifications
-		WHERE receiver_id = $1
+		INSERT INTO notifications(sender_id, receiver_id, type, reference_id, seen)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5)
+		RETURNING id, created_at
 	`
 
-	rows, err := r.db.Query(ctx, query, userID)
-	if err != nil {
-		return nil, err
+	if err := tx.QueryRow(
+		ctx,
+		query,
+		notification.SenderID,
+		notification.ReceiverID,
+		notification.Type,
+		notification.ReferenceID,
+		notification.Seen,
+	).Scan(
+		&notification.ID,
+		&notification.CreatedAt,
+	); err != nil {
+		return models.Notification{}, translate(err)
 	}
-	defer rows.Close()
 
-	var notifications []models.Notification
+	return notification, nil
+}
+
+// Campos aceitos em GET /users/{user_id}/notifications
+var notificationList = listquery.Resource[models.Notification]{
+	Fields: map[string]listquery.Field[models.Notification]{
+		"id":                {Column: "id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(n models.Notification) any { return n.ID }},
+		"sender_id":         {Column: "sender_id", Type: listquery.Int, Filterable: true},
+		"notification_type": {Column: "type", Type: listquery.Int, Filterable: true},
+		"reference_id":      {Column: "reference_id", Type: listquery.Int, Filterable: true},
+		"seen":              {Column: "seen", Type: listquery.Bool, Filterable: true},
+		"created_at":        {Column: "created_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(n models.Notification) any { return n.CreatedAt.Time }},
+	},
+	Key:         "id",
+	DefaultSort: "-created_at",
+}
+
+func (r *NotificationRepository) GetAll(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Notification], error) {
 
-	for rows.Next() {
+	query := `
+		SELECT id, COALESCE(sender_id, 0), receiver_id, type, reference_id, seen, created_at
+		FROM notifications
+		WHERE receiver_id = $1
+	`
 
+	return listquery.Fetch(ctx, r.db, notificationList, params, query, []any{userID}, func(row pgx.Row) (models.Notification, error) {
 		var notification models.Notification
 
-		if err = rows.Scan(
+		err := row.Scan(
 			&notification.ID,
 			&notification.SenderID,
 			&notification.ReceiverID,
@@ -76,24 +109,16 @@ func (r *NotificationRepository) GetAll(ctx context.Context, userID uint64) ([]m
 			&notification.ReferenceID,
 			&notification.Seen,
 			&notification.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-
-		notifications = append(notifications, notification)
-	}
+		)
 
-	if len(notifications) < 1 {
-		return nil, errors.New("no notifications found")
-	}
-
-	return notifications, nil
+		return notification, err
+	})
 }
 
 func (r *NotificationRepository) GetByID(ctx context.Context, userID, notificationID uint64) (models.Notification, error) {
 
 	query := `
-		SELECT id, sender_id, receiver_id, type, reference_id, seen, created_at
+		SELECT id, COALESCE(sender_id, 0), receiver_id, type, reference_id, seen, created_at
 		FROM notifications
 		WHERE id = $1 AND receiver_id = $2
 	`
@@ -115,7 +140,7 @@ func (r *NotificationRepository) GetByID(ctx context.Context, userID, notificati
 		&notification.CreatedAt,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Notification{}, errors.New("notification not found")
+			return models.Notification{}, ErrNotificationNotFound
 		}
 		return models.Notification{}, err
 	}
@@ -123,6 +148,20 @@ func (r *NotificationRepository) GetByID(ctx context.Context, userID, notificati
 	return notification, nil
 }
 
+// Apaga as notificações recebidas pelo usuário e desvincula as que ele enviou
+func (r *NotificationRepository) DeleteByUserID(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error) {
+	result, err := tx.Exec(ctx, `DELETE FROM notifications WHERE receiver_id = $1`, userID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE notifications SET sender_id = NULL WHERE sender_id = $1`, userID); err != nil {
+		return 0, translate(err)
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
 func (r *NotificationRepository) Delete(ctx context.Context, tx pgx.Tx, userID, notificationID uint64) (uint64, error) {
 	query := `
 		DELETE FROM notifications
@@ -131,11 +170,11 @@ func (r *NotificationRepository) Delete(ctx context.Context, tx pgx.Tx, userID,
 
 	result, err := tx.Exec(ctx, query, notificationID, userID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() < 1 {
-		return 0, errors.New("no notification deleted")
+		return 0, ErrNotificationNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"HareID/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PlansRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *PlansRepository) GetAll(ctx context.Context) ([]models.Plan, error) {
+
+	query := `
+		SELECT id, price_id, name, provider, amount, max_seats, max_teams, COALESCE(features, '{}'), COALESCE(meter_limits, '{}'::jsonb)
+		FROM plans
+		ORDER BY max_seats
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []models.Plan
+
+	for rows.Next() {
+		var plan models.Plan
+
+		if err := rows.Scan(
+			&plan.ID,
+			&plan.PriceID,
+			&plan.Name,
+			&plan.Provider,
+			&plan.Amount,
+			&plan.MaxSeats,
+			&plan.MaxTeams,
+			&plan.Features,
+			&plan.MeterLimits,
+		); err != nil {
+			return nil, err
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+func (r *PlansRepository) GetByPriceID(ctx context.Context, priceID string) (models.Plan, error) {
+
+	query := `
+		SELECT id, price_id, name, provider, amount, max_seats, max_teams, COALESCE(features, '{}'), COALESCE(meter_limits, '{}'::jsonb)
+		FROM plans
+		WHERE price_id = $1
+	`
+
+	var plan models.Plan
+
+	if err := r.db.QueryRow(ctx, query, priceID).Scan(
+		&plan.ID,
+		&plan.PriceID,
+		&plan.Name,
+		&plan.Provider,
+		&plan.Amount,
+		&plan.MaxSeats,
+		&plan.MaxTeams,
+		&plan.Features,
+		&plan.MeterLimits,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Plan{}, ErrPlanNotFound
+		}
+		return models.Plan{}, err
+	}
+
+	return plan, nil
+}
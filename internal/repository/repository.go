@@ -1,8 +1,14 @@
 package repository
 
 import (
+	"HareID/internal/enums"
+	"HareID/internal/enums/invoice"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/pii"
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,57 +17,164 @@ import (
 type Repository struct {
 	Users interface {
 		Create(ctx context.Context, tx pgx.Tx, user models.User) (models.User, error)
-		GetAll(ctx context.Context) ([]models.User, error)
+		GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.User], error)
 		GetByGoogleSubscription(ctx context.Context, googleSubscription string) (models.User, error)
 		GetByID(ctx context.Context, userID uint64) (models.User, error)
+		GetGoogleSubByID(ctx context.Context, userID uint64) (string, error)
 		GetByStripeCustomerID(ctx context.Context, stripeCustomerID string) (models.User, error)
+		GetByCpfCnpj(ctx context.Context, cpfCnpj string) (models.User, error)
+		ExistsByCpfCnpj(ctx context.Context, cpfCnpj string, exceptUserID uint64) (bool, error)
 		Update(ctx context.Context, tx pgx.Tx, userID uint64, user models.User) (uint64, error)
-		Delete(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error)
+		SetStripeCustomerID(ctx context.Context, tx pgx.Tx, userID uint64, stripeCustomerID string) (uint64, error)
+		Anonymize(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error)
+		GetSessionsRevokedAt(ctx context.Context, userID uint64) (*time.Time, error)
+		LockForUpdate(ctx context.Context, tx pgx.Tx, userID uint64) error
+		IsAdmin(ctx context.Context, userID uint64) (bool, error)
+		SetAdmin(ctx context.Context, tx pgx.Tx, userID uint64, isAdmin bool) (uint64, error)
+		RevokeSessions(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error)
+		RotateCpfCnpj(ctx context.Context, tx pgx.Tx, afterID uint64, limit int) (models.KeyRotationBatch, error)
 	}
 	Subscriptions interface {
 		Create(ctx context.Context, tx pgx.Tx, subscription models.Subscription) (models.Subscription, error)
-		GetAll(ctx context.Context) ([]models.Subscription, error)
+		GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Subscription], error)
 		GetBySubscriptionID(ctx context.Context, subscriptionID string) (models.Subscription, error)
 		GetByID(ctx context.Context, id uint64) (models.Subscription, error)
+		GetCurrentByUserID(ctx context.Context, userID uint64) (models.Subscription, error)
+		GetCurrentByTeamID(ctx context.Context, teamID uint64) (models.Subscription, error)
+		GetAllByStatus(ctx context.Context, statuses ...subscription.Subscription) ([]models.Subscription, error)
+		GetAllByUserID(ctx context.Context, userID uint64) ([]models.Subscription, error)
+		CountByUserID(ctx context.Context, userID uint64) (uint64, error)
+		CountByStatus(ctx context.Context) (map[subscription.Subscription]uint64, error)
 		Update(ctx context.Context, tx pgx.Tx, subscriptionID string, subscription models.Subscription) (uint64, error)
+		UpdateStatus(ctx context.Context, tx pgx.Tx, subscriptionID string, status subscription.Subscription, period *models.BillingPeriod, eventAt time.Time) (bool, error)
+		UpdateQuantity(ctx context.Context, tx pgx.Tx, subscriptionID string, quantity int64) (uint64, error)
+		Upsert(ctx context.Context, tx pgx.Tx, subscription models.Subscription, eventAt time.Time) (bool, error)
 		Delete(ctx context.Context, tx pgx.Tx, subscriptionID string) (uint64, error)
 	}
 	Teams interface {
 		Create(ctx context.Context, tx pgx.Tx, team models.Team) (models.Team, error)
-		GetAll(ctx context.Context) ([]models.Team, error)
+		GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Team], error)
+		GetAllByMemberID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Team], error)
 		GetByID(ctx context.Context, teamID uint64) (models.Team, error)
 		SearchByOwnerID(ctx context.Context, userID uint64) (models.Team, error)
+		GetAllByOwnerID(ctx context.Context, userID uint64) ([]models.Team, error)
+		LockForUpdate(ctx context.Context, tx pgx.Tx, teamID uint64) error
+		CountByOwnerID(ctx context.Context, userID uint64) (uint64, error)
 		Update(ctx context.Context, tx pgx.Tx, teamID uint64, team models.Team) (uint64, error)
+		UpdateOwner(ctx context.Context, tx pgx.Tx, teamID, ownerID uint64) (uint64, error)
 		Delete(ctx context.Context, tx pgx.Tx, teamID uint64) (uint64, error)
 	}
 	TeamMembers interface {
 		Create(ctx context.Context, tx pgx.Tx, teamMember models.TeamMember) (models.TeamMember, error)
-		GetAll(ctx context.Context, teamID uint64) ([]models.TeamMember, error)
+		GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.TeamMember], error)
 		GetByUserID(ctx context.Context, userID uint64) (models.TeamMember, error)
+		GetAllByUserID(ctx context.Context, userID uint64) ([]models.TeamMember, error)
+		Exists(ctx context.Context, teamID, userID uint64) (bool, error)
+		CountByTeamID(ctx context.Context, teamID uint64) (uint64, error)
+		UpdateRole(ctx context.Context, tx pgx.Tx, teamID, userID uint64, role enums.TeamRole) (uint64, error)
+		Delete(ctx context.Context, tx pgx.Tx, teamID, userID uint64) (uint64, error)
 	}
 	JoinRequests interface {
 		Create(ctx context.Context, tx pgx.Tx, joinRequest models.JoinRequest) (models.JoinRequest, error)
-		GetAll(ctx context.Context, teamID uint64) ([]models.JoinRequest, error)
+		GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.JoinRequest], error)
 		GetByID(ctx context.Context, joinRequestID, teamID uint64) (models.JoinRequest, error)
+		GetAllBySenderID(ctx context.Context, senderID uint64) ([]models.JoinRequest, error)
 		Delete(ctx context.Context, tx pgx.Tx, requestID, teamID uint64) (uint64, error)
 		Accept(ctx context.Context, tx pgx.Tx, userID, teamID, joinRequestID uint64) (uint64, error)
 		Reject(ctx context.Context, tx pgx.Tx, userID, teamID, joinRequestID uint64) (uint64, error)
 	}
 	Notifications interface {
 		CreateByJoinRequest(ctx context.Context, tx pgx.Tx, joinRequest models.JoinRequest) (models.Notification, error)
-		GetAll(ctx context.Context, userID uint64) ([]models.Notification, error)
+		Create(ctx context.Context, tx pgx.Tx, notification models.Notification) (models.Notification, error)
+		GetAll(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Notification], error)
 		GetByID(ctx context.Context, userID, notificationID uint64) (models.Notification, error)
 		Delete(ctx context.Context, tx pgx.Tx, userID, notificationID uint64) (uint64, error)
+		DeleteByUserID(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error)
+	}
+	StripeEvents interface {
+		Create(ctx context.Context, tx pgx.Tx, event models.StripeEvent) (bool, error)
+		ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.StripeEvent, error)
+		MarkProcessed(ctx context.Context, tx pgx.Tx, eventID string) error
+		MarkRetry(ctx context.Context, tx pgx.Tx, eventID, lastError string, nextAttemptAt time.Time) error
+		MarkFailed(ctx context.Context, tx pgx.Tx, eventID, lastError string) error
+		Reset(ctx context.Context, tx pgx.Tx, eventID string) (uint64, error)
+		GetAll(ctx context.Context, status enums.WebhookEventStatus, params listquery.Params) (listquery.Page[models.StripeEvent], error)
+		GetByID(ctx context.Context, eventID string) (models.StripeEvent, error)
+	}
+	Invoices interface {
+		Upsert(ctx context.Context, tx pgx.Tx, inv models.Invoice, eventAt time.Time) (models.Invoice, bool, error)
+		SetRefund(ctx context.Context, tx pgx.Tx, invoiceID string, amountRefunded int64, status invoice.Invoice) (uint64, error)
+		GetByUserID(ctx context.Context, userID uint64) ([]models.Invoice, error)
+	}
+	Usage interface {
+		Create(ctx context.Context, tx pgx.Tx, event models.UsageEvent) (bool, error)
+		ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.UsageEvent, error)
+		SetStatus(ctx context.Context, tx pgx.Tx, ids []uint64, status enums.UsageStatus, lastError string) error
+		MarkRetry(ctx context.Context, tx pgx.Tx, ids []uint64, lastError string, nextAttemptAt time.Time) error
+		SumByTeam(ctx context.Context, teamID uint64, from, to time.Time) (map[string]int64, error)
+	}
+	BillingHistory interface {
+		Create(ctx context.Context, tx pgx.Tx, history models.BillingHistory, dedupeKey string) (bool, error)
+		GetByUserID(ctx context.Context, userID uint64) ([]models.BillingHistory, error)
+	}
+	DataExports interface {
+		Create(ctx context.Context, tx pgx.Tx, export models.DataExport) (models.DataExport, error)
+		GetByID(ctx context.Context, exportID uint64) (models.DataExport, error)
+		GetActiveByUserID(ctx context.Context, userID uint64) (models.DataExport, error)
+		ClaimPending(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.DataExport, error)
+		MarkReady(ctx context.Context, tx pgx.Tx, exportID uint64, filePath string, expiresAt time.Time) error
+		MarkFailed(ctx context.Context, tx pgx.Tx, exportID uint64, lastError string) error
+		GetExpired(ctx context.Context) ([]models.DataExport, error)
+		MarkExpired(ctx context.Context, tx pgx.Tx, exportID uint64) error
+		DeleteByUserID(ctx context.Context, tx pgx.Tx, userID uint64) ([]string, error)
+	}
+	AccountDeletions interface {
+		Create(ctx context.Context, tx pgx.Tx, deletion models.AccountDeletion) (models.AccountDeletion, error)
+		GetLatestByUserID(ctx context.Context, userID uint64) (models.AccountDeletion, error)
+		Cancel(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error)
+		ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.AccountDeletion, error)
+		MarkRetry(ctx context.Context, tx pgx.Tx, deletionID uint64, lastError string, nextAttemptAt time.Time) error
+		Complete(ctx context.Context, tx pgx.Tx, deletionID uint64) (bool, error)
+		CreateTombstone(ctx context.Context, tx pgx.Tx, tombstone models.UserTombstone) error
+	}
+	TermsVersions interface {
+		Create(ctx context.Context, tx pgx.Tx, version models.TermsVersion) (models.TermsVersion, error)
+		GetCurrent(ctx context.Context) ([]models.TermsVersion, error)
+		GetByID(ctx context.Context, versionID uint64) (models.TermsVersion, error)
+	}
+	Consents interface {
+		Create(ctx context.Context, tx pgx.Tx, consent models.UserConsent) (models.UserConsent, error)
+		GetLatestByUserID(ctx context.Context, userID uint64) ([]models.UserConsent, error)
+		GetAllByUserID(ctx context.Context, userID uint64) ([]models.UserConsent, error)
+	}
+	AuditLogs interface {
+		Create(ctx context.Context, tx pgx.Tx, entry models.AuditLog) (models.AuditLog, error)
+		GetAll(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, error)
+		GetChain(ctx context.Context, afterID uint64, limit int) ([]models.AuditLog, error)
+	}
+	Plans interface {
+		GetAll(ctx context.Context) ([]models.Plan, error)
+		GetByPriceID(ctx context.Context, priceID string) (models.Plan, error)
 	}
 }
 
-func NewRepository(db *pgxpool.Pool) Repository {
+func NewRepository(db *pgxpool.Pool, cipher *pii.Cipher) Repository {
 	return Repository{
-		Users:         &UserRepository{db: db},
-		Subscriptions: &SubscriptionRepository{db: db},
-		Teams:         &TeamsRepository{db: db},
-		TeamMembers:   &TeamMembersRepository{db: db},
-		JoinRequests:  &JoinRequestRepository{db: db},
-		Notifications: &NotificationRepository{db: db},
+		Users:            &UserRepository{db: db, cipher: cipher},
+		Subscriptions:    &SubscriptionRepository{db: db},
+		Teams:            &TeamsRepository{db: db},
+		TeamMembers:      &TeamMembersRepository{db: db},
+		JoinRequests:     &JoinRequestRepository{db: db},
+		Notifications:    &NotificationRepository{db: db},
+		StripeEvents:     &StripeEventRepository{db: db},
+		Invoices:         &InvoiceRepository{db: db},
+		Usage:            &UsageRepository{db: db},
+		BillingHistory:   &BillingHistoryRepository{db: db},
+		DataExports:      &DataExportRepository{db: db},
+		Plans:            &PlansRepository{db: db},
+		AccountDeletions: &AccountDeletionRepository{db: db},
+		TermsVersions:    &TermsVersionRepository{db: db},
+		Consents:         &ConsentRepository{db: db},
+		AuditLogs:        &AuditLogRepository{db: db},
 	}
 }
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/listquery"
+	"HareID/internal/models"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StripeEventRepository struct {
+	db *pgxpool.Pool
+}
+
+const stripeEventColumns = `
+	id, provider, type, object_id, payload, status, attempts, COALESCE(last_error, ''),
+	stripe_created_at, next_attempt_at, received_at, processed_at
+`
+
+// Guarda o evento. Entregas repetidas do mesmo evento são ignoradas
+func (r *StripeEventRepository) Create(ctx context.Context, tx pgx.Tx, event models.StripeEvent) (bool, error) {
+
+	query := `
+		INSERT INTO stripe_events (id, provider, type, object_id, payload, status, stripe_created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	result, err := tx.Exec(ctx, query,
+		event.ID,
+		event.Provider,
+		event.Type,
+		event.ObjectID,
+		event.Payload,
+		enums.EVENT_PENDING,
+		event.StripeCreatedAt,
+	)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// Reserva os próximos eventos pendentes, em ordem de criação no Stripe, por um tempo de lease
+func (r *StripeEventRepository) ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.StripeEvent, error) {
+
+	query := `
+		UPDATE stripe_events
+		SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM stripe_events
+			WHERE status = $2 AND next_attempt_at <= NOW()
+			ORDER BY stripe_created_at, received_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + stripeEventColumns
+
+	rows, err := tx.Query(ctx, query, lease.Seconds(), enums.EVENT_PENDING, limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	events, err := scanStripeEvents(rows)
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	// O RETURNING não preserva a ordem da subconsulta
+	sortStripeEvents(events)
+
+	return events, nil
+}
+
+func (r *StripeEventRepository) MarkProcessed(ctx context.Context, tx pgx.Tx, eventID string) error {
+
+	query := `
+		UPDATE stripe_events
+		SET status = $1, last_error = NULL, next_attempt_at = NULL, processed_at = NOW()
+		WHERE id = $2
+	`
+
+	_, err := tx.Exec(ctx, query, enums.EVENT_PROCESSED, eventID)
+	return err
+}
+
+func (r *StripeEventRepository) MarkRetry(ctx context.Context, tx pgx.Tx, eventID, lastError string, nextAttemptAt time.Time) error {
+
+	query := `
+		UPDATE stripe_events
+		SET status = $1, last_error = $2, next_attempt_at = $3
+		WHERE id = $4
+	`
+
+	_, err := tx.Exec(ctx, query, enums.EVENT_PENDING, lastError, nextAttemptAt, eventID)
+	return err
+}
+
+func (r *StripeEventRepository) MarkFailed(ctx context.Context, tx pgx.Tx, eventID, lastError string) error {
+
+	query := `
+		UPDATE stripe_events
+		SET status = $1, last_error = $2, next_attempt_at = NULL
+		WHERE id = $3
+	`
+
+	_, err := tx.Exec(ctx, query, enums.EVENT_FAILED, lastError, eventID)
+	return err
+}
+
+// Reserva o evento para reprocessamento imediato, zerando as tentativas anteriores
+// Devolve à fila um evento já concluído (processado ou com falha), zerando as tentativas. Eventos pendentes
+// não são alterados: podem estar com um worker, que os reivindicou em ClaimDue. Retorna 0 nesse caso
+func (r *StripeEventRepository) Reset(ctx context.Context, tx pgx.Tx, eventID string) (uint64, error) {
+
+	query := `
+		UPDATE stripe_events
+		SET status = $1, attempts = 0, last_error = NULL, next_attempt_at = NOW()
+		WHERE id = $2 AND status <> $1
+	`
+
+	result, err := tx.Exec(ctx, query, enums.EVENT_PENDING, eventID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+// Campos aceitos em GET /admin/webhook-events
+var stripeEventList = listquery.Resource[models.StripeEvent]{
+	Fields: map[string]listquery.Field[models.StripeEvent]{
+		"id":                {Column: "id", Type: listquery.String, Filterable: true, Sortable: true, Value: func(e models.StripeEvent) any { return e.ID }},
+		"provider":          {Column: "provider", Type: listquery.String, Filterable: true},
+		"type":              {Column: "type", Type: listquery.String, Filterable: true},
+		"object_id":         {Column: "object_id", Type: listquery.String, Filterable: true},
+		"stripe_created_at": {Column: "stripe_created_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(e models.StripeEvent) any { return e.StripeCreatedAt }},
+		"received_at":       {Column: "received_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(e models.StripeEvent) any { return e.ReceivedAt }},
+	},
+	Key:         "id",
+	DefaultSort: "-received_at",
+}
+
+func (r *StripeEventRepository) GetAll(ctx context.Context, status enums.WebhookEventStatus, params listquery.Params) (listquery.Page[models.StripeEvent], error) {
+
+	query := `SELECT ` + stripeEventColumns + ` FROM stripe_events WHERE status = $1`
+
+	return listquery.Fetch(ctx, r.db, stripeEventList, params, query, []any{status}, scanStripeEvent)
+}
+
+func (r *StripeEventRepository) GetByID(ctx context.Context, eventID string) (models.StripeEvent, error) {
+
+	query := `SELECT ` + stripeEventColumns + ` FROM stripe_events WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, query, eventID)
+	if err != nil {
+		return models.StripeEvent{}, err
+	}
+	defer rows.Close()
+
+	events, err := scanStripeEvents(rows)
+	if err != nil {
+		return models.StripeEvent{}, err
+	}
+
+	if len(events) == 0 {
+		return models.StripeEvent{}, ErrWebhookEventNotFound
+	}
+
+	return events[0], nil
+}
+
+func scanStripeEvents(rows pgx.Rows) ([]models.StripeEvent, error) {
+
+	var events []models.StripeEvent
+
+	for rows.Next() {
+		event, err := scanStripeEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanStripeEvent(row pgx.Row) (models.StripeEvent, error) {
+	var event models.StripeEvent
+
+	err := row.Scan(
+		&event.ID,
+		&event.Provider,
+		&event.Type,
+		&event.ObjectID,
+		&event.Payload,
+		&event.Status,
+		&event.Attempts,
+		&event.LastError,
+		&event.StripeCreatedAt,
+		&event.NextAttemptAt,
+		&event.ReceivedAt,
+		&event.ProcessedAt,
+	)
+
+	return event, err
+}
+
+func sortStripeEvents(events []models.StripeEvent) {
+	slices.SortStableFunc(events, func(a, b models.StripeEvent) int {
+		if c := a.StripeCreatedAt.Compare(b.StripeCreatedAt); c != 0 {
+			return c
+		}
+		return a.ReceivedAt.Compare(b.ReceivedAt)
+	})
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/payments"
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,32 +20,129 @@ type SubscriptionRepository struct {
 func (r SubscriptionRepository) Create(ctx context.Context, tx pgx.Tx, subscription models.Subscription) (models.Subscription, error) {
 
 	query := `
-		INSERT INTO subscriptions (user_id, subscription_id, price_id, status, current_period_end)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, subscription_id
+		INSERT INTO subscriptions (user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_end, past_due_since, current_period_start)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, subscription_id, past_due_since
 	`
 
 	err := tx.QueryRow(ctx, query,
 		subscription.UserID,
+		subscription.TeamID,
 		subscription.SubscriptionID,
+		subscriptionProvider(subscription),
 		subscription.PriceID,
+		subscription.Quantity,
 		subscription.Status,
 		subscription.CurrentPeriodEnd,
-	).Scan(&subscription.ID, &subscription.SubscriptionID)
+		delinquentSince(subscription.Status, time.Now()),
+		subscription.CurrentPeriodStart,
+	).Scan(&subscription.ID, &subscription.SubscriptionID, &subscription.PastDueSince)
 
 	if err != nil {
-		return models.Subscription{}, err
+		return models.Subscription{}, translate(err)
 	}
 
 	return subscription, nil
 }
 
-func (r SubscriptionRepository) GetAll(ctx context.Context) ([]models.Subscription, error) {
+// Campos aceitos em GET /subscriptions
+var subscriptionList = listquery.Resource[models.Subscription]{
+	Fields: map[string]listquery.Field[models.Subscription]{
+		"id":                 {Column: "id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(s models.Subscription) any { return s.ID }},
+		"user_id":            {Column: "user_id", Type: listquery.Int, Filterable: true},
+		"team_id":            {Column: "team_id", Type: listquery.Int, Filterable: true},
+		"subscription_id":    {Column: "subscription_id", Type: listquery.String, Filterable: true},
+		"provider":           {Column: "provider", Type: listquery.String, Filterable: true},
+		"price_id":           {Column: "price_id", Type: listquery.String, Filterable: true},
+		"status":             {Column: "status", Type: listquery.Int, Filterable: true},
+		"current_period_end": {Column: "current_period_end", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(s models.Subscription) any { return s.CurrentPeriodEnd }},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+func (r SubscriptionRepository) GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Subscription], error) {
 	query := `
-		SELECT id, user_id, subscription_id, price_id, status, current_period_end FROM subscriptions
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE TRUE
 	`
 
-	rows, err := r.db.Query(ctx, query)
+	return listquery.Fetch(ctx, r.db, subscriptionList, params, query, nil, func(row pgx.Row) (models.Subscription, error) {
+		var subscription models.Subscription
+
+		err := row.Scan(
+			&subscription.ID,
+			&subscription.UserID,
+			&subscription.TeamID,
+			&subscription.SubscriptionID,
+			&subscription.Provider,
+			&subscription.PriceID,
+			&subscription.Quantity,
+			&subscription.Status,
+			&subscription.CurrentPeriodStart,
+			&subscription.CurrentPeriodEnd,
+			&subscription.PastDueSince,
+		)
+
+		return subscription, err
+	})
+}
+
+func (r SubscriptionRepository) GetBySubscriptionID(ctx context.Context, subscriptionID string) (models.Subscription, error) {
+	query := `
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE subscription_id = $1
+	`
+
+	return r.scanOne(ctx, query, subscriptionID)
+}
+
+func (r SubscriptionRepository) GetByID(ctx context.Context, id uint64) (models.Subscription, error) {
+	query := `
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE id = $1
+	`
+
+	return r.scanOne(ctx, query, id)
+}
+
+// Busca a assinatura pessoal mais recente do usuário. Assinaturas de equipe são resolvidas por GetCurrentByTeamID
+func (r SubscriptionRepository) GetCurrentByUserID(ctx context.Context, userID uint64) (models.Subscription, error) {
+	query := `
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE user_id = $1 AND team_id IS NULL
+		ORDER BY current_period_end DESC
+		LIMIT 1
+	`
+
+	return r.scanOne(ctx, query, userID)
+}
+
+// Busca a assinatura mais recente cobrada da equipe
+func (r SubscriptionRepository) GetCurrentByTeamID(ctx context.Context, teamID uint64) (models.Subscription, error) {
+	query := `
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE team_id = $1
+		ORDER BY current_period_end DESC
+		LIMIT 1
+	`
+
+	return r.scanOne(ctx, query, teamID)
+}
+
+// Lista as assinaturas nos status informados
+func (r SubscriptionRepository) GetAllByStatus(ctx context.Context, statuses ...subscription.Subscription) ([]models.Subscription, error) {
+	query := `
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE status = ANY($1)
+	`
+
+	values := make([]int, len(statuses))
+	for i, status := range statuses {
+		values[i] = int(status)
+	}
+
+	rows, err := r.db.Query(ctx, query, values)
 	if err != nil {
 		return nil, err
 	}
@@ -52,84 +153,212 @@ func (r SubscriptionRepository) GetAll(ctx context.Context) ([]models.Subscripti
 	for rows.Next() {
 		var subscription models.Subscription
 
-		err := rows.Scan(&subscription.ID, &subscription.UserID, &subscription.SubscriptionID, &subscription.PriceID, &subscription.Status, &subscription.CurrentPeriodEnd)
-		if err != nil {
+		if err := rows.Scan(
+			&subscription.ID,
+			&subscription.UserID,
+			&subscription.TeamID,
+			&subscription.SubscriptionID,
+			&subscription.Provider,
+			&subscription.PriceID,
+			&subscription.Quantity,
+			&subscription.Status,
+			&subscription.CurrentPeriodStart,
+			&subscription.CurrentPeriodEnd,
+			&subscription.PastDueSince,
+		); err != nil {
 			return nil, err
 		}
 		subscriptions = append(subscriptions, subscription)
 	}
 
-	return subscriptions, nil
+	return subscriptions, rows.Err()
 }
 
-func (r SubscriptionRepository) GetBySubscriptionID(ctx context.Context, subscriptionID string) (models.Subscription, error) {
+// Lista as assinaturas do usuário, pessoais e de equipe
+func (r SubscriptionRepository) GetAllByUserID(ctx context.Context, userID uint64) ([]models.Subscription, error) {
 	query := `
-		SELECT id, user_id, subscription_id, price_id, status, current_period_end FROM subscriptions
-		WHERE subscription_id = $1
+		SELECT id, user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_start, current_period_end, past_due_since FROM subscriptions
+		WHERE user_id = $1
+		ORDER BY current_period_end DESC
 	`
 
-	var subscription models.Subscription
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	err := r.db.QueryRow(ctx, query, subscriptionID).Scan(
-		&subscription.ID,
-		&subscription.UserID,
-		&subscription.SubscriptionID,
-		&subscription.PriceID,
-		&subscription.Status,
-		&subscription.CurrentPeriodEnd,
-	)
+	var subscriptions []models.Subscription
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Subscription{}, errors.New("subscription not found")
+	for rows.Next() {
+		var subscription models.Subscription
+
+		if err := rows.Scan(
+			&subscription.ID,
+			&subscription.UserID,
+			&subscription.TeamID,
+			&subscription.SubscriptionID,
+			&subscription.Provider,
+			&subscription.PriceID,
+			&subscription.Quantity,
+			&subscription.Status,
+			&subscription.CurrentPeriodStart,
+			&subscription.CurrentPeriodEnd,
+			&subscription.PastDueSince,
+		); err != nil {
+			return nil, err
 		}
-		return models.Subscription{}, err
+		subscriptions = append(subscriptions, subscription)
 	}
 
-	return subscription, nil
+	return subscriptions, rows.Err()
 }
 
-func (r SubscriptionRepository) GetByID(ctx context.Context, id uint64) (models.Subscription, error) {
+// Conta todas as assinaturas do usuário, pessoais e de equipe, incluindo as encerradas
+func (r SubscriptionRepository) CountByUserID(ctx context.Context, userID uint64) (uint64, error) {
 	query := `
-		SELECT * from subscriptions WHERE id = $1
+		SELECT COUNT(*) FROM subscriptions WHERE user_id = $1
 	`
 
-	var subscription models.Subscription
+	var count uint64
 
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&subscription.ID,
-		&subscription.UserID,
-		&subscription.SubscriptionID,
-		&subscription.PriceID,
-		&subscription.Status,
-		&subscription.CurrentPeriodEnd,
-	)
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Quantidade de assinaturas em cada status
+func (r SubscriptionRepository) CountByStatus(ctx context.Context) (map[subscription.Subscription]uint64, error) {
+	query := `
+		SELECT status, COUNT(*) FROM subscriptions GROUP BY status
+	`
 
+	rows, err := r.db.Query(ctx, query)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Subscription{}, errors.New("subscription not found")
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[subscription.Subscription]uint64{}
+	for rows.Next() {
+		var status subscription.Subscription
+		var count uint64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
 		}
-		return models.Subscription{}, err
+		counts[status] = count
 	}
 
-	return subscription, nil
+	return counts, rows.Err()
 }
 
 func (r SubscriptionRepository) Update(ctx context.Context, tx pgx.Tx, subscriptionID string, subscription models.Subscription) (uint64, error) {
 
 	query := `
 		UPDATE subscriptions
-		SET price_id = $1, status = $2, current_period_end = $3
-		WHERE subscription_id = $4
+		SET team_id = COALESCE($1, team_id), price_id = $2, quantity = $3, status = $4, current_period_end = $5,
+			past_due_since = ` + keepPastDueSince("$7") + `
+		WHERE subscription_id = $6
 	`
 
-	result, err := tx.Exec(ctx, query, subscription.PriceID, subscription.Status, subscription.CurrentPeriodEnd, subscriptionID)
+	result, err := tx.Exec(ctx, query,
+		subscription.TeamID,
+		subscription.PriceID,
+		subscription.Quantity,
+		subscription.Status,
+		subscription.CurrentPeriodEnd,
+		subscriptionID,
+		delinquentSince(subscription.Status, time.Now()),
+	)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrSubscriptionNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+// Grava o estado vindo de um evento do provedor. Eventos mais antigos que o último aplicado são descartados
+func (r SubscriptionRepository) Upsert(ctx context.Context, tx pgx.Tx, subscription models.Subscription, eventAt time.Time) (bool, error) {
+
+	query := `
+		INSERT INTO subscriptions (user_id, team_id, subscription_id, provider, price_id, quantity, status, current_period_end, last_event_at, past_due_since, current_period_start)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (subscription_id) DO UPDATE
+		SET team_id = COALESCE(EXCLUDED.team_id, subscriptions.team_id),
+			price_id = EXCLUDED.price_id,
+			quantity = EXCLUDED.quantity,
+			status = EXCLUDED.status,
+			current_period_start = EXCLUDED.current_period_start,
+			current_period_end = EXCLUDED.current_period_end,
+			last_event_at = EXCLUDED.last_event_at,
+			past_due_since = ` + keepPastDueSince("EXCLUDED.past_due_since") + `
+		WHERE subscriptions.last_event_at IS NULL OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+	`
+
+	result, err := tx.Exec(ctx, query,
+		subscription.UserID,
+		subscription.TeamID,
+		subscription.SubscriptionID,
+		subscriptionProvider(subscription),
+		subscription.PriceID,
+		subscription.Quantity,
+		subscription.Status,
+		subscription.CurrentPeriodEnd,
+		eventAt,
+		delinquentSince(subscription.Status, eventAt),
+		subscription.CurrentPeriodStart,
+	)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// Atualiza status e período a partir de um evento, descartando eventos mais antigos que o último aplicado.
+// period nil mantém o período atual
+func (r SubscriptionRepository) UpdateStatus(ctx context.Context, tx pgx.Tx, subscriptionID string, status subscription.Subscription, period *models.BillingPeriod, eventAt time.Time) (bool, error) {
+
+	query := `
+		UPDATE subscriptions
+		SET status = $1, current_period_end = COALESCE($2, current_period_end), last_event_at = $3,
+			current_period_start = COALESCE($6, current_period_start),
+			past_due_since = ` + keepPastDueSince("$5") + `
+		WHERE subscription_id = $4 AND (last_event_at IS NULL OR last_event_at <= $3)
+	`
+
+	var periodStart, periodEnd *time.Time
+	if period != nil {
+		periodStart, periodEnd = &period.Start, &period.End
+	}
+
+	result, err := tx.Exec(ctx, query, status, periodEnd, eventAt, subscriptionID, delinquentSince(status, eventAt), periodStart)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r SubscriptionRepository) UpdateQuantity(ctx context.Context, tx pgx.Tx, subscriptionID string, quantity int64) (uint64, error) {
+
+	query := `
+		UPDATE subscriptions SET quantity = $1 WHERE subscription_id = $2
+	`
+
+	result, err := tx.Exec(ctx, query, quantity, subscriptionID)
+	if err != nil {
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no subscription updated")
+		return 0, ErrSubscriptionNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -144,7 +373,7 @@ func (r SubscriptionRepository) Delete(ctx context.Context, tx pgx.Tx, subscript
 
 	result, err := tx.Exec(ctx, query, subscriptionID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() == 0 {
@@ -153,3 +382,53 @@ func (r SubscriptionRepository) Delete(ctx context.Context, tx pgx.Tx, subscript
 
 	return uint64(result.RowsAffected()), nil
 }
+
+// Momento a gravar em past_due_since: at para PAST_DUE e UNPAID, nil para os demais status
+func delinquentSince(status subscription.Subscription, at time.Time) *time.Time {
+	if status != subscription.PAST_DUE && status != subscription.UNPAID {
+		return nil
+	}
+	return &at
+}
+
+// Mantém o início da inadimplência enquanto ela continuar (ex: PAST_DUE seguido de UNPAID ou de uma
+// nova cobrança falha) e limpa quando a assinatura sai dela. value é o resultado de delinquentSince
+func keepPastDueSince(value string) string {
+	return `CASE WHEN ` + value + `::timestamptz IS NULL THEN NULL ELSE COALESCE(subscriptions.past_due_since, ` + value + `) END`
+}
+
+// Registros sem provedor vêm do Stripe
+func subscriptionProvider(subscription models.Subscription) string {
+	if subscription.Provider == "" {
+		return payments.STRIPE
+	}
+	return subscription.Provider
+}
+
+func (r SubscriptionRepository) scanOne(ctx context.Context, query string, args ...any) (models.Subscription, error) {
+
+	var subscription models.Subscription
+
+	err := r.db.QueryRow(ctx, query, args...).Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.TeamID,
+		&subscription.SubscriptionID,
+		&subscription.Provider,
+		&subscription.PriceID,
+		&subscription.Quantity,
+		&subscription.Status,
+		&subscription.CurrentPeriodStart,
+		&subscription.CurrentPeriodEnd,
+		&subscription.PastDueSince,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Subscription{}, ErrSubscriptionNotFound
+		}
+		return models.Subscription{}, err
+	}
+
+	return subscription, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"context"
 	"errors"
@@ -27,45 +28,62 @@ func (r *TeamsRepository) Create(ctx context.Context, tx pgx.Tx, team models.Tea
 		&team.Domain,
 		&team.CreatedAt,
 	); err != nil {
-		return models.Team{}, err
+		return models.Team{}, translate(err)
 	}
 
 	return team, nil
 }
 
-func (r *TeamsRepository) GetAll(ctx context.Context) ([]models.Team, error) {
+// Campos aceitos em GET /teams e GET /me/teams
+var teamList = listquery.Resource[models.Team]{
+	Fields: map[string]listquery.Field[models.Team]{
+		"id":         {Column: "id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(t models.Team) any { return t.ID }},
+		"name":       {Column: "name", Type: listquery.String, Filterable: true, Sortable: true, Value: func(t models.Team) any { return t.Name }},
+		"domain":     {Column: "domain", Type: listquery.String, Filterable: true, Sortable: true, Value: func(t models.Team) any { return t.Domain }},
+		"owner_id":   {Column: "owner_id", Type: listquery.Int, Filterable: true},
+		"created_at": {Column: "created_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(t models.Team) any { return t.CreatedAt }},
+		"updated_at": {Column: "updated_at", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(t models.Team) any { return t.UpdatedAt }},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+func (r *TeamsRepository) GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Team], error) {
 
 	query := `
 		SELECT id, name, domain, owner_id, created_at, updated_at
 		FROM teams
+		WHERE TRUE
 	`
 
-	rows, err := r.db.Query(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return listquery.Fetch(ctx, r.db, teamList, params, query, nil, scanTeam)
+}
 
-	var teams []models.Team
+// Equipes das quais o usuário é membro (GET /me/teams), com os mesmos campos de GET /teams
+func (r *TeamsRepository) GetAllByMemberID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Team], error) {
 
-	for rows.Next() {
-		var team models.Team
+	query := `
+		SELECT id, name, domain, owner_id, created_at, updated_at
+		FROM teams
+		WHERE id IN (SELECT team_id FROM teammembers WHERE user_id = $1)
+	`
 
-		if err = rows.Scan(
-			&team.ID,
-			&team.Name,
-			&team.Domain,
-			&team.OwnerID,
-			&team.CreatedAt,
-			&team.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
+	return listquery.Fetch(ctx, r.db, teamList, params, query, []any{userID}, scanTeam)
+}
 
-		teams = append(teams, team)
-	}
+func scanTeam(row pgx.Row) (models.Team, error) {
+	var team models.Team
 
-	return teams, nil
+	err := row.Scan(
+		&team.ID,
+		&team.Name,
+		&team.Domain,
+		&team.OwnerID,
+		&team.CreatedAt,
+		&team.UpdatedAt,
+	)
+
+	return team, err
 }
 
 func (r *TeamsRepository) GetByID(ctx context.Context, teamID uint64) (models.Team, error) {
@@ -88,7 +106,7 @@ func (r *TeamsRepository) GetByID(ctx context.Context, teamID uint64) (models.Te
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Team{}, errors.New("team not found")
+			return models.Team{}, ErrTeamNotFound
 		}
 		return models.Team{}, err
 	}
@@ -115,7 +133,7 @@ func (r *TeamsRepository) SearchByOwnerID(ctx context.Context, userID uint64) (m
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Team{}, errors.New("team not found")
+			return models.Team{}, ErrTeamNotFound
 		}
 		return models.Team{}, err
 	}
@@ -123,6 +141,76 @@ func (r *TeamsRepository) SearchByOwnerID(ctx context.Context, userID uint64) (m
 	return team, nil
 }
 
+func (r *TeamsRepository) GetAllByOwnerID(ctx context.Context, userID uint64) ([]models.Team, error) {
+
+	query := `
+		SELECT id, name, owner_id, created_at, updated_at
+		FROM teams
+		WHERE owner_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []models.Team
+
+	for rows.Next() {
+		var team models.Team
+
+		if err := rows.Scan(
+			&team.ID,
+			&team.Name,
+			&team.OwnerID,
+			&team.CreatedAt,
+			&team.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, team)
+	}
+
+	return teams, rows.Err()
+}
+
+// Trava a linha da equipe até o fim da transação. Serializa as entradas de membros para a contagem
+// de assentos não ser ultrapassada por aceites simultâneos
+func (r *TeamsRepository) LockForUpdate(ctx context.Context, tx pgx.Tx, teamID uint64) error {
+
+	query := `
+		SELECT id FROM teams WHERE id = $1 FOR UPDATE
+	`
+
+	var id uint64
+
+	if err := tx.QueryRow(ctx, query, teamID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTeamNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *TeamsRepository) CountByOwnerID(ctx context.Context, userID uint64) (uint64, error) {
+
+	query := `
+		SELECT COUNT(*) FROM teams WHERE owner_id = $1
+	`
+
+	var count uint64
+
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func (r *TeamsRepository) Update(ctx context.Context, tx pgx.Tx, teamID uint64, team models.Team) (uint64, error) {
 
 	query := `
@@ -133,11 +221,31 @@ func (r *TeamsRepository) Update(ctx context.Context, tx pgx.Tx, teamID uint64,
 
 	result, err := tx.Exec(ctx, query, team.Name, team.Domain, teamID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no team updated")
+		return 0, ErrTeamNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+func (r *TeamsRepository) UpdateOwner(ctx context.Context, tx pgx.Tx, teamID, ownerID uint64) (uint64, error) {
+
+	query := `
+		UPDATE teams
+		SET owner_id = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	result, err := tx.Exec(ctx, query, ownerID, teamID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrTeamNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
@@ -152,11 +260,11 @@ func (r *TeamsRepository) Delete(ctx context.Context, tx pgx.Tx, teamID uint64)
 
 	result, err := tx.Exec(ctx, query, teamID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no team deleted")
+		return 0, ErrTeamNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
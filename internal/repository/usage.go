@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/models"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UsageRepository struct {
+	db *pgxpool.Pool
+}
+
+// Grava o evento. Repetições com a mesma chave de idempotência são ignoradas
+func (r *UsageRepository) Create(ctx context.Context, tx pgx.Tx, event models.UsageEvent) (bool, error) {
+
+	query := `
+		INSERT INTO usage_events (team_id, meter, quantity, occurred_at, idempotency_key, status, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`
+
+	result, err := tx.Exec(ctx, query,
+		event.TeamID,
+		event.Meter,
+		event.Quantity,
+		event.OccurredAt,
+		event.IdempotencyKey,
+		enums.USAGE_PENDING,
+	)
+	if err != nil {
+		return false, translate(err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// Reserva os próximos eventos pendentes por um tempo de lease, como a fila de webhooks
+func (r *UsageRepository) ClaimDue(ctx context.Context, tx pgx.Tx, limit int, lease time.Duration) ([]models.UsageEvent, error) {
+
+	query := `
+		UPDATE usage_events
+		SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $1)
+		WHERE id IN (
+			SELECT id FROM usage_events
+			WHERE status = $2 AND next_attempt_at <= NOW()
+			ORDER BY occurred_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, team_id, meter, quantity, occurred_at, idempotency_key, status, attempts, COALESCE(last_error, '')
+	`
+
+	rows, err := tx.Query(ctx, query, lease.Seconds(), enums.USAGE_PENDING, limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var events []models.UsageEvent
+
+	for rows.Next() {
+		var event models.UsageEvent
+
+		if err := rows.Scan(
+			&event.ID,
+			&event.TeamID,
+			&event.Meter,
+			&event.Quantity,
+			&event.OccurredAt,
+			&event.IdempotencyKey,
+			&event.Status,
+			&event.Attempts,
+			&event.LastError,
+		); err != nil {
+			return nil, translate(err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *UsageRepository) SetStatus(ctx context.Context, tx pgx.Tx, ids []uint64, status enums.UsageStatus, lastError string) error {
+
+	query := `
+		UPDATE usage_events
+		SET status = $1, last_error = NULLIF($2, ''), reported_at = CASE WHEN $1 = $3 THEN NOW() ELSE reported_at END
+		WHERE id = ANY($4)
+	`
+
+	_, err := tx.Exec(ctx, query, status, lastError, enums.USAGE_REPORTED, ids)
+	return err
+}
+
+func (r *UsageRepository) MarkRetry(ctx context.Context, tx pgx.Tx, ids []uint64, lastError string, nextAttemptAt time.Time) error {
+
+	query := `
+		UPDATE usage_events
+		SET last_error = $1, next_attempt_at = $2
+		WHERE id = ANY($3)
+	`
+
+	_, err := tx.Exec(ctx, query, lastError, nextAttemptAt, ids)
+	return err
+}
+
+// Soma o consumo de cada medidor da equipe no intervalo [from, to)
+func (r *UsageRepository) SumByTeam(ctx context.Context, teamID uint64, from, to time.Time) (map[string]int64, error) {
+
+	query := `
+		SELECT meter, SUM(quantity)
+		FROM usage_events
+		WHERE team_id = $1 AND occurred_at >= $2 AND occurred_at < $3
+		GROUP BY meter
+	`
+
+	rows, err := r.db.Query(ctx, query, teamID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int64)
+
+	for rows.Next() {
+		var meter string
+		var total int64
+
+		if err := rows.Scan(&meter, &total); err != nil {
+			return nil, err
+		}
+
+		totals[meter] = total
+	}
+
+	return totals, rows.Err()
+}
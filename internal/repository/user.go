@@ -1,29 +1,44 @@
 package repository
 
 import (
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/pii"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// O CPF/CNPJ é gravado cifrado (cpf_cnpj), com o índice cego para buscas exatas (cpf_cnpj_index)
+// e a chave mestra usada (cpf_cnpj_key_id). As leituras devolvem o valor decifrado
 type UserRepository struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	cipher *pii.Cipher
 }
 
 func (r UserRepository) Create(ctx context.Context, tx pgx.Tx, user models.User) (models.User, error) {
 	query := `
-		INSERT INTO users (google_sub, name, cpf_cnpj, stripe_customer_id, auth_provider, consent_terms, data_consent)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (google_sub, name, cpf_cnpj, cpf_cnpj_index, cpf_cnpj_key_id, stripe_customer_id, auth_provider, consent_terms, data_consent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id, create_date
 	`
 
-	err := tx.QueryRow(ctx, query,
+	encrypted, err := r.encryptCpfCnpj(user.CpfCnpj)
+	if err != nil {
+		return models.User{}, translate(err)
+	}
+
+	err = tx.QueryRow(ctx, query,
 		user.GoogleSub,
 		user.Name,
-		user.CpfCnpj,
+		encrypted.value,
+		encrypted.index,
+		encrypted.keyID,
 		user.StripeCustomerID,
 		user.AuthProvider,
 		user.ConsentTerms,
@@ -31,36 +46,43 @@ func (r UserRepository) Create(ctx context.Context, tx pgx.Tx, user models.User)
 	).Scan(&user.ID, &user.CreateDate)
 
 	if err != nil {
-		return models.User{}, err
+		return models.User{}, translate(err)
 	}
 
 	return user, nil
 }
 
-func (r UserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+// Campos aceitos em GET /users. O CPF/CNPJ é cifrado e não entra em filtros nem na ordenação
+var userList = listquery.Resource[models.User]{
+	Fields: map[string]listquery.Field[models.User]{
+		"id":                 {Column: "id", Type: listquery.Int, Filterable: true, Sortable: true, Value: func(u models.User) any { return u.ID }},
+		"name":               {Column: "name", Type: listquery.String, Filterable: true, Sortable: true, Value: func(u models.User) any { return u.Name }},
+		"stripe_customer_id": {Column: "stripe_customer_id", Type: listquery.String, Filterable: true},
+		"create_date":        {Column: "create_date", Type: listquery.Time, Filterable: true, Sortable: true, Value: func(u models.User) any { return u.CreateDate }},
+		"is_admin":           {Column: "is_admin", Type: listquery.Bool, Filterable: true},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+func (r UserRepository) GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.User], error) {
 	query := `
-		SELECT id, name, cpf_cnpj, stripe_customer_id FROM users
+		SELECT id, name, cpf_cnpj, stripe_customer_id, is_admin, create_date FROM users
+		WHERE deleted_at IS NULL
 	`
 
-	rows, err := r.db.Query(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []models.User
-
-	for rows.Next() {
+	return listquery.Fetch(ctx, r.db, userList, params, query, nil, func(row pgx.Row) (models.User, error) {
 		var user models.User
 
-		err := rows.Scan(&user.ID, &user.Name, &user.CpfCnpj, &user.StripeCustomerID)
-		if err != nil {
-			return nil, err
+		if err := row.Scan(&user.ID, &user.Name, &user.CpfCnpj, &user.StripeCustomerID, &user.IsAdmin, &user.CreateDate); err != nil {
+			return models.User{}, err
 		}
-		users = append(users, user)
-	}
 
-	return users, nil
+		var err error
+		user.CpfCnpj, err = r.cipher.Decrypt(user.CpfCnpj)
+
+		return user, err
+	})
 }
 
 func (r UserRepository) GetByGoogleSubscription(ctx context.Context, googleSubscription string) (models.User, error) {
@@ -68,7 +90,7 @@ func (r UserRepository) GetByGoogleSubscription(ctx context.Context, googleSubsc
 	query := `
 		SELECT id, google_sub,name, cpf_cnpj, stripe_customer_id, auth_provider, consent_terms, data_consent, create_date
 		FROM users
-		WHERE google_sub = $1
+		WHERE google_sub = $1 AND deleted_at IS NULL
 	`
 
 	var user models.User
@@ -87,11 +109,15 @@ func (r UserRepository) GetByGoogleSubscription(ctx context.Context, googleSubsc
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.User{}, errors.New("user not found")
+			return models.User{}, ErrUserNotFound
 		}
 		return models.User{}, err
 	}
 
+	if user.CpfCnpj, err = r.cipher.Decrypt(user.CpfCnpj); err != nil {
+		return models.User{}, err
+	}
+
 	return user, nil
 }
 
@@ -117,24 +143,69 @@ func (r UserRepository) GetByStripeCustomerID(ctx context.Context, stripeCustome
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.User{}, errors.New("user not found")
+			return models.User{}, ErrUserNotFound
 		}
 		return models.User{}, err
 	}
 
+	if user.CpfCnpj, err = r.cipher.Decrypt(user.CpfCnpj); err != nil {
+		return models.User{}, err
+	}
+
 	return user, nil
 }
 
 func (r UserRepository) GetByID(ctx context.Context, userID uint64) (models.User, error) {
 	query := `
-		SELECT id, name, cpf_cnpj, stripe_customer_id, auth_provider, consent_terms, data_consent, create_date
+		SELECT id, name, cpf_cnpj, stripe_customer_id, is_admin, auth_provider, consent_terms, data_consent, create_date
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var user models.User
 
 	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&user.ID,
+		&user.Name,
+		&user.CpfCnpj,
+		&user.StripeCustomerID,
+		&user.IsAdmin,
+		&user.AuthProvider,
+		&user.ConsentTerms,
+		&user.DataConsent,
+		&user.CreateDate,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+
+	if user.CpfCnpj, err = r.cipher.Decrypt(user.CpfCnpj); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// Busca exata pelo índice cego, sem decifrar a tabela
+func (r UserRepository) GetByCpfCnpj(ctx context.Context, cpfCnpj string) (models.User, error) {
+	query := `
+		SELECT id, name, cpf_cnpj, stripe_customer_id, auth_provider, consent_terms, data_consent, create_date
+		FROM users
+		WHERE cpf_cnpj_index = $1 AND deleted_at IS NULL
+	`
+
+	index := r.cipher.BlindIndex(cpfCnpj)
+	if index == "" {
+		return models.User{}, ErrUserNotFound
+	}
+
+	var user models.User
+
+	err := r.db.QueryRow(ctx, query, index).Scan(
 		&user.ID,
 		&user.Name,
 		&user.CpfCnpj,
@@ -147,48 +218,322 @@ func (r UserRepository) GetByID(ctx context.Context, userID uint64) (models.User
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return models.User{}, errors.New("user not found")
+			return models.User{}, ErrUserNotFound
 		}
 		return models.User{}, err
 	}
 
+	if user.CpfCnpj, err = r.cipher.Decrypt(user.CpfCnpj); err != nil {
+		return models.User{}, err
+	}
+
 	return user, nil
 }
 
+// Indica se outra conta ativa já usa o documento
+func (r UserRepository) ExistsByCpfCnpj(ctx context.Context, cpfCnpj string, exceptUserID uint64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE cpf_cnpj_index = $1 AND id <> $2 AND deleted_at IS NULL
+		)
+	`
+
+	var exists bool
+
+	if err := r.db.QueryRow(ctx, query, r.cipher.BlindIndex(cpfCnpj), exceptUserID).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Identificador do Google do usuário. Fica fora de GetByID para não ser devolvido pela API
+func (r UserRepository) GetGoogleSubByID(ctx context.Context, userID uint64) (string, error) {
+	query := `
+		SELECT google_sub FROM users WHERE id = $1
+	`
+
+	var googleSub string
+
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&googleSub); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+
+	return googleSub, nil
+}
+
+// Campos editáveis pelo próprio usuário. O stripe_customer_id só muda pelo checkout e pelos webhooks (SetStripeCustomerID)
 func (r UserRepository) Update(ctx context.Context, tx pgx.Tx, userID uint64, user models.User) (uint64, error) {
 
 	query := `
 		UPDATE users
-		SET name = $1, cpf_cnpj = $2, stripe_customer_id = $3, update_date = NOW()
-		WHERE id = $4
+		SET name = $1, cpf_cnpj = $2, cpf_cnpj_index = $3, cpf_cnpj_key_id = $4, update_date = NOW()
+		WHERE id = $5
 	`
 
-	result, err := tx.Exec(ctx, query, user.Name, user.CpfCnpj, user.StripeCustomerID, userID)
+	encrypted, err := r.encryptCpfCnpj(user.CpfCnpj)
 	if err != nil {
+		return 0, translate(err)
+	}
+
+	result, err := tx.Exec(ctx, query, user.Name, encrypted.value, encrypted.index, encrypted.keyID, userID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrUserNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+func (r UserRepository) SetStripeCustomerID(ctx context.Context, tx pgx.Tx, userID uint64, stripeCustomerID string) (uint64, error) {
+
+	query := `
+		UPDATE users
+		SET stripe_customer_id = $1, update_date = NOW()
+		WHERE id = $2
+	`
+
+	result, err := tx.Exec(ctx, query, stripeCustomerID, userID)
+	if err != nil {
+		return 0, translate(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return 0, ErrUserNotFound
+	}
+
+	return uint64(result.RowsAffected()), nil
+}
+
+// Apaga os dados pessoais mantendo o registro, para não quebrar o histórico de equipes e cobranças.
+// Também revoga todas as sessões abertas
+func (r UserRepository) Anonymize(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error) {
+	// google_sub é único: um valor aleatório libera a identidade sem ser previsível a partir do id
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
 		return 0, err
 	}
 
+	query := `
+		UPDATE users
+		SET name = 'Deleted user', cpf_cnpj = '', cpf_cnpj_index = NULL, cpf_cnpj_key_id = NULL, google_sub = $2,
+			is_admin = FALSE, sessions_revoked_at = NOW(), deleted_at = NOW(), update_date = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := tx.Exec(ctx, query, userID, "deleted:"+hex.EncodeToString(suffix))
+	if err != nil {
+		return 0, translate(err)
+	}
+
 	if result.RowsAffected() == 0 {
-		return 0, errors.New("no user updated")
+		return 0, ErrUserNotFound
 	}
 
 	return uint64(result.RowsAffected()), nil
 }
 
-func (r UserRepository) Delete(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error) {
+// Invalida todos os tokens emitidos até agora
+func (r UserRepository) RevokeSessions(ctx context.Context, tx pgx.Tx, userID uint64) (uint64, error) {
 	query := `
-		DELETE FROM users
+		UPDATE users
+		SET sessions_revoked_at = NOW()
 		WHERE id = $1
 	`
 
 	result, err := tx.Exec(ctx, query, userID)
 	if err != nil {
-		return 0, err
+		return 0, translate(err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return 0, err
+	return uint64(result.RowsAffected()), nil
+}
+
+// Momento a partir do qual os tokens do usuário voltam a valer. Nil se nunca houve revogação
+func (r UserRepository) GetSessionsRevokedAt(ctx context.Context, userID uint64) (*time.Time, error) {
+	query := `
+		SELECT sessions_revoked_at FROM users WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var revokedAt *time.Time
+
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&revokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return revokedAt, nil
+}
+
+// Trava a linha do usuário até o fim da transação. Serializa a criação de equipes do mesmo dono
+// para o limite do plano não ser ultrapassado por requisições simultâneas
+func (r UserRepository) LockForUpdate(ctx context.Context, tx pgx.Tx, userID uint64) error {
+	query := `
+		SELECT id FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`
+
+	var id uint64
+
+	if err := tx.QueryRow(ctx, query, userID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Se o usuário é administrador da plataforma. Consultado a cada requisição das rotas administrativas
+func (r UserRepository) IsAdmin(ctx context.Context, userID uint64) (bool, error) {
+	query := `
+		SELECT is_admin FROM users WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var isAdmin bool
+
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&isAdmin); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+
+	return isAdmin, nil
+}
+
+func (r UserRepository) SetAdmin(ctx context.Context, tx pgx.Tx, userID uint64, isAdmin bool) (uint64, error) {
+	query := `
+		UPDATE users
+		SET is_admin = $1, update_date = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := tx.Exec(ctx, query, isAdmin, userID)
+	if err != nil {
+		return 0, translate(err)
 	}
 
 	return uint64(result.RowsAffected()), nil
 }
+
+// Passa para a chave mestra ativa os CPF/CNPJ ainda em chaves antigas ou em texto puro, a partir de afterID.
+// Só a chave de dados é reembrulhada; o valor cifrado continua o mesmo. Um registro que falha
+// não interrompe o lote: fica em Failed e o cursor segue adiante
+func (r UserRepository) RotateCpfCnpj(ctx context.Context, tx pgx.Tx, afterID uint64, limit int) (models.KeyRotationBatch, error) {
+	query := `
+		SELECT id, cpf_cnpj FROM users
+		WHERE id > $1 AND cpf_cnpj <> '' AND cpf_cnpj_key_id IS DISTINCT FROM $2
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`
+
+	activeKeyID := r.cipher.ActiveKeyID()
+
+	rows, err := tx.Query(ctx, query, afterID, activeKeyID, limit)
+	if err != nil {
+		return models.KeyRotationBatch{}, translate(err)
+	}
+
+	type staleValue struct {
+		userID uint64
+		stored string
+	}
+
+	var stale []staleValue
+	for rows.Next() {
+		var value staleValue
+
+		if err := rows.Scan(&value.userID, &value.stored); err != nil {
+			rows.Close()
+			return models.KeyRotationBatch{}, err
+		}
+		stale = append(stale, value)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return models.KeyRotationBatch{}, translate(err)
+	}
+
+	batch := models.KeyRotationBatch{LastID: afterID, Scanned: len(stale), Failed: map[uint64]error{}}
+
+	for _, value := range stale {
+		batch.LastID = value.userID
+
+		if err := r.rotateCpfCnpj(ctx, tx, value.userID, value.stored); err != nil {
+			batch.Failed[value.userID] = err
+			continue
+		}
+		batch.Rotated++
+	}
+
+	return batch, nil
+}
+
+// Regrava um registro dentro de um savepoint, para que um erro do banco (ex: índice duplicado
+// de um CPF legado) não aborte a transação do lote
+func (r UserRepository) rotateCpfCnpj(ctx context.Context, tx pgx.Tx, userID uint64, stored string) error {
+	update := `
+		UPDATE users
+		SET cpf_cnpj = $1, cpf_cnpj_key_id = $2, cpf_cnpj_index = COALESCE($3, cpf_cnpj_index)
+		WHERE id = $4
+	`
+
+	// Texto puro legado ainda não tem índice
+	var index *string
+	if !pii.IsEncrypted(stored) {
+		blindIndex := r.cipher.BlindIndex(stored)
+		index = &blindIndex
+	}
+
+	rotated, _, err := r.cipher.Rotate(stored)
+	if err != nil {
+		return err
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer savepoint.Rollback(ctx)
+
+	if _, err := savepoint.Exec(ctx, update, rotated, pii.KeyID(rotated), index, userID); err != nil {
+		return translate(err)
+	}
+
+	return savepoint.Commit(ctx)
+}
+
+type encryptedValue struct {
+	value string
+	index *string
+	keyID *string
+}
+
+// Vazio fica vazio, sem índice nem chave
+func (r UserRepository) encryptCpfCnpj(cpfCnpj string) (encryptedValue, error) {
+	if cpfCnpj == "" {
+		return encryptedValue{}, nil
+	}
+
+	value, err := r.cipher.Encrypt(cpfCnpj)
+	if err != nil {
+		return encryptedValue{}, err
+	}
+
+	index := r.cipher.BlindIndex(cpfCnpj)
+	keyID := pii.KeyID(value)
+
+	return encryptedValue{value: value, index: &index, keyID: &keyID}, nil
+}
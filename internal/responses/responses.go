@@ -1,8 +1,9 @@
 package responses
 
 import (
+	"HareID/internal/apperrors"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -13,15 +14,69 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 
 	if data != nil {
 		if err := json.NewEncoder(w).Encode(data); err != nil {
-			log.Fatal(err)
+			slog.Error("error encoding response", "error", err)
 		}
 	}
 }
 
-func Error(w http.ResponseWriter, statusCode int, err error) {
-	JSON(w, statusCode, struct {
-		Err string `json:"error"`
-	}{
-		Err: err.Error(),
-	})
+// Corpo das respostas de erro (RFC 7807, application/problem+json)
+type ProblemDetails struct {
+	// urn:hareid:problem:<code>
+	Type   string `json:"type" example:"urn:hareid:problem:team_not_found"`
+	Title  string `json:"title" example:"Not Found"`
+	Status int    `json:"status" example:"404"`
+	Detail string `json:"detail" example:"team not found"`
+	// Caminho da requisição
+	Instance string `json:"instance,omitempty" example:"/teams/42"`
+	// Código estável do erro, o mesmo do type
+	Code string `json:"code" example:"team_not_found"`
+	// Mensagem de cada campo inválido (erros de validação)
+	Fields  map[string]string `json:"fields,omitempty"`
+	TraceID string            `json:"trace_id,omitempty"`
+}
+
+var statusByKind = map[apperrors.Kind]int{
+	apperrors.KindInternal:        http.StatusInternalServerError,
+	apperrors.KindInvalid:         http.StatusBadRequest,
+	apperrors.KindValidation:      http.StatusUnprocessableEntity,
+	apperrors.KindUnauthenticated: http.StatusUnauthorized,
+	apperrors.KindForbidden:       http.StatusForbidden,
+	apperrors.KindNotFound:        http.StatusNotFound,
+	apperrors.KindConflict:        http.StatusConflict,
+	apperrors.KindPaymentRequired: http.StatusPaymentRequired,
+}
+
+// Responde o erro no formato application/problem+json. O status vem da categoria do erro (apperrors.Kind);
+// erros sem tipo respondem 500 sem expor o detalhe, que vai para o log com o request_id e o trace_id
+func Problem(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := apperrors.From(err)
+
+	status, ok := statusByKind[appErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	if status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+		appErr = apperrors.ErrInternal
+	}
+
+	// O middleware de tracing publica o trace no header X-Trace-ID; repetido no corpo para o cliente informar no suporte
+	problem := ProblemDetails{
+		Type:     "urn:hareid:problem:" + appErr.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   appErr.Message,
+		Instance: r.URL.Path,
+		Code:     appErr.Code,
+		Fields:   appErr.Fields,
+		TraceID:  w.Header().Get("X-Trace-ID"),
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding response", "error", err)
+	}
 }
@@ -1,58 +1,141 @@
 package services
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
 	"HareID/internal/enums"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"HareID/internal/validators"
 	"context"
+	"log/slog"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TeamMembersServices struct {
-	repo repository.Repository
-	val  validators.Validations
-	db   *pgxpool.Pool
+	repo         repository.Repository
+	val          validators.Validations
+	db           *pgxpool.Pool
+	entitlements *EntitlementServices
+	billing      *SubscriptionServices
 }
 
 func (s *TeamMembersServices) Create(ctx context.Context, role enums.TeamRole, teamID, userID uint64) (models.TeamMember, error) {
+	ctx, span := tracing.Start(ctx, "TeamMembersServices.Create")
+	defer span.End()
 
-	tx, err := s.db.Begin(ctx)
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return models.TeamMember{}, err
 	}
 	defer tx.Rollback(ctx)
 
-	teamMember := models.TeamMember{
+	teamMember, err := s.add(ctx, tx, role, teamID, userID)
+	if err != nil {
+		return models.TeamMember{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.TeamMember{}, err
+	}
+
+	s.syncSeats(ctx, teamID)
+
+	return teamMember, nil
+}
+
+// Confere o limite de assentos e insere o membro na transação do chamador (ex: junto com o aceite do pedido)
+func (s *TeamMembersServices) add(ctx context.Context, tx pgx.Tx, role enums.TeamRole, teamID, userID uint64) (models.TeamMember, error) {
+	if err := s.entitlements.CanAddMember(ctx, tx, teamID); err != nil {
+		return models.TeamMember{}, err
+	}
+
+	teamMember, err := s.repo.TeamMembers.Create(ctx, tx, models.TeamMember{
 		Role:   role,
 		TeamID: teamID,
 		UserID: userID,
-	}
-
-	teamMember, err = s.repo.TeamMembers.Create(ctx, tx, teamMember)
+	})
 	if err != nil {
 		return models.TeamMember{}, err
 	}
 
-	if err = tx.Commit(ctx); err != nil {
+	if err := recordAudit(ctx, s.repo, tx, memberAudit(nil, models.AUDIT_MEMBER_ADDED, teamID, userID, role)); err != nil {
 		return models.TeamMember{}, err
 	}
 
 	return teamMember, nil
 }
 
-func (s *TeamMembersServices) GetAll(ctx context.Context, teamID uint64) ([]models.TeamMember, error) {
+// Ajusta a quantidade cobrada depois do commit. Falhas ficam para a conciliação
+func (s *TeamMembersServices) syncSeats(ctx context.Context, teamID uint64) {
+	if err := s.billing.SyncTeamSeats(ctx, teamID); err != nil {
+		slog.ErrorContext(ctx, "error syncing seats", "team_id", teamID, "error", err)
+	}
+}
+
+// Remove um membro da equipe. O dono pode remover qualquer membro e cada membro pode sair por conta própria
+func (s *TeamMembersServices) Delete(ctx context.Context, requestUserID, teamID, userID uint64) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "TeamMembersServices.Delete")
+	defer span.End()
+
+	team, err := s.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return 0, err
+	}
+
+	if requestUserID != team.OwnerID && requestUserID != userID {
+		return 0, apperrors.Forbidden("not_team_owner", "only the team owner can remove other members")
+	}
+
+	if userID == team.OwnerID {
+		return 0, apperrors.Conflict("owner_cannot_leave", "the team owner cannot be removed from the team")
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
 
-	teamMembers, err := s.repo.TeamMembers.GetAll(ctx, teamID)
+	affectedRows, err := s.repo.TeamMembers.Delete(ctx, tx, teamID, userID)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, memberAudit(&requestUserID, models.AUDIT_MEMBER_REMOVED, teamID, userID, enums.UNKNOWN)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	if err := s.billing.SyncTeamSeats(ctx, teamID); err != nil {
+		slog.ErrorContext(ctx, "error syncing seats", "team_id", teamID, "error", err)
 	}
 
-	return teamMembers, nil
+	return affectedRows, nil
 }
 
-func (s *TeamMembersServices) GetByUserID(ctx context.Context, userID uint64) (models.TeamMember, error) {
+func (s *TeamMembersServices) GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.TeamMember], error) {
+	ctx, span := tracing.Start(ctx, "TeamMembersServices.GetAll")
+	defer span.End()
+
+	return s.repo.TeamMembers.GetAll(ctx, teamID, params)
+}
+
+// Visível para o próprio usuário e os administradores da plataforma
+func (s *TeamMembersServices) GetByUserID(ctx context.Context, requestUserID, userID uint64) (models.TeamMember, error) {
+	ctx, span := tracing.Start(ctx, "TeamMembersServices.GetByUserID")
+	defer span.End()
+
+	if err := requireOwnerOrAdmin(ctx, s.repo, requestUserID, userID); err != nil {
+		return models.TeamMember{}, err
+	}
 
 	teamMember, err := s.repo.TeamMembers.GetByUserID(ctx, userID)
 	if err != nil {
@@ -62,3 +145,21 @@ func (s *TeamMembersServices) GetByUserID(ctx context.Context, userID uint64) (m
 	return teamMember, nil
 
 }
+
+// Entrada ou saída de membro. O papel só é registrado quando conhecido
+func memberAudit(actorID *uint64, action string, teamID, userID uint64, role enums.TeamRole) models.AuditLog {
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: models.AUDIT_TARGET_MEMBER,
+		TargetID:   auditID(userID),
+		TeamID:     &teamID,
+		UserID:     &userID,
+	}
+
+	if role != enums.UNKNOWN {
+		entry.After = audit.Snapshot(map[string]enums.TeamRole{"role": role})
+	}
+
+	return entry
+}
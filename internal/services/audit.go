@@ -0,0 +1,227 @@
+package services
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/enums"
+	"HareID/internal/listquery"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+	auditVerifyBatch  = 1000
+)
+
+type AuditServices struct {
+	repo         repository.Repository
+	db           *pgxpool.Pool
+	entitlements *EntitlementServices
+}
+
+// Trilha da equipe, visível para o dono e os administradores
+func (s *AuditServices) GetByTeamID(ctx context.Context, requestUserID, teamID uint64, filter models.AuditFilter) ([]models.AuditLog, error) {
+	ctx, span := tracing.Start(ctx, "AuditServices.GetByTeamID")
+	defer span.End()
+
+	team, err := s.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if team.OwnerID != requestUserID {
+		members, err := s.repo.TeamMembers.GetAll(ctx, teamID, listquery.Params{
+			Limit:   1,
+			Filters: []listquery.Filter{{Field: "user_id", Operator: listquery.Eq, Value: strconv.FormatUint(requestUserID, 10)}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		isAdmin := false
+		for _, member := range members.Items {
+			if member.UserID == requestUserID && member.Role == enums.ADMIN {
+				isAdmin = true
+			}
+		}
+
+		if !isAdmin {
+			return nil, ErrNotTeamAdmin
+		}
+	}
+
+	// Recurso pago da equipe: o plano pessoal de quem consulta não libera a trilha de outra equipe
+	check, err := s.entitlements.CheckTeamFeature(ctx, teamID, models.FEATURE_AUDIT_LOGS)
+	if err != nil {
+		return nil, err
+	}
+	if !check.Allowed {
+		return nil, apperrors.PaymentRequired(check.Reason, "the team's plan does not include "+models.FEATURE_AUDIT_LOGS)
+	}
+
+	filter.TeamID = &teamID
+	filter.UserID = nil
+
+	entries, err := s.repo.AuditLogs.GetAll(ctx, auditLimit(filter))
+	return orEmpty(entries), err
+}
+
+// Ações feitas pelo usuário ou que o afetaram
+func (s *AuditServices) GetByUserID(ctx context.Context, requestUserID, userID uint64, filter models.AuditFilter) ([]models.AuditLog, error) {
+	ctx, span := tracing.Start(ctx, "AuditServices.GetByUserID")
+	defer span.End()
+
+	if requestUserID != userID {
+		return nil, ErrNotDataOwner
+	}
+
+	filter.UserID = &userID
+	filter.TeamID = nil
+
+	entries, err := s.repo.AuditLogs.GetAll(ctx, auditLimit(filter))
+	return orEmpty(entries), err
+}
+
+// Recalcula a cadeia inteira e aponta a primeira entrada alterada, apagada ou fora de ordem
+func (s *AuditServices) Verify(ctx context.Context) (models.AuditVerification, error) {
+	ctx, span := tracing.Start(ctx, "AuditServices.Verify")
+	defer span.End()
+
+	result := models.AuditVerification{Valid: true}
+	var lastID uint64
+	prevHash := ""
+
+	for {
+		entries, err := s.repo.AuditLogs.GetChain(ctx, lastID, auditVerifyBatch)
+		if err != nil {
+			return models.AuditVerification{}, err
+		}
+
+		for _, entry := range entries {
+			result.Checked++
+
+			reason := ""
+			switch {
+			case entry.PrevHash != prevHash:
+				reason = "prev_hash does not match the previous entry"
+			case audit.Hash(entry) != entry.Hash:
+				reason = "hash does not match the entry content"
+			}
+
+			if reason != "" {
+				brokenAt := entry.ID
+				result.Valid = false
+				result.BrokenAt = &brokenAt
+				result.Reason = reason
+				return result, nil
+			}
+
+			prevHash = entry.Hash
+			lastID = entry.ID
+		}
+
+		if len(entries) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+func auditLimit(filter models.AuditFilter) models.AuditFilter {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	return filter
+}
+
+// Transação de uma alteração auditada. As entradas ficam em memória e só entram na cadeia no Commit:
+// o append trava a cadeia inteira até o fim da transação, então ele precisa ser o último passo para
+// que as demais transações auditadas esperem apenas o insert e o commit, e não toda a alteração
+type auditedTx struct {
+	pgx.Tx
+	repo    repository.Repository
+	entries []models.AuditLog
+}
+
+func beginAudited(ctx context.Context, db *pgxpool.Pool, repo repository.Repository) (pgx.Tx, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditedTx{Tx: tx, repo: repo}, nil
+}
+
+func (t *auditedTx) Commit(ctx context.Context) error {
+	for _, entry := range t.entries {
+		if _, err := t.repo.AuditLogs.Create(ctx, t.Tx, entry); err != nil {
+			return fmt.Errorf("audit %s: %w", entry.Action, err)
+		}
+	}
+	t.entries = nil
+
+	return t.Tx.Commit(ctx)
+}
+
+// Grava a entrada na mesma transação da alteração, então uma não existe sem a outra.
+// IP e request ID vêm da requisição; o autor, quando não informado, é o usuário autenticado.
+// Em transações abertas com beginAudited a gravação fica para o Commit
+func recordAudit(ctx context.Context, repo repository.Repository, tx pgx.Tx, entry models.AuditLog) error {
+
+	request := audit.FromContext(ctx)
+
+	if entry.ActorID == nil {
+		entry.ActorID = request.ActorID
+	}
+	entry.IP = request.IP
+	entry.RequestID = request.RequestID
+
+	if audited, ok := tx.(*auditedTx); ok {
+		audited.entries = append(audited.entries, entry)
+		return nil
+	}
+
+	if _, err := repo.AuditLogs.Create(ctx, tx, entry); err != nil {
+		return fmt.Errorf("audit %s: %w", entry.Action, err)
+	}
+
+	return nil
+}
+
+// Para ações que não gravam nada no banco (ex: login, chamadas ao provedor de pagamento)
+func recordAuditNow(ctx context.Context, repo repository.Repository, db *pgxpool.Pool, entry models.AuditLog) error {
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := recordAudit(ctx, repo, tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Before e after com apenas os campos alterados
+func auditDiff(entry models.AuditLog, before, after any) (models.AuditLog, error) {
+	var err error
+	entry.Before, entry.After, err = audit.Diff(before, after)
+	return entry, err
+}
+
+func auditID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"HareID/internal/audit"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Cadeia em memória que grava como o AuditLogRepository
+type fakeAuditChain struct {
+	entries []models.AuditLog
+}
+
+func (f *fakeAuditChain) Create(ctx context.Context, tx pgx.Tx, entry models.AuditLog) (models.AuditLog, error) {
+	if len(f.entries) > 0 {
+		entry.PrevHash = f.entries[len(f.entries)-1].Hash
+	}
+	entry.ID = uint64(len(f.entries) + 1)
+	entry.CreatedAt = time.Date(2026, 5, 20, 13, 0, len(f.entries), 0, time.UTC)
+	entry.Hash = audit.Hash(entry)
+
+	f.entries = append(f.entries, entry)
+	return entry, nil
+}
+
+func (f *fakeAuditChain) GetAll(ctx context.Context, filter models.AuditFilter) ([]models.AuditLog, error) {
+	return f.entries, nil
+}
+
+func (f *fakeAuditChain) GetChain(ctx context.Context, afterID uint64, limit int) ([]models.AuditLog, error) {
+	var chain []models.AuditLog
+	for _, entry := range f.entries {
+		if entry.ID > afterID && len(chain) < limit {
+			chain = append(chain, entry)
+		}
+	}
+	return chain, nil
+}
+
+// Transação falsa: só o Commit é chamado pelo auditedTx
+type fakeTx struct {
+	pgx.Tx
+	committed bool
+}
+
+func (t *fakeTx) Commit(ctx context.Context) error {
+	t.committed = true
+	return nil
+}
+
+func TestAuditedTxAppendsOnCommit(t *testing.T) {
+	chain := &fakeAuditChain{}
+	repo := repository.Repository{AuditLogs: chain}
+	inner := &fakeTx{}
+	tx := &auditedTx{Tx: inner, repo: repo}
+
+	ctx := audit.WithActor(audit.WithRequest(context.Background(), audit.Request{IP: "203.0.113.9", RequestID: "req-1"}), 7)
+
+	for _, action := range []string{models.AUDIT_TEAM_CREATED, models.AUDIT_MEMBER_ADDED} {
+		if err := recordAudit(ctx, repo, tx, models.AuditLog{Action: action, TargetType: models.AUDIT_TARGET_TEAM, TargetID: "42"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(chain.entries) != 0 {
+		t.Fatalf("%d entries appended before commit", len(chain.entries))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !inner.committed {
+		t.Error("transaction was not committed")
+	}
+	if len(chain.entries) != 2 || chain.entries[0].Action != models.AUDIT_TEAM_CREATED || chain.entries[1].Action != models.AUDIT_MEMBER_ADDED {
+		t.Fatalf("entries = %+v", chain.entries)
+	}
+
+	first := chain.entries[0]
+	if first.ActorID == nil || *first.ActorID != 7 || first.IP != "203.0.113.9" || first.RequestID != "req-1" {
+		t.Errorf("request data not recorded: %+v", first)
+	}
+	if chain.entries[1].PrevHash != first.Hash {
+		t.Error("second entry does not point to the first")
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		entries    int
+		tamper     func([]models.AuditLog) []models.AuditLog
+		wantValid  bool
+		wantBroken uint64
+		wantReason string
+	}{
+		{"empty chain", 0, nil, true, 0, ""},
+		{"intact chain", 5, nil, true, 0, ""},
+		{"edited content", 5, func(e []models.AuditLog) []models.AuditLog {
+			e[2].TargetID = "99"
+			return e
+		}, false, 3, "hash does not match the entry content"},
+		{"edited content with recomputed hash", 5, func(e []models.AuditLog) []models.AuditLog {
+			e[2].TargetID = "99"
+			e[2].Hash = audit.Hash(e[2])
+			return e
+		}, false, 4, "prev_hash does not match the previous entry"},
+		{"deleted entry", 5, func(e []models.AuditLog) []models.AuditLog {
+			return append(e[:1], e[2:]...)
+		}, false, 3, "prev_hash does not match the previous entry"},
+		{"swapped entries", 5, func(e []models.AuditLog) []models.AuditLog {
+			e[1], e[2] = e[2], e[1]
+			e[1].ID, e[2].ID = e[2].ID, e[1].ID
+			return e
+		}, false, 2, "prev_hash does not match the previous entry"},
+		{"first entry with a previous hash", 3, func(e []models.AuditLog) []models.AuditLog {
+			e[0].PrevHash = "forged"
+			return e
+		}, false, 1, "prev_hash does not match the previous entry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &fakeAuditChain{}
+			for i := 0; i < tt.entries; i++ {
+				if _, err := chain.Create(context.Background(), nil, models.AuditLog{Action: models.AUDIT_TEAM_UPDATED, TargetID: auditID(uint64(i))}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tamper != nil {
+				chain.entries = tt.tamper(chain.entries)
+			}
+
+			service := &AuditServices{repo: repository.Repository{AuditLogs: chain}}
+
+			result, err := service.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Valid != tt.wantValid || result.Reason != tt.wantReason {
+				t.Fatalf("result = %+v, want valid %v (%s)", result, tt.wantValid, tt.wantReason)
+			}
+			if !tt.wantValid && (result.BrokenAt == nil || *result.BrokenAt != tt.wantBroken) {
+				t.Errorf("broken at = %v, want %d", result.BrokenAt, tt.wantBroken)
+			}
+		})
+	}
+}
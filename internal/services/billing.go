@@ -0,0 +1,74 @@
+package services
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
+)
+
+type BillingServices struct {
+	repo   repository.Repository
+	stripe *client.API
+}
+
+// Abre o portal do Stripe para o cliente gerenciar cartões, faturas e cancelamento
+func (s *BillingServices) CreatePortalSession(ctx context.Context, userID uint64, returnURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "BillingServices.CreatePortalSession")
+	defer span.End()
+
+	if returnURL == "" {
+		return "", apperrors.Field("return_url", "is required")
+	}
+
+	user, err := s.repo.Users.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if user.StripeCustomerID == "" {
+		return "", ErrNoBillingAccount
+	}
+
+	params := &stripe.BillingPortalSessionParams{
+		Customer:  stripe.String(user.StripeCustomerID),
+		ReturnURL: stripe.String(returnURL),
+	}
+	params.Context = ctx
+
+	sess, err := s.stripe.BillingPortalSessions.New(params)
+	if err != nil {
+		return "", err
+	}
+
+	return sess.URL, nil
+}
+
+// Histórico de testes, lembretes e fim de carência das assinaturas do usuário
+func (s *BillingServices) GetHistory(ctx context.Context, userID uint64) ([]models.BillingHistory, error) {
+	ctx, span := tracing.Start(ctx, "BillingServices.GetHistory")
+	defer span.End()
+
+	history, err := s.repo.BillingHistory.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (s *BillingServices) GetInvoices(ctx context.Context, userID uint64) ([]models.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "BillingServices.GetInvoices")
+	defer span.End()
+
+	invoices, err := s.repo.Invoices.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
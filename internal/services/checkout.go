@@ -1,47 +1,181 @@
 package services
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"HareID/internal/validators"
 	"context"
-	"errors"
-	"fmt"
+	"log/slog"
 
-	"github.com/stripe/stripe-go/v79"
-	"github.com/stripe/stripe-go/v79/checkout/session"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type CheckoutServices struct{}
+type CheckoutServices struct {
+	repo      repository.Repository
+	db        *pgxpool.Pool
+	providers payments.Registry
+}
 
 func (s *CheckoutServices) CreateCheckoutSession(ctx context.Context, userID uint64, priceID, successURL, cancelURL string) (string, error) {
-	if priceID == "" {
-		return "", errors.New("price_id is required")
+	ctx, span := tracing.Start(ctx, "CheckoutServices.CreateCheckoutSession")
+	defer span.End()
+
+	if err := validateCheckout(priceID, successURL, cancelURL); err != nil {
+		return "", err
 	}
-	if successURL == "" {
-		return "", errors.New("success_url is required")
+
+	return s.checkout(ctx, userID, nil, priceID, 1, successURL, cancelURL)
+}
+
+// Cria o checkout da equipe cobrando um assento por membro
+func (s *CheckoutServices) CreateTeamCheckoutSession(ctx context.Context, requestUserID, teamID uint64, priceID, successURL, cancelURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "CheckoutServices.CreateTeamCheckoutSession")
+	defer span.End()
+
+	if err := validateCheckout(priceID, successURL, cancelURL); err != nil {
+		return "", err
 	}
-	if cancelURL == "" {
-		return "", errors.New("cancel_url is required")
+
+	team, err := s.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return "", err
 	}
 
-	params := &stripe.CheckoutSessionParams{
-		PaymentMethodTypes: stripe.StringSlice([]string{
-			"card",
-		}),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				Price:    stripe.String(priceID),
-				Quantity: stripe.Int64(1),
-			},
-		},
-		Mode:              stripe.String(string(stripe.CheckoutSessionModeSubscription)),
-		SuccessURL:        stripe.String(successURL),
-		CancelURL:         stripe.String(cancelURL),
-		ClientReferenceID: stripe.String(fmt.Sprintf("%d", userID)),
-	}
-
-	sess, err := session.New(params)
+	if team.OwnerID != requestUserID {
+		return "", apperrors.Forbidden("not_team_owner", "only the team owner can start the team checkout")
+	}
+
+	seats, err := s.repo.TeamMembers.CountByTeamID(ctx, teamID)
 	if err != nil {
 		return "", err
 	}
 
-	return sess.URL, nil
+	return s.checkout(ctx, requestUserID, &teamID, priceID, int64(seats), successURL, cancelURL)
+}
+
+// Abre o checkout no provedor configurado para o plano. Preços fora da tabela plans seguem para o Stripe
+func (s *CheckoutServices) checkout(ctx context.Context, userID uint64, teamID *uint64, priceID string, quantity int64, successURL, cancelURL string) (string, error) {
+
+	plan, err := s.repo.Plans.GetByPriceID(ctx, priceID)
+	if err != nil {
+		plan = models.Plan{PriceID: priceID, Provider: payments.STRIPE}
+	}
+
+	provider, err := s.providers.Get(plan.Provider)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.repo.Users.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	req := payments.CheckoutRequest{
+		UserID:     userID,
+		TeamID:     teamID,
+		Name:       user.Name,
+		CpfCnpj:    user.CpfCnpj,
+		PriceID:    priceID,
+		Amount:     plan.Amount,
+		Quantity:   quantity,
+		SuccessURL: successURL,
+		CancelURL:  cancelURL,
+	}
+
+	// O cliente gravado no usuário é do Stripe
+	if provider.Name() == payments.STRIPE {
+		req.CustomerID = user.StripeCustomerID
+	}
+
+	session, err := provider.CreateCheckout(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	entry := models.AuditLog{
+		ActorID:    &userID,
+		Action:     models.AUDIT_CHECKOUT_STARTED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		TeamID:     teamID,
+		UserID:     &userID,
+		After: audit.Snapshot(map[string]any{
+			"provider": provider.Name(),
+			"price_id": priceID,
+			"quantity": quantity,
+		}),
+	}
+
+	// Provedores que já criam a assinatura no checkout ganham o registro local agora; os webhooks atualizam o status
+	var pending *models.Subscription
+	if session.SubscriptionID != "" {
+		pending = &models.Subscription{
+			UserID:         userID,
+			TeamID:         teamID,
+			SubscriptionID: session.SubscriptionID,
+			Provider:       provider.Name(),
+			PriceID:        priceID,
+			Quantity:       quantity,
+			Status:         subscription.INCOMPLETE,
+		}
+	}
+
+	if err := s.record(ctx, entry, pending); err != nil {
+		// Sem o registro local nenhum webhook acharia a assinatura: ela é cancelada no provedor para não cobrar
+		if pending != nil {
+			if cancelErr := provider.Cancel(context.WithoutCancel(ctx), pending.SubscriptionID, false); cancelErr != nil {
+				slog.ErrorContext(ctx, "error canceling subscription after failed checkout", "provider", provider.Name(), "subscription_id", pending.SubscriptionID, "error", cancelErr)
+			}
+		}
+		return "", err
+	}
+
+	return session.URL, nil
+}
+
+// Grava a auditoria do checkout e a assinatura pendente, quando houver, na mesma transação
+func (s *CheckoutServices) record(ctx context.Context, entry models.AuditLog, pending *models.Subscription) error {
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if pending != nil {
+		if _, err := s.repo.Subscriptions.Create(ctx, tx, *pending); err != nil {
+			return err
+		}
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Lista todos os campos obrigatórios ausentes de uma vez
+func validateCheckout(priceID, successURL, cancelURL string) error {
+	missing := validators.FieldErrors{}
+	if priceID == "" {
+		missing["price_id"] = "is required"
+	}
+	if successURL == "" {
+		missing["success_url"] = "is required"
+	}
+	if cancelURL == "" {
+		missing["cancel_url"] = "is required"
+	}
+
+	if len(missing) > 0 {
+		return missing
+	}
+	return nil
 }
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/authentication"
+	"HareID/internal/enums"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Finalidades sem as quais o usuário não recebe o token completo. Marketing é opcional
+var requiredConsents = []enums.ConsentPurpose{enums.CONSENT_TERMS, enums.CONSENT_DATA_PROCESSING}
+
+type ConsentServices struct {
+	repo repository.Repository
+	db   *pgxpool.Pool
+}
+
+func (s *ConsentServices) GetCurrentTerms(ctx context.Context) ([]models.TermsVersion, error) {
+	ctx, span := tracing.Start(ctx, "ConsentServices.GetCurrentTerms")
+	defer span.End()
+
+	versions, err := s.repo.TermsVersions.GetCurrent(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return orEmpty(versions), nil
+}
+
+// Publica uma nova versão. Quem aceitou a anterior precisa consentir de novo no próximo login
+func (s *ConsentServices) PublishTerms(ctx context.Context, version models.TermsVersion) (models.TermsVersion, error) {
+	ctx, span := tracing.Start(ctx, "ConsentServices.PublishTerms")
+	defer span.End()
+
+	version.Version = strings.TrimSpace(version.Version)
+	version.ContentURL = strings.TrimSpace(version.ContentURL)
+
+	if err := validatePurpose(version.Purpose); err != nil {
+		return models.TermsVersion{}, err
+	}
+	if version.Version == "" {
+		return models.TermsVersion{}, apperrors.Field("version", "is required")
+	}
+	if version.ContentURL == "" {
+		return models.TermsVersion{}, apperrors.Field("content_url", "is required")
+	}
+	if version.PublishedAt.IsZero() {
+		version.PublishedAt = time.Now()
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return models.TermsVersion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := s.repo.TermsVersions.Create(ctx, tx, version)
+	if err != nil {
+		return models.TermsVersion{}, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		Action:     models.AUDIT_TERMS_PUBLISHED,
+		TargetType: models.AUDIT_TARGET_TERMS,
+		TargetID:   auditID(created.ID),
+		After: audit.Snapshot(map[string]any{
+			"purpose":     created.Purpose,
+			"version":     created.Version,
+			"content_url": created.ContentURL,
+		}),
+	}); err != nil {
+		return models.TermsVersion{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.TermsVersion{}, err
+	}
+
+	return created, nil
+}
+
+// Situação atual, histórico e pendências do usuário
+func (s *ConsentServices) GetByUserID(ctx context.Context, userID uint64) (models.ConsentOverview, error) {
+	ctx, span := tracing.Start(ctx, "ConsentServices.GetByUserID")
+	defer span.End()
+
+	current, err := s.repo.TermsVersions.GetCurrent(ctx)
+	if err != nil {
+		return models.ConsentOverview{}, err
+	}
+
+	latest, err := s.repo.Consents.GetLatestByUserID(ctx, userID)
+	if err != nil {
+		return models.ConsentOverview{}, err
+	}
+
+	history, err := s.repo.Consents.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return models.ConsentOverview{}, err
+	}
+
+	overview := models.ConsentOverview{
+		Current: make([]models.ConsentStatus, 0, len(latest)),
+		History: orEmpty(history),
+		Pending: pendingTerms(current, latest),
+	}
+
+	for _, consent := range latest {
+		status := models.ConsentStatus{
+			Purpose:        consent.Purpose,
+			Granted:        consent.Granted,
+			TermsVersionID: consent.TermsVersionID,
+			UpToDate:       consent.Granted,
+			UpdatedAt:      consent.CreatedAt,
+		}
+		if version, ok := versionFor(current, consent.Purpose); ok && consent.Granted {
+			status.UpToDate = consent.TermsVersionID != nil && *consent.TermsVersionID == version.ID
+		}
+		overview.Current = append(overview.Current, status)
+	}
+
+	return overview, nil
+}
+
+// Grava aceites e revogações. Revogar uma finalidade obrigatória encerra todas as sessões do usuário
+func (s *ConsentServices) Record(ctx context.Context, userID uint64, requests []models.UserConsent, source models.ConsentSource) (models.ConsentResult, error) {
+	ctx, span := tracing.Start(ctx, "ConsentServices.Record")
+	defer span.End()
+
+	if len(requests) == 0 {
+		return models.ConsentResult{}, apperrors.Field("consents", "is required")
+	}
+
+	current, err := s.repo.TermsVersions.GetCurrent(ctx)
+	if err != nil {
+		return models.ConsentResult{}, err
+	}
+
+	googleSub, err := s.repo.Users.GetGoogleSubByID(ctx, userID)
+	if err != nil {
+		return models.ConsentResult{}, err
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return models.ConsentResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	result := models.ConsentResult{Consents: make([]models.UserConsent, 0, len(requests))}
+	withdrewRequired := false
+
+	for i, request := range requests {
+		consent, err := s.record(ctx, tx, userID, request, current, source)
+		if err != nil {
+			return models.ConsentResult{}, fmt.Errorf("consent %d: %w", i, err)
+		}
+
+		if !consent.Granted && isRequiredConsent(consent.Purpose) {
+			withdrewRequired = true
+		}
+
+		if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+			ActorID:    &userID,
+			Action:     models.AUDIT_CONSENT_RECORDED,
+			TargetType: models.AUDIT_TARGET_USER,
+			TargetID:   auditID(userID),
+			UserID:     &userID,
+			After: audit.Snapshot(map[string]any{
+				"purpose":          consent.Purpose,
+				"granted":          consent.Granted,
+				"terms_version_id": consent.TermsVersionID,
+			}),
+		}); err != nil {
+			return models.ConsentResult{}, err
+		}
+
+		result.Consents = append(result.Consents, consent)
+	}
+
+	if withdrewRequired {
+		if _, err := s.repo.Users.RevokeSessions(ctx, tx, userID); err != nil {
+			return models.ConsentResult{}, err
+		}
+
+		if err := recordAudit(ctx, s.repo, tx, sessionsRevokedAudit(&userID, userID, "required consent withdrawn")); err != nil {
+			return models.ConsentResult{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.ConsentResult{}, err
+	}
+
+	latest, err := s.repo.Consents.GetLatestByUserID(ctx, userID)
+	if err != nil {
+		return models.ConsentResult{}, err
+	}
+
+	result.Pending = pendingTerms(current, latest)
+
+	if len(result.Pending) == 0 && !withdrewRequired {
+		if result.Token, err = authentication.CreateToken(googleSub, userID); err != nil {
+			return models.ConsentResult{}, err
+		}
+
+		if err := recordAuditNow(ctx, s.repo, s.db, models.AuditLog{
+			ActorID:    &userID,
+			Action:     models.AUDIT_TOKEN_ISSUED,
+			TargetType: models.AUDIT_TARGET_USER,
+			TargetID:   auditID(userID),
+			UserID:     &userID,
+			After:      audit.Snapshot(map[string]string{"token_scope": "session", "reason": "terms accepted"}),
+		}); err != nil {
+			return models.ConsentResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ConsentServices) record(ctx context.Context, tx pgx.Tx, userID uint64, request models.UserConsent, current []models.TermsVersion, source models.ConsentSource) (models.UserConsent, error) {
+
+	if err := validatePurpose(request.Purpose); err != nil {
+		return models.UserConsent{}, err
+	}
+
+	consent := models.UserConsent{
+		UserID:    userID,
+		Purpose:   request.Purpose,
+		Granted:   request.Granted,
+		IP:        source.IP,
+		UserAgent: source.UserAgent,
+	}
+
+	// O aceite precisa citar a versão vigente, para provar o que foi aceito. A revogação vale para qualquer versão
+	if version, ok := versionFor(current, request.Purpose); ok && request.Granted {
+		if request.TermsVersionID == nil || *request.TermsVersionID != version.ID {
+			return models.UserConsent{}, apperrors.Field("terms_version_id", fmt.Sprintf("must be the current version (%d)", version.ID))
+		}
+		consent.TermsVersionID = &version.ID
+	}
+
+	return s.repo.Consents.Create(ctx, tx, consent)
+}
+
+func sessionsRevokedAudit(actorID *uint64, userID uint64, reason string) models.AuditLog {
+	return models.AuditLog{
+		ActorID:    actorID,
+		Action:     models.AUDIT_SESSIONS_REVOKE,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+		After:      audit.Snapshot(map[string]string{"reason": reason}),
+	}
+}
+
+// Grava o aceite feito no cadastro, vinculado às versões vigentes
+func recordSignupConsents(ctx context.Context, repo repository.Repository, tx pgx.Tx, user models.User, source models.ConsentSource) error {
+
+	if !user.ConsentTerms {
+		return nil
+	}
+
+	current, err := repo.TermsVersions.GetCurrent(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, purpose := range requiredConsents {
+		consent := models.UserConsent{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			Granted:   true,
+			IP:        source.IP,
+			UserAgent: source.UserAgent,
+		}
+		if version, ok := versionFor(current, purpose); ok {
+			consent.TermsVersionID = &version.ID
+		}
+		if _, err := repo.Consents.Create(ctx, tx, consent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Versões obrigatórias vigentes que o usuário não aceitou (ou revogou)
+func pendingTerms(current []models.TermsVersion, latest []models.UserConsent) []models.TermsVersion {
+
+	pending := []models.TermsVersion{}
+
+	for _, version := range current {
+		if !isRequiredConsent(version.Purpose) {
+			continue
+		}
+
+		accepted := false
+		for _, consent := range latest {
+			if consent.Purpose == version.Purpose && consent.Granted &&
+				consent.TermsVersionID != nil && *consent.TermsVersionID == version.ID {
+				accepted = true
+			}
+		}
+
+		if !accepted {
+			pending = append(pending, version)
+		}
+	}
+
+	return pending
+}
+
+func versionFor(current []models.TermsVersion, purpose enums.ConsentPurpose) (models.TermsVersion, bool) {
+	for _, version := range current {
+		if version.Purpose == purpose {
+			return version, true
+		}
+	}
+	return models.TermsVersion{}, false
+}
+
+func isRequiredConsent(purpose enums.ConsentPurpose) bool {
+	for _, required := range requiredConsents {
+		if purpose == required {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePurpose(purpose enums.ConsentPurpose) error {
+	switch purpose {
+	case enums.CONSENT_TERMS, enums.CONSENT_MARKETING, enums.CONSENT_DATA_PROCESSING:
+		return nil
+	default:
+		return apperrors.Field("purpose", "is unknown")
+	}
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tempo em que um pedido reservado fica invisível para outros workers
+const deletionLease = 10 * time.Minute
+
+type DeletionServices struct {
+	repo          repository.Repository
+	db            *pgxpool.Pool
+	cfg           config.Config
+	subscriptions *SubscriptionServices
+	entitlements  *EntitlementServices
+}
+
+// Agenda a exclusão da conta para depois do prazo de arrependimento
+func (s *DeletionServices) Schedule(ctx context.Context, requestUserID, userID uint64, teamPolicy string) (models.AccountDeletion, error) {
+	ctx, span := tracing.Start(ctx, "DeletionServices.Schedule")
+	defer span.End()
+
+	if requestUserID != userID {
+		return models.AccountDeletion{}, ErrNotDataOwner
+	}
+
+	if teamPolicy == "" {
+		teamPolicy = s.cfg.Privacy.DeletionTeamPolicy
+	}
+	if teamPolicy != models.TEAM_POLICY_TRANSFER && teamPolicy != models.TEAM_POLICY_DISSOLVE {
+		return models.AccountDeletion{}, apperrors.Field("team_policy", "must be transfer or dissolve")
+	}
+
+	if latest, err := s.repo.AccountDeletions.GetLatestByUserID(ctx, userID); err == nil && latest.Status == enums.DELETION_SCHEDULED {
+		return latest, nil
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	deletion, err := s.repo.AccountDeletions.Create(ctx, tx, models.AccountDeletion{
+		UserID:       userID,
+		TeamPolicy:   teamPolicy,
+		ScheduledFor: time.Now().Add(s.cfg.Privacy.DeletionCoolingOff),
+	})
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_DELETION_SCHEDULED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+		After: audit.Snapshot(map[string]any{
+			"team_policy":   deletion.TeamPolicy,
+			"scheduled_for": deletion.ScheduledFor,
+		}),
+	}); err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	return deletion, nil
+}
+
+// Desiste da exclusão enquanto o prazo de arrependimento não acabou
+func (s *DeletionServices) Cancel(ctx context.Context, requestUserID, userID uint64) (models.AccountDeletion, error) {
+	ctx, span := tracing.Start(ctx, "DeletionServices.Cancel")
+	defer span.End()
+
+	if requestUserID != userID {
+		return models.AccountDeletion{}, ErrNotDataOwner
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	canceled, err := s.repo.AccountDeletions.Cancel(ctx, tx, userID)
+	if err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	if canceled == 0 {
+		return models.AccountDeletion{}, apperrors.Conflict("deletion_not_cancelable", "no account deletion can be canceled")
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_DELETION_CANCELED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+	}); err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.AccountDeletion{}, err
+	}
+
+	return s.repo.AccountDeletions.GetLatestByUserID(ctx, userID)
+}
+
+func (s *DeletionServices) Get(ctx context.Context, requestUserID, userID uint64) (models.AccountDeletion, error) {
+	ctx, span := tracing.Start(ctx, "DeletionServices.Get")
+	defer span.End()
+
+	if requestUserID != userID {
+		return models.AccountDeletion{}, ErrNotDataOwner
+	}
+
+	return s.repo.AccountDeletions.GetLatestByUserID(ctx, userID)
+}
+
+// Executa as exclusões com prazo vencido. Retorna quantos pedidos foram reservados
+func (s *DeletionServices) ProcessDue(ctx context.Context, batchSize int) (int, error) {
+	ctx, span := tracing.Start(ctx, "DeletionServices.ProcessDue")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	deletions, err := s.repo.AccountDeletions.ClaimDue(ctx, tx, batchSize, deletionLease)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	for _, deletion := range deletions {
+		if execErr := s.execute(ctx, deletion); execErr != nil {
+			slog.ErrorContext(ctx, "account deletion failed", "deletion_id", deletion.ID, "attempt", deletion.Attempts, "error", execErr)
+			if err := s.retry(ctx, deletion, execErr); err != nil {
+				return len(deletions), err
+			}
+		}
+	}
+
+	return len(deletions), nil
+}
+
+// Cancela no provedor as assinaturas que deixam de existir e, numa única transação, resolve as equipes, apaga as
+// notificações e as exportações, anonimiza o usuário (revogando as sessões) e grava o tombstone
+func (s *DeletionServices) execute(ctx context.Context, deletion models.AccountDeletion) error {
+
+	googleSub, err := s.repo.Users.GetGoogleSubByID(ctx, deletion.UserID)
+	if err != nil {
+		return err
+	}
+
+	owned, err := s.repo.Teams.GetAllByOwnerID(ctx, deletion.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Os sucessores são escolhidos antes do cancelamento: equipes transferidas mantêm a assinatura
+	successors, err := s.successors(ctx, owned, deletion.TeamPolicy)
+	if err != nil {
+		return err
+	}
+
+	dissolved := make(map[uint64]bool)
+	for _, team := range owned {
+		if _, ok := successors[team.ID]; !ok {
+			dissolved[team.ID] = true
+		}
+	}
+
+	if err := s.cancelSubscriptions(ctx, deletion.UserID, dissolved); err != nil {
+		return err
+	}
+
+	memberships, err := s.repo.TeamMembers.GetAllByUserID(ctx, deletion.UserID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tombstone := models.UserTombstone{
+		UserID:       deletion.UserID,
+		IdentityHash: s.identityHash(googleSub),
+		TeamPolicy:   deletion.TeamPolicy,
+		RequestedAt:  deletion.RequestedAt,
+	}
+
+	// Equipes que continuam existindo sem o usuário e precisam recalcular os assentos
+	var remaining []uint64
+
+	for _, team := range owned {
+		successor, transferred := successors[team.ID]
+		if err := s.resolveTeam(ctx, tx, team, successor, transferred); err != nil {
+			return fmt.Errorf("team %d: %w", team.ID, err)
+		}
+		if transferred {
+			tombstone.TeamsTransferred++
+			remaining = append(remaining, team.ID)
+		} else {
+			tombstone.TeamsDissolved++
+		}
+	}
+
+	for _, membership := range memberships {
+		if slices.ContainsFunc(owned, func(team models.Team) bool { return team.ID == membership.TeamID }) {
+			continue
+		}
+		if _, err := s.repo.TeamMembers.Delete(ctx, tx, membership.TeamID, deletion.UserID); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.repo, tx, memberAudit(nil, models.AUDIT_MEMBER_REMOVED, membership.TeamID, deletion.UserID, enums.UNKNOWN)); err != nil {
+			return err
+		}
+		remaining = append(remaining, membership.TeamID)
+	}
+
+	if _, err := s.repo.Notifications.DeleteByUserID(ctx, tx, deletion.UserID); err != nil {
+		return err
+	}
+
+	// Exportações na fila ou prontas deixam de existir; os links já enviados param de funcionar
+	exportFiles, err := s.repo.DataExports.DeleteByUserID(ctx, tx, deletion.UserID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repo.Users.Anonymize(ctx, tx, deletion.UserID); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		Action:     models.AUDIT_USER_ANONYMIZED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(deletion.UserID),
+		UserID:     &deletion.UserID,
+		After: audit.Snapshot(map[string]any{
+			"teams_transferred":    tombstone.TeamsTransferred,
+			"teams_dissolved":      tombstone.TeamsDissolved,
+			"export_files_deleted": len(exportFiles),
+		}),
+	}); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, sessionsRevokedAudit(nil, deletion.UserID, "account deleted")); err != nil {
+		return err
+	}
+
+	if err := s.repo.AccountDeletions.CreateTombstone(ctx, tx, tombstone); err != nil {
+		return err
+	}
+
+	completed, err := s.repo.AccountDeletions.Complete(ctx, tx, deletion.ID)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return apperrors.Conflict("deletion_not_scheduled", "account deletion is no longer scheduled")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	s.entitlements.Invalidate()
+
+	// Uma falha aqui deixa só o arquivo, já sem link válido; o pedido não é refeito por isso
+	for _, filePath := range exportFiles {
+		if err := removeExportFile(filePath); err != nil {
+			slog.ErrorContext(ctx, "error removing data export after account deletion", "deletion_id", deletion.ID, "error", err)
+		}
+	}
+
+	// Os assentos cobrados acompanham a saída do usuário; falhas são corrigidas pela conciliação
+	for _, teamID := range remaining {
+		if err := s.subscriptions.SyncTeamSeats(ctx, teamID); err != nil {
+			slog.ErrorContext(ctx, "error syncing seats after account deletion", "deletion_id", deletion.ID, "team_id", teamID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Encerra imediatamente a assinatura pessoal e as das equipes que serão dissolvidas
+func (s *DeletionServices) cancelSubscriptions(ctx context.Context, userID uint64, dissolved map[uint64]bool) error {
+
+	subscriptions, err := s.repo.Subscriptions.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subscriptionsToCancel(subscriptions, dissolved) {
+		if err := s.subscriptions.Cancel(ctx, userID, sub.SubscriptionID, false); err != nil {
+			return fmt.Errorf("cancel subscription %s: %w", sub.SubscriptionID, err)
+		}
+	}
+
+	return nil
+}
+
+// Assinaturas ainda vigentes que são pessoais ou de uma equipe dissolvida
+func subscriptionsToCancel(subscriptions []models.Subscription, dissolved map[uint64]bool) []models.Subscription {
+	var cancel []models.Subscription
+	for _, sub := range subscriptions {
+		if sub.Status == subscription.CANCELED || sub.Status == subscription.INCOMPLETE_EXPIRED {
+			continue
+		}
+		if sub.TeamID == nil || dissolved[*sub.TeamID] {
+			cancel = append(cancel, sub)
+		}
+	}
+	return cancel
+}
+
+// Sucessor de cada equipe com a política transfer: um administrador ou o membro mais antigo.
+// Equipes fora do mapa são dissolvidas
+func (s *DeletionServices) successors(ctx context.Context, owned []models.Team, teamPolicy string) (map[uint64]models.TeamMember, error) {
+
+	successors := make(map[uint64]models.TeamMember)
+	if teamPolicy != models.TEAM_POLICY_TRANSFER {
+		return successors, nil
+	}
+
+	for _, team := range owned {
+		members, err := listquery.All(ctx, func(ctx context.Context, params listquery.Params) (listquery.Page[models.TeamMember], error) {
+			return s.repo.TeamMembers.GetAll(ctx, team.ID, params)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("team %d: %w", team.ID, err)
+		}
+
+		if successor, ok := teamSuccessor(members, team.OwnerID); ok {
+			successors[team.ID] = successor
+		}
+	}
+
+	return successors, nil
+}
+
+// Transfere a equipe para o sucessor ou, sem ele, a dissolve
+func (s *DeletionServices) resolveTeam(ctx context.Context, tx pgx.Tx, team models.Team, successor models.TeamMember, transfer bool) error {
+
+	if transfer {
+		if _, err := s.repo.Teams.UpdateOwner(ctx, tx, team.ID, successor.UserID); err != nil {
+			return err
+		}
+		if _, err := s.repo.TeamMembers.UpdateRole(ctx, tx, team.ID, successor.UserID, enums.OWNER); err != nil {
+			return err
+		}
+		if _, err := s.repo.TeamMembers.Delete(ctx, tx, team.ID, team.OwnerID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.repo, tx, models.AuditLog{
+			Action:     models.AUDIT_TEAM_OWNER_CHANGED,
+			TargetType: models.AUDIT_TARGET_TEAM,
+			TargetID:   auditID(team.ID),
+			TeamID:     &team.ID,
+			UserID:     &successor.UserID,
+			Before:     audit.Snapshot(map[string]uint64{"owner_id": team.OwnerID}),
+			After:      audit.Snapshot(map[string]uint64{"owner_id": successor.UserID}),
+		})
+	}
+
+	if _, err := s.repo.Teams.Delete(ctx, tx, team.ID); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, s.repo, tx, teamDeletedAudit(nil, team))
+}
+
+func (s *DeletionServices) retry(ctx context.Context, deletion models.AccountDeletion, execErr error) error {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.repo.AccountDeletions.MarkRetry(ctx, tx, deletion.ID, execErr.Error(), time.Now().Add(webhookBackoff(deletion.Attempts))); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Administradores têm preferência; entre membros do mesmo nível, o mais antigo
+func teamSuccessor(members []models.TeamMember, ownerID uint64) (models.TeamMember, bool) {
+
+	var successor models.TeamMember
+	found := false
+
+	for _, member := range members {
+		if member.UserID == ownerID {
+			continue
+		}
+
+		switch {
+		case !found:
+		case member.Role == enums.ADMIN && successor.Role != enums.ADMIN:
+		case (member.Role == enums.ADMIN) == (successor.Role == enums.ADMIN) && member.CreatedAt.Before(successor.CreatedAt):
+		default:
+			continue
+		}
+
+		successor = member
+		found = true
+	}
+
+	return successor, found
+}
+
+// Propósito da chave dos hashes de identidade das contas excluídas
+const deletionIdentityKeyPurpose = "deletion-identity"
+
+// Hash da identidade do Google, para provar a exclusão sem guardar o dado pessoal
+func (s *DeletionServices) identityHash(googleSub string) string {
+	mac := hmac.New(sha256.New, s.cfg.Auth.DerivedKey(deletionIdentityKeyPurpose))
+	mac.Write([]byte(googleSub))
+	return hex.EncodeToString(mac.Sum(nil))
+}
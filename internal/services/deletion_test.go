@@ -0,0 +1,42 @@
+package services
+
+import (
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"slices"
+	"testing"
+)
+
+func TestSubscriptionsToCancel(t *testing.T) {
+	transferredTeam, dissolvedTeam := uint64(10), uint64(20)
+
+	subscriptions := []models.Subscription{
+		{SubscriptionID: "personal", Status: subscription.ACTIVE},
+		{SubscriptionID: "personal_canceled", Status: subscription.CANCELED},
+		{SubscriptionID: "transferred", TeamID: &transferredTeam, Status: subscription.ACTIVE},
+		{SubscriptionID: "dissolved", TeamID: &dissolvedTeam, Status: subscription.PAST_DUE},
+		{SubscriptionID: "dissolved_expired", TeamID: &dissolvedTeam, Status: subscription.INCOMPLETE_EXPIRED},
+	}
+
+	cases := []struct {
+		name      string
+		dissolved map[uint64]bool
+		want      []string
+	}{
+		{"transferred team keeps its subscription", map[uint64]bool{dissolvedTeam: true}, []string{"personal", "dissolved"}},
+		{"all teams dissolved", map[uint64]bool{transferredTeam: true, dissolvedTeam: true}, []string{"personal", "transferred", "dissolved"}},
+		{"no team dissolved", map[uint64]bool{}, []string{"personal"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, sub := range subscriptionsToCancel(subscriptions, tc.dissolved) {
+				got = append(got, sub.SubscriptionID)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("subscriptionsToCancel() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Motivos devolvidos quando um recurso é negado
+const (
+	ReasonNoSubscription       = "no_subscription"
+	ReasonFeatureNotInPlan     = "feature_not_in_plan"
+	ReasonSubscriptionInactive = "subscription_inactive"
+)
+
+type EntitlementServices struct {
+	repo  repository.Repository
+	db    *pgxpool.Pool
+	cfg   config.Config
+	cache *entitlementCache
+}
+
+// Cache curto dos direitos por usuário, limpo quando chegam webhooks de cobrança
+type entitlementCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[uint64]cachedEntitlements
+}
+
+type cachedEntitlements struct {
+	value     models.UserEntitlements
+	expiresAt time.Time
+}
+
+func newEntitlementCache(ttl time.Duration) *entitlementCache {
+	return &entitlementCache{ttl: ttl, entries: make(map[uint64]cachedEntitlements)}
+}
+
+func (c *entitlementCache) get(userID uint64) (models.UserEntitlements, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, userID)
+		return models.UserEntitlements{}, false
+	}
+
+	return entry.value, true
+}
+
+func (c *entitlementCache) set(userID uint64, value models.UserEntitlements) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[userID] = cachedEntitlements{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *entitlementCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
+
+// Resolve o plano e o estado de acesso a partir da assinatura atual do usuário
+func (s *EntitlementServices) GetByUserID(ctx context.Context, userID uint64) (models.Entitlement, error) {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.GetByUserID")
+	defer span.End()
+
+	sub, err := s.repo.Subscriptions.GetCurrentByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		// Sem assinatura o usuário fica no plano gratuito
+		return s.fromSubscription(ctx, nil), nil
+	}
+	if err != nil {
+		return models.Entitlement{}, err
+	}
+
+	return s.fromSubscription(ctx, &sub), nil
+}
+
+// Resolve os direitos da equipe pela sua própria assinatura ou, na falta dela, pela do dono
+func (s *EntitlementServices) GetByTeamID(ctx context.Context, teamID uint64) (models.Entitlement, error) {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.GetByTeamID")
+	defer span.End()
+
+	sub, err := s.repo.Subscriptions.GetCurrentByTeamID(ctx, teamID)
+	if err == nil {
+		return s.fromSubscription(ctx, &sub), nil
+	}
+	if !errors.Is(err, repository.ErrSubscriptionNotFound) {
+		return models.Entitlement{}, err
+	}
+
+	team, err := s.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return models.Entitlement{}, err
+	}
+
+	return s.GetByUserID(ctx, team.OwnerID)
+}
+
+// Junta os direitos da assinatura do usuário com os de todas as equipes de que ele participa
+func (s *EntitlementServices) GetEffective(ctx context.Context, userID uint64) (models.UserEntitlements, error) {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.GetEffective")
+	defer span.End()
+
+	if cached, ok := s.cache.get(userID); ok {
+		return cached, nil
+	}
+
+	personal, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return models.UserEntitlements{}, err
+	}
+
+	memberships, err := s.repo.TeamMembers.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return models.UserEntitlements{}, err
+	}
+
+	effective := models.UserEntitlements{
+		UserID:   userID,
+		Personal: personal,
+		Teams:    []models.TeamEntitlement{},
+		Features: []string{},
+	}
+
+	addFeatures(&effective, personal)
+
+	for _, membership := range memberships {
+		teamEntitlement, err := s.GetByTeamID(ctx, membership.TeamID)
+		if err != nil {
+			return models.UserEntitlements{}, err
+		}
+
+		effective.Teams = append(effective.Teams, models.TeamEntitlement{
+			TeamID:      membership.TeamID,
+			TeamName:    membership.TeamName,
+			Entitlement: teamEntitlement,
+		})
+
+		addFeatures(&effective, teamEntitlement)
+	}
+
+	s.cache.set(userID, effective)
+
+	return effective, nil
+}
+
+// Verifica se alguma assinatura do usuário (própria ou de equipe) libera o recurso
+func (s *EntitlementServices) CheckFeature(ctx context.Context, userID uint64, feature string) (models.FeatureCheck, error) {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.CheckFeature")
+	defer span.End()
+
+	effective, err := s.GetEffective(ctx, userID)
+	if err != nil {
+		return models.FeatureCheck{}, err
+	}
+
+	sources := []models.Entitlement{effective.Personal}
+	for _, team := range effective.Teams {
+		sources = append(sources, team.Entitlement)
+	}
+
+	return featureCheck(feature, sources...), nil
+}
+
+// Verifica se os direitos da própria equipe liberam o recurso, sem considerar os outros planos de quem pede
+func (s *EntitlementServices) CheckTeamFeature(ctx context.Context, teamID uint64, feature string) (models.FeatureCheck, error) {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.CheckTeamFeature")
+	defer span.End()
+
+	entitlement, err := s.GetByTeamID(ctx, teamID)
+	if err != nil {
+		return models.FeatureCheck{}, err
+	}
+
+	return featureCheck(feature, entitlement), nil
+}
+
+// Libera o recurso se alguma fonte ativa o incluir; senão explica o motivo. O plano informado é o da
+// primeira fonte, ou o da assinatura bloqueada que teria o recurso
+func featureCheck(feature string, sources ...models.Entitlement) models.FeatureCheck {
+
+	check := models.FeatureCheck{
+		Feature: feature,
+		Plan:    sources[0].Plan.Name,
+	}
+
+	for _, source := range sources {
+		if source.Access != enums.READ_ONLY && slices.Contains(source.Plan.Features, feature) {
+			check.Allowed = true
+			return check
+		}
+	}
+
+	// O recurso existe em algum plano, mas a assinatura está bloqueada
+	hasSubscription := false
+	for _, source := range sources {
+		if source.SubscriptionID != "" {
+			hasSubscription = true
+		}
+		if slices.Contains(source.Plan.Features, feature) {
+			check.Reason = ReasonSubscriptionInactive
+			check.Plan = source.Plan.Name
+			return check
+		}
+	}
+
+	check.Reason = ReasonFeatureNotInPlan
+	if !hasSubscription {
+		check.Reason = ReasonNoSubscription
+	}
+
+	return check
+}
+
+// Descarta os direitos em cache após mudanças de assinatura
+func (s *EntitlementServices) Invalidate() {
+	s.cache.clear()
+}
+
+func addFeatures(effective *models.UserEntitlements, entitlement models.Entitlement) {
+	if entitlement.Access == enums.READ_ONLY {
+		return
+	}
+
+	for _, feature := range entitlement.Plan.Features {
+		if !slices.Contains(effective.Features, feature) {
+			effective.Features = append(effective.Features, feature)
+		}
+	}
+}
+
+func (s *EntitlementServices) fromSubscription(ctx context.Context, sub *models.Subscription) models.Entitlement {
+
+	entitlement := models.Entitlement{
+		Plan:   models.FreePlan,
+		Access: enums.FULL_ACCESS,
+	}
+
+	if sub == nil {
+		return entitlement
+	}
+
+	entitlement.SubscriptionID = sub.SubscriptionID
+	entitlement.Status = sub.Status
+
+	// Teste do HareID encerrado volta ao plano gratuito, pois nunca houve cobrança
+	if sub.Provider == payments.LOCAL && sub.Status != subscription.TRIALING {
+		return entitlement
+	}
+
+	switch sub.Status {
+	case subscription.ACTIVE, subscription.TRIALING:
+		entitlement.Plan = s.planFor(ctx, sub.PriceID)
+
+	case subscription.PAST_DUE, subscription.UNPAID:
+		entitlement.Plan = s.planFor(ctx, sub.PriceID)
+
+		graceEndsAt := graceStart(*sub).AddDate(0, 0, s.cfg.Billing.GraceDays)
+		entitlement.GraceEndsAt = &graceEndsAt
+
+		entitlement.Access = enums.GRACE_PERIOD
+		if time.Now().After(graceEndsAt) {
+			s.lockOut(&entitlement)
+		}
+
+	case subscription.CANCELED, subscription.INACTIVE, subscription.INCOMPLETE_EXPIRED:
+		s.lockOut(&entitlement)
+	}
+
+	return entitlement
+}
+
+// Aplica DUNNING_ACTION: somente leitura ou rebaixamento para o plano gratuito
+func (s *EntitlementServices) lockOut(entitlement *models.Entitlement) {
+	if s.cfg.Billing.DunningAction == "downgrade" {
+		entitlement.Plan = models.FreePlan
+		entitlement.Access = enums.FULL_ACCESS
+		return
+	}
+
+	entitlement.Access = enums.READ_ONLY
+}
+
+// Confere o limite de equipes dentro da transação que cria a equipe. A linha do dono fica travada até
+// o commit, então a contagem já enxerga as equipes criadas pelas transações que esperavam antes
+func (s *EntitlementServices) CanCreateTeam(ctx context.Context, tx pgx.Tx, userID uint64) error {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.CanCreateTeam")
+	defer span.End()
+
+	if err := s.repo.Users.LockForUpdate(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	entitlement, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if entitlement.Access == enums.READ_ONLY {
+		return ErrTeamReadOnly
+	}
+
+	ownedTeams, err := s.repo.Teams.CountByOwnerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if ownedTeams >= entitlement.Plan.MaxTeams {
+		return fmt.Errorf("%w: the %s plan allows %d team(s)", ErrPlanLimitReached, entitlement.Plan.Name, entitlement.Plan.MaxTeams)
+	}
+
+	return nil
+}
+
+// Confere o limite de assentos dentro da transação que insere o membro, com a equipe travada até o commit
+func (s *EntitlementServices) CanAddMember(ctx context.Context, tx pgx.Tx, teamID uint64) error {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.CanAddMember")
+	defer span.End()
+
+	if err := s.repo.Teams.LockForUpdate(ctx, tx, teamID); err != nil {
+		return err
+	}
+
+	entitlement, err := s.GetByTeamID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	if entitlement.Access == enums.READ_ONLY {
+		return ErrTeamReadOnly
+	}
+
+	seats, err := s.repo.TeamMembers.CountByTeamID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	if seats >= entitlement.Plan.MaxSeats {
+		return fmt.Errorf("%w: the %s plan allows %d seat(s)", ErrPlanLimitReached, entitlement.Plan.Name, entitlement.Plan.MaxSeats)
+	}
+
+	return nil
+}
+
+func (s *EntitlementServices) CanModifyTeam(ctx context.Context, teamID uint64) error {
+	ctx, span := tracing.Start(ctx, "EntitlementServices.CanModifyTeam")
+	defer span.End()
+
+	entitlement, err := s.GetByTeamID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	if entitlement.Access == enums.READ_ONLY {
+		return ErrTeamReadOnly
+	}
+
+	return nil
+}
+
+func (s *EntitlementServices) planFor(ctx context.Context, priceID string) models.Plan {
+
+	plan, err := s.repo.Plans.GetByPriceID(ctx, priceID)
+	if err != nil {
+		slog.WarnContext(ctx, "no plan mapped to price, falling back to free plan", "price_id", priceID, "error", err)
+		return models.FreePlan
+	}
+
+	return plan
+}
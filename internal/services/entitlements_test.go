@@ -0,0 +1,140 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+// Catálogo com um único plano pago
+type fakePlans struct {
+	plan models.Plan
+}
+
+func (f fakePlans) GetAll(ctx context.Context) ([]models.Plan, error) {
+	return []models.Plan{f.plan}, nil
+}
+
+func (f fakePlans) GetByPriceID(ctx context.Context, priceID string) (models.Plan, error) {
+	return f.plan, nil
+}
+
+func TestFromSubscriptionGrace(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+
+	cases := []struct {
+		name         string
+		sub          models.Subscription
+		wantAccess   enums.TeamAccess
+		wantGraceEnd time.Time
+	}{
+		{
+			// A renovação falha já avançou o período; a carência conta da inadimplência
+			name: "failed renewal already moved the period end forward",
+			sub: models.Subscription{
+				Status:           subscription.PAST_DUE,
+				CurrentPeriodEnd: now.AddDate(0, 1, 0),
+				PastDueSince:     daysAgo(10),
+			},
+			wantAccess:   enums.READ_ONLY,
+			wantGraceEnd: daysAgo(10).AddDate(0, 0, 7),
+		},
+		{
+			name: "recently past due keeps grace access",
+			sub: models.Subscription{
+				Status:           subscription.UNPAID,
+				CurrentPeriodEnd: now.AddDate(0, 1, 0),
+				PastDueSince:     daysAgo(2),
+			},
+			wantAccess:   enums.GRACE_PERIOD,
+			wantGraceEnd: daysAgo(2).AddDate(0, 0, 7),
+		},
+		{
+			name: "legacy row without past_due_since counts from the period end",
+			sub: models.Subscription{
+				Status:           subscription.PAST_DUE,
+				CurrentPeriodEnd: *daysAgo(8),
+			},
+			wantAccess:   enums.READ_ONLY,
+			wantGraceEnd: daysAgo(8).AddDate(0, 0, 7),
+		},
+	}
+
+	plan := models.Plan{PriceID: "price_pro"}
+	s := &EntitlementServices{
+		repo: repository.Repository{Plans: fakePlans{plan: plan}},
+		cfg:  config.Config{Billing: config.Billing{GraceDays: 7, DunningAction: "lockout"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.sub.Provider = payments.STRIPE
+			tc.sub.PriceID = plan.PriceID
+
+			got := s.fromSubscription(context.Background(), &tc.sub)
+			if got.Access != tc.wantAccess {
+				t.Errorf("access = %v, want %v", got.Access, tc.wantAccess)
+			}
+			if got.GraceEndsAt == nil || got.GraceEndsAt.Sub(tc.wantGraceEnd).Abs() > time.Second {
+				t.Errorf("grace ends at = %v, want %v", got.GraceEndsAt, tc.wantGraceEnd)
+			}
+		})
+	}
+}
+
+func TestFeatureCheck(t *testing.T) {
+	plan := models.Plan{Name: "Pro", PriceID: "price_pro", Features: []string{models.FEATURE_AUDIT_LOGS}}
+	s := &EntitlementServices{
+		repo: repository.Repository{Plans: fakePlans{plan: plan}},
+		cfg:  config.Config{Billing: config.Billing{GraceDays: 7, DunningAction: "lockout"}},
+	}
+
+	// Inadimplente há 30 dias, já fora da carência
+	pastDueSince := time.Now().AddDate(0, 0, -30)
+	entitlement := func(status subscription.Subscription) models.Entitlement {
+		return s.fromSubscription(context.Background(), &models.Subscription{
+			SubscriptionID:   "sub_" + plan.PriceID,
+			Provider:         payments.STRIPE,
+			PriceID:          plan.PriceID,
+			Status:           status,
+			CurrentPeriodEnd: time.Now().AddDate(0, 1, 0),
+			PastDueSince:     &pastDueSince,
+		})
+	}
+	free := s.fromSubscription(context.Background(), nil)
+
+	cases := []struct {
+		name        string
+		sources     []models.Entitlement
+		wantAllowed bool
+		wantReason  string
+	}{
+		{"active plan with the feature", []models.Entitlement{entitlement(subscription.ACTIVE)}, true, ""},
+		{"plan with the feature out of grace", []models.Entitlement{entitlement(subscription.UNPAID)}, false, ReasonSubscriptionInactive},
+		{"canceled plan falls back to free", []models.Entitlement{entitlement(subscription.CANCELED)}, false, ReasonFeatureNotInPlan},
+		{"free plan", []models.Entitlement{free}, false, ReasonNoSubscription},
+		// CheckFeature junta o plano pessoal e o das equipes; CheckTeamFeature olha só a equipe da rota
+		{"user check: paid personal plan with an unpaid team", []models.Entitlement{entitlement(subscription.ACTIVE), entitlement(subscription.UNPAID)}, true, ""},
+		{"team check: unpaid team of a user with a paid personal plan", []models.Entitlement{entitlement(subscription.UNPAID)}, false, ReasonSubscriptionInactive},
+		{"team check: team without subscription", []models.Entitlement{free}, false, ReasonNoSubscription},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := featureCheck(models.FEATURE_AUDIT_LOGS, tc.sources...)
+			if got.Allowed != tc.wantAllowed || got.Reason != tc.wantReason {
+				t.Errorf("featureCheck() = %+v, want allowed %v reason %q", got, tc.wantAllowed, tc.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/repository"
+)
+
+var (
+	// O plano contratado não comporta a operação
+	ErrPlanLimitReached = apperrors.PaymentRequired("plan_limit_reached", "plan limit reached")
+	// A assinatura do dono está cancelada ou fora do período de carência
+	ErrTeamReadOnly = apperrors.Forbidden("team_read_only", "team is read-only until the subscription is regularized")
+	// Apenas o titular pode alterar a assinatura
+	ErrNotSubscriptionOwner = apperrors.Forbidden("not_subscription_owner", "only the subscription owner can change it")
+	// O usuário ainda não tem cadastro no provedor de pagamento
+	ErrNoBillingAccount = apperrors.Conflict("no_billing_account", "user has no billing account")
+	// O price_id informado não corresponde a nenhum plano
+	ErrUnknownPlan = apperrors.Invalid("unknown_plan", "unknown plan")
+	// A rota é restrita aos membros da equipe
+	ErrNotTeamMember = apperrors.Forbidden("not_team_member", "only team members can access this resource")
+	// A rota é restrita ao dono e aos administradores da equipe
+	ErrNotTeamAdmin = apperrors.Forbidden("not_team_admin", "only the team owner and administrators can access this resource")
+	// A operação é restrita ao dono da equipe
+	ErrNotTeamOwner = apperrors.Forbidden("not_team_owner", "only the team owner can perform this operation")
+	// Dados pessoais só podem ser acessados pelo próprio titular
+	ErrNotDataOwner = apperrors.Forbidden("not_data_owner", "users can only access their own data")
+	// Link de download com assinatura inválida ou vencido
+	ErrInvalidExportLink = apperrors.Forbidden("invalid_export_link", "invalid or expired download link")
+	// A sessão do token foi encerrada (logout, troca de dispositivo ou exclusão da conta)
+	ErrSessionRevoked = apperrors.Unauthenticated("session_revoked", "session revoked")
+	// Google sub sem cadastro no login
+	ErrInvalidCredentials = apperrors.Unauthenticated("invalid_credentials", "invalid credentials")
+	// Pedido de entrada que já foi aceito ou recusado
+	ErrJoinRequestDecided = repository.ErrJoinRequestDecided
+	// Evento de webhook que ainda está na fila (pendente ou em processamento)
+	ErrWebhookEventQueued = apperrors.Conflict("webhook_event_queued", "event is already queued for processing")
+	// Administrador tentando revogar o próprio acesso
+	ErrOwnAdminRevoke = apperrors.Conflict("own_admin_revoke", "administrators cannot revoke their own access")
+)
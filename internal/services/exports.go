@@ -0,0 +1,467 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/enums"
+	"HareID/internal/listquery"
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tempo máximo de uma exportação em andamento antes de voltar para a fila
+const exportLease = 15 * time.Minute
+
+type ExportServices struct {
+	repo repository.Repository
+	db   *pgxpool.Pool
+	cfg  config.Config
+}
+
+// Parte do arquivo exportado, gravada como <name>.json (e csv/<name>.csv)
+type exportSection struct {
+	name string
+	data any
+}
+
+// Coloca o pedido na fila. Se já houver uma exportação em andamento ela é reaproveitada
+func (s *ExportServices) Request(ctx context.Context, requestUserID, userID uint64, includeCSV bool) (models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "ExportServices.Request")
+	defer span.End()
+
+	if requestUserID != userID {
+		return models.DataExport{}, ErrNotDataOwner
+	}
+
+	if active, err := s.repo.DataExports.GetActiveByUserID(ctx, userID); err == nil {
+		return active, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	export, err := s.repo.DataExports.Create(ctx, tx, models.DataExport{UserID: userID, IncludeCSV: includeCSV})
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+// Consulta a exportação, com o link de download assinado quando o arquivo estiver pronto
+func (s *ExportServices) GetByID(ctx context.Context, requestUserID, userID, exportID uint64) (models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "ExportServices.GetByID")
+	defer span.End()
+
+	if requestUserID != userID {
+		return models.DataExport{}, ErrNotDataOwner
+	}
+
+	export, err := s.repo.DataExports.GetByID(ctx, exportID)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if export.UserID != userID {
+		return models.DataExport{}, repository.ErrDataExportNotFound
+	}
+
+	if export.Status == enums.EXPORT_READY && export.ExpiresAt != nil {
+		export.DownloadURL = s.downloadURL(export.ID, export.ExpiresAt.Unix())
+	}
+
+	return export, nil
+}
+
+// Valida o link assinado e devolve a exportação com o caminho do arquivo
+func (s *ExportServices) Open(ctx context.Context, exportID uint64, expires int64, signature string) (models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "ExportServices.Open")
+	defer span.End()
+
+	expected := s.sign(exportID, expires)
+	if !hmac.Equal([]byte(signature), []byte(expected)) || time.Now().Unix() > expires {
+		return models.DataExport{}, ErrInvalidExportLink
+	}
+
+	export, err := s.repo.DataExports.GetByID(ctx, exportID)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	if export.Status != enums.EXPORT_READY || export.FilePath == "" {
+		return models.DataExport{}, ErrInvalidExportLink
+	}
+
+	return export, nil
+}
+
+// Gera os arquivos das exportações na fila e avisa o titular. Retorna quantas foram reservadas
+func (s *ExportServices) ProcessPending(ctx context.Context, batchSize int) (int, error) {
+	ctx, span := tracing.Start(ctx, "ExportServices.ProcessPending")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	exports, err := s.repo.DataExports.ClaimPending(ctx, tx, batchSize, exportLease)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	for _, export := range exports {
+		filePath, buildErr := s.build(ctx, export)
+		if err := s.finish(ctx, export, filePath, buildErr); err != nil {
+			return len(exports), err
+		}
+	}
+
+	return len(exports), nil
+}
+
+// Apaga os arquivos vencidos. Retorna quantos foram removidos
+func (s *ExportServices) PurgeExpired(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "ExportServices.PurgeExpired")
+	defer span.End()
+
+	exports, err := s.repo.DataExports.GetExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, export := range exports {
+		if err := removeExportFile(export.FilePath); err != nil {
+			return 0, err
+		}
+
+		tx, err := s.db.Begin(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := s.repo.DataExports.MarkExpired(ctx, tx, export.ID); err != nil {
+			tx.Rollback(ctx)
+			return 0, err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(exports), nil
+}
+
+func removeExportFile(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *ExportServices) finish(ctx context.Context, export models.DataExport, filePath string, buildErr error) error {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if buildErr != nil {
+		slog.ErrorContext(ctx, "data export failed", "export_id", export.ID, "error", buildErr)
+		if err := s.repo.DataExports.MarkFailed(ctx, tx, export.ID, buildErr.Error()); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+
+	if err := s.repo.DataExports.MarkReady(ctx, tx, export.ID, filePath, time.Now().Add(s.cfg.Privacy.ExportTTL)); err != nil {
+		// A conta foi excluída durante a geração: o arquivo não pode ficar para trás
+		if errors.Is(err, repository.ErrDataExportNotFound) {
+			return removeExportFile(filePath)
+		}
+		return err
+	}
+
+	if _, err := s.repo.Notifications.Create(ctx, tx, models.Notification{
+		ReceiverID:  export.UserID,
+		Type:        enums.DATA_EXPORT_READY,
+		ReferenceID: export.ID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Monta o zip com todos os dados do titular e devolve o caminho gravado
+func (s *ExportServices) build(ctx context.Context, export models.DataExport) (string, error) {
+
+	sections, err := s.collect(ctx, export.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	files := make([]string, 0, len(sections)*2)
+
+	for _, section := range sections {
+		content, err := json.MarshalIndent(section.data, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := writeZipFile(archive, section.name+".json", content); err != nil {
+			return "", err
+		}
+		files = append(files, section.name+".json")
+
+		if !export.IncludeCSV {
+			continue
+		}
+
+		content, err = exportCSV(section.data)
+		if err != nil {
+			return "", err
+		}
+		if err := writeZipFile(archive, "csv/"+section.name+".csv", content); err != nil {
+			return "", err
+		}
+		files = append(files, "csv/"+section.name+".csv")
+	}
+
+	manifest, err := json.MarshalIndent(map[string]any{
+		"user_id":      export.UserID,
+		"export_id":    export.ID,
+		"generated_at": time.Now().UTC(),
+		"files":        files,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeZipFile(archive, "manifest.json", manifest); err != nil {
+		return "", err
+	}
+
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.cfg.Privacy.ExportDir, 0o700); err != nil {
+		return "", err
+	}
+
+	// Sufixo aleatório para o nome do arquivo não ser previsível
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(s.cfg.Privacy.ExportDir, fmt.Sprintf("export_%d_%s.zip", export.ID, hex.EncodeToString(suffix)))
+
+	if err := os.WriteFile(filePath, buffer.Bytes(), 0o600); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+// Reúne os dados ligados ao usuário em todos os repositórios
+func (s *ExportServices) collect(ctx context.Context, userID uint64) ([]exportSection, error) {
+
+	user, err := s.repo.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.repo.TeamMembers.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]models.Team, 0, len(memberships))
+	for _, membership := range memberships {
+		team, err := s.repo.Teams.GetByID(ctx, membership.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	joinRequests, err := s.repo.JoinRequests.GetAllBySenderID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := listquery.All(ctx, func(ctx context.Context, params listquery.Params) (listquery.Page[models.Notification], error) {
+		return s.repo.Notifications.GetAll(ctx, userID, params)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.repo.Subscriptions.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := s.repo.Invoices.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.repo.BillingHistory.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := s.repo.Consents.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []exportSection{
+		{"user", user},
+		{"consents", orEmpty(consents)},
+		{"team_memberships", orEmpty(memberships)},
+		{"teams", teams},
+		{"join_requests", orEmpty(joinRequests)},
+		{"notifications", orEmpty(notifications)},
+		{"subscriptions", orEmpty(subscriptions)},
+		{"invoices", orEmpty(invoices)},
+		{"billing_history", orEmpty(history)},
+	}, nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	return err
+}
+
+// Converte a seção para CSV usando os campos JSON como colunas. Campos aninhados ficam em JSON
+func exportCSV(data any) ([]byte, error) {
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]any
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	if bytes.HasPrefix(encoded, []byte("[")) {
+		err = decoder.Decode(&records)
+	} else {
+		var record map[string]any
+		err = decoder.Decode(&record)
+		records = append(records, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	for _, record := range records {
+		for column := range record {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	slices.Sort(columns)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			switch value := record[column].(type) {
+			case nil:
+			case string:
+				row[i] = value
+			case json.Number, bool:
+				row[i] = fmt.Sprint(value)
+			default:
+				nested, err := json.Marshal(value)
+				if err != nil {
+					return nil, err
+				}
+				row[i] = string(nested)
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+
+	return buffer.Bytes(), writer.Error()
+}
+
+func orEmpty[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// Propósito da chave que assina os links de download
+const exportLinkKeyPurpose = "export-link"
+
+func (s *ExportServices) sign(exportID uint64, expires int64) string {
+	mac := hmac.New(sha256.New, s.cfg.Auth.DerivedKey(exportLinkKeyPurpose))
+	mac.Write([]byte(strconv.FormatUint(exportID, 10) + "." + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// O link vale até o arquivo expirar
+func (s *ExportServices) downloadURL(exportID uint64, expires int64) string {
+	return fmt.Sprintf("%s/exports/%d/download?expires=%d&signature=%s",
+		s.cfg.Server.PublicURL, exportID, expires, s.sign(exportID, expires))
+}
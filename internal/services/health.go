@@ -0,0 +1,85 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/db/migrations"
+	"HareID/internal/models"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tempo máximo de cada verificação do /readyz
+const readinessTimeout = 2 * time.Second
+
+type HealthServices struct {
+	db  *pgxpool.Pool
+	cfg config.Config
+
+	// Ligado no início do desligamento, para o orquestrador parar de mandar tráfego antes do servidor fechar
+	draining atomic.Bool
+}
+
+func (s *HealthServices) StartDraining() {
+	s.draining.Store(true)
+}
+
+// Verifica se a instância pode receber tráfego: não está desligando, o banco responde,
+// o esquema está na versão do binário e o Stripe está configurado
+func (s *HealthServices) Readiness(ctx context.Context) models.Readiness {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks := []models.ReadinessCheck{
+		s.checkDraining(),
+		s.checkDatabase(ctx),
+		s.checkMigrations(ctx),
+		s.checkStripe(),
+	}
+
+	readiness := models.Readiness{Ready: true, Checks: checks}
+	for _, check := range checks {
+		if !check.Healthy {
+			readiness.Ready = false
+		}
+	}
+
+	return readiness
+}
+
+func (s *HealthServices) checkDraining() models.ReadinessCheck {
+	if s.draining.Load() {
+		return models.ReadinessCheck{Name: "shutdown", Healthy: false, Detail: "shutting down"}
+	}
+	return models.ReadinessCheck{Name: "shutdown", Healthy: true}
+}
+
+func (s *HealthServices) checkDatabase(ctx context.Context) models.ReadinessCheck {
+	if err := s.db.Ping(ctx); err != nil {
+		return models.ReadinessCheck{Name: "database", Healthy: false, Detail: err.Error()}
+	}
+	return models.ReadinessCheck{Name: "database", Healthy: true}
+}
+
+func (s *HealthServices) checkMigrations(ctx context.Context) models.ReadinessCheck {
+	current, latest, err := migrations.Version(ctx, s.db)
+	if err != nil {
+		return models.ReadinessCheck{Name: "migrations", Healthy: false, Detail: err.Error()}
+	}
+
+	if current < latest {
+		return models.ReadinessCheck{Name: "migrations", Healthy: false, Detail: fmt.Sprintf("database at version %d, expected %d", current, latest)}
+	}
+
+	return models.ReadinessCheck{Name: "migrations", Healthy: true, Detail: fmt.Sprintf("version %d", current)}
+}
+
+func (s *HealthServices) checkStripe() models.ReadinessCheck {
+	if s.cfg.Stripe.SecretKey == "" || s.cfg.Stripe.WebhookSecret == "" {
+		return models.ReadinessCheck{Name: "stripe", Healthy: false, Detail: "STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required"}
+	}
+	return models.ReadinessCheck{Name: "stripe", Healthy: true}
+}
@@ -2,26 +2,30 @@ package services
 
 import (
 	"HareID/internal/enums"
+	"HareID/internal/listquery"
+	"HareID/internal/metrics"
 	"HareID/internal/models"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"HareID/internal/validators"
 	"context"
-	"errors"
-	"log"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type JoinRequestServices struct {
-	repo repository.Repository
-	val  validators.Validations
-	db   *pgxpool.Pool
+	repo    repository.Repository
+	val     validators.Validations
+	db      *pgxpool.Pool
+	members *TeamMembersServices
 }
 
 // Criar um novo pedido de entrada
 func (s *JoinRequestServices) Create(ctx context.Context, requestUserID, teamID uint64) (models.JoinRequest, models.Notification, error) {
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.Create")
+	defer span.End()
 
-	tx, err := s.db.Begin(ctx)
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return models.JoinRequest{}, models.Notification{}, err
 	}
@@ -29,7 +33,6 @@ func (s *JoinRequestServices) Create(ctx context.Context, requestUserID, teamID
 
 	team, err := s.repo.Teams.GetByID(ctx, teamID)
 	if err != nil {
-		log.Println(err)
 		return models.JoinRequest{}, models.Notification{}, err
 	}
 
@@ -42,38 +45,45 @@ func (s *JoinRequestServices) Create(ctx context.Context, requestUserID, teamID
 
 	joinRequest, err = s.repo.JoinRequests.Create(ctx, tx, joinRequest)
 	if err != nil {
-		log.Println(err)
 		return models.JoinRequest{}, models.Notification{}, err
 	}
-	
+
 	createdNotification, err := s.repo.Notifications.CreateByJoinRequest(ctx, tx, joinRequest)
 	if err != nil {
 		return models.JoinRequest{}, models.Notification{}, err
 	}
 
+	if err := recordAudit(ctx, s.repo, tx, joinRequestAudit(requestUserID, models.AUDIT_JOIN_REQUESTED, joinRequest)); err != nil {
+		return models.JoinRequest{}, models.Notification{}, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return models.JoinRequest{}, models.Notification{}, err
 	}
 
+	metrics.JoinRequestTransition("none", joinRequestStatusName(enums.PENDING))
+
 	return joinRequest, createdNotification, nil
 }
 
 // Buscar todos os pedidos de um time
-func (s *JoinRequestServices) GetAll(ctx context.Context, requestUserID, teamID uint64) ([]models.JoinRequest, error) {
+func (s *JoinRequestServices) GetAll(ctx context.Context, requestUserID, teamID uint64, params listquery.Params) (listquery.Page[models.JoinRequest], error) {
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.GetAll")
+	defer span.End()
 
-	requests, err := s.repo.JoinRequests.GetAll(ctx, teamID)
+	requests, err := s.repo.JoinRequests.GetAll(ctx, teamID, params)
 	if err != nil {
-		return nil, err
+		return listquery.Page[models.JoinRequest]{}, err
 	}
 
-	// valida permissão para cada join request
-	for _, r := range requests {
+	// valida permissão para cada join request da página
+	for _, r := range requests.Items {
 		ok, err := s.val.JoinRequest.CanSee(ctx, requestUserID, r.ID, teamID)
 		if err != nil {
-			return nil, err
+			return listquery.Page[models.JoinRequest]{}, err
 		}
 		if !ok {
-			return nil, errors.New("you dont have permission to see the team join requests")
+			return listquery.Page[models.JoinRequest]{}, ErrNotTeamAdmin
 		}
 	}
 
@@ -82,6 +92,8 @@ func (s *JoinRequestServices) GetAll(ctx context.Context, requestUserID, teamID
 
 // Buscar um pedido específico pelo ID
 func (s *JoinRequestServices) GetByID(ctx context.Context, requestUserID, teamID, requestID uint64) (models.JoinRequest, error) {
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.GetByID")
+	defer span.End()
 
 	ok, err := s.val.JoinRequest.CanSee(ctx, requestUserID, requestID, teamID)
 	if err != nil {
@@ -89,7 +101,7 @@ func (s *JoinRequestServices) GetByID(ctx context.Context, requestUserID, teamID
 	}
 
 	if !ok {
-		return models.JoinRequest{}, errors.New("you dont have permission to see the team join requests")
+		return models.JoinRequest{}, ErrNotTeamAdmin
 	}
 
 	request, err := s.repo.JoinRequests.GetByID(ctx, requestID, teamID)
@@ -102,7 +114,10 @@ func (s *JoinRequestServices) GetByID(ctx context.Context, requestUserID, teamID
 
 // Deletar um pedido
 func (s *JoinRequestServices) Delete(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error) {
-	tx, err := s.db.Begin(ctx)
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.Delete")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return 0, err
 	}
@@ -114,7 +129,17 @@ func (s *JoinRequestServices) Delete(ctx context.Context, requestUserID, teamID,
 	}
 
 	if !ok {
-		return 0, errors.New("you dont have permission to delete the team join requests")
+		return 0, ErrNotTeamAdmin
+	}
+
+	// Mesma trava do aceite: a recusa não corre com um aceite do mesmo pedido
+	if err := s.repo.Teams.LockForUpdate(ctx, tx, teamID); err != nil {
+		return 0, err
+	}
+
+	request, err := s.repo.JoinRequests.GetByID(ctx, requestID, teamID)
+	if err != nil {
+		return 0, err
 	}
 
 	affectedRows, err := s.repo.JoinRequests.Delete(ctx, tx, requestID, teamID)
@@ -122,54 +147,85 @@ func (s *JoinRequestServices) Delete(ctx context.Context, requestUserID, teamID,
 		return 0, err
 	}
 
+	if err := recordAudit(ctx, s.repo, tx, joinRequestAudit(requestUserID, models.AUDIT_JOIN_DELETED, request)); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return 0, err
 	}
 
+	metrics.JoinRequestTransition(joinRequestStatusName(request.Status), "deleted")
+
 	return affectedRows, nil
 }
 
-// Aceitar um pedido
-func (s *JoinRequestServices) Accept(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error) {
-	tx, err := s.db.Begin(ctx)
+// Aceitar um pedido. A aprovação e a entrada do membro são gravadas juntas: se o limite de assentos
+// barrar a entrada o pedido continua pendente e pode ser aceito depois
+func (s *JoinRequestServices) Accept(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, models.TeamMember, error) {
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.Accept")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
-		return 0, err
+		return 0, models.TeamMember{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	ok, err := s.val.JoinRequest.CanSee(ctx, requestUserID, requestID, teamID)
 	if err != nil {
-		return 0, err
+		return 0, models.TeamMember{}, err
 	}
 
 	if !ok {
-		return 0, errors.New("you dont have permission to accept the team join requests")
+		return 0, models.TeamMember{}, ErrNotTeamAdmin
+	}
+
+	// Com a equipe travada, dois aceites do mesmo pedido não passam ambos pela checagem de status
+	if err := s.repo.Teams.LockForUpdate(ctx, tx, teamID); err != nil {
+		return 0, models.TeamMember{}, err
 	}
 
 	request, err := s.repo.JoinRequests.GetByID(ctx, requestID, teamID)
 	if err != nil {
-		return 0, err
+		return 0, models.TeamMember{}, err
 	}
 
 	if request.Status != 0 {
-		return 0, errors.New("request already accepted or rejected")
+		return 0, models.TeamMember{}, ErrJoinRequestDecided
 	}
 
 	affectedRows, err := s.repo.JoinRequests.Accept(ctx, tx, requestUserID, teamID, requestID)
 	if err != nil {
-		return 0, err
+		return 0, models.TeamMember{}, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, joinRequestAudit(requestUserID, models.AUDIT_JOIN_ACCEPTED, request)); err != nil {
+		return 0, models.TeamMember{}, err
+	}
+
+	teamMember, err := s.members.add(ctx, tx, enums.MARKETING_MEMBER, teamID, request.SenderID)
+	if err != nil {
+		return 0, models.TeamMember{}, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return 0, err
+		return 0, models.TeamMember{}, err
 	}
 
-	return affectedRows, nil
+	metrics.JoinRequestTransition(joinRequestStatusName(request.Status), joinRequestStatusName(enums.APPROVED))
+
+	s.members.syncSeats(ctx, teamID)
+
+	return affectedRows, teamMember, nil
 }
 
 // Rejeitar um pedido
 func (s *JoinRequestServices) Reject(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error) {
-	tx, err := s.db.Begin(ctx)
+	ctx, span := tracing.Start(ctx, "JoinRequestServices.Reject")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return 0, err
 	}
@@ -181,7 +237,12 @@ func (s *JoinRequestServices) Reject(ctx context.Context, requestUserID, teamID,
 	}
 
 	if !ok {
-		return 0, errors.New("you dont have permission to reject the requests")
+		return 0, ErrNotTeamAdmin
+	}
+
+	// Mesma trava do aceite: a recusa não corre com um aceite do mesmo pedido
+	if err := s.repo.Teams.LockForUpdate(ctx, tx, teamID); err != nil {
+		return 0, err
 	}
 
 	request, err := s.repo.JoinRequests.GetByID(ctx, requestID, teamID)
@@ -190,7 +251,7 @@ func (s *JoinRequestServices) Reject(ctx context.Context, requestUserID, teamID,
 	}
 
 	if request.Status != 0 {
-		return 0, errors.New("request already accepted or rejected")
+		return 0, ErrJoinRequestDecided
 	}
 
 	affectedRows, err := s.repo.JoinRequests.Reject(ctx, tx, requestUserID, teamID, requestID)
@@ -198,9 +259,41 @@ func (s *JoinRequestServices) Reject(ctx context.Context, requestUserID, teamID,
 		return 0, err
 	}
 
+	if err := recordAudit(ctx, s.repo, tx, joinRequestAudit(requestUserID, models.AUDIT_JOIN_REJECTED, request)); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return 0, err
 	}
 
+	metrics.JoinRequestTransition(joinRequestStatusName(request.Status), joinRequestStatusName(enums.REJECTED))
+
 	return affectedRows, nil
 }
+
+// Nome do status nas métricas
+func joinRequestStatusName(status enums.Status) string {
+	switch status {
+	case enums.PENDING:
+		return "pending"
+	case enums.APPROVED:
+		return "approved"
+	case enums.REJECTED:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// Pedido de entrada e decisões sobre ele. O usuário afetado é quem pediu para entrar
+func joinRequestAudit(actorID uint64, action string, request models.JoinRequest) models.AuditLog {
+	return models.AuditLog{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: models.AUDIT_TARGET_JOIN_REQUEST,
+		TargetID:   auditID(request.ID),
+		TeamID:     &request.TeamID,
+		UserID:     &request.SenderID,
+	}
+}
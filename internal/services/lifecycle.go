@@ -0,0 +1,167 @@
+package services
+
+import (
+	"HareID/config"
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LifecycleServices struct {
+	repo         repository.Repository
+	db           *pgxpool.Pool
+	cfg          config.Config
+	entitlements *EntitlementServices
+}
+
+// Aplica as transições de teste e de cobrança vencidas. Cada transição é registrada uma única vez,
+// então o job pode rodar em paralelo ou repetir sem duplicar notificações
+func (s *LifecycleServices) Run(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "LifecycleServices.Run")
+	defer span.End()
+
+	subscriptions, err := s.repo.Subscriptions.GetAllByStatus(ctx, subscription.TRIALING, subscription.PAST_DUE, subscription.UNPAID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	applied := 0
+
+	for _, sub := range subscriptions {
+		var ok bool
+
+		if sub.Status == subscription.TRIALING {
+			ok, err = s.trial(ctx, sub, now)
+		} else {
+			ok, err = s.dunning(ctx, sub, now)
+		}
+
+		// Uma assinatura com erro não impede as demais
+		if err != nil {
+			slog.ErrorContext(ctx, "billing lifecycle failed", "subscription_id", sub.SubscriptionID, "error", err)
+			continue
+		}
+
+		if ok {
+			applied++
+		}
+	}
+
+	if applied > 0 {
+		s.entitlements.Invalidate()
+	}
+
+	return applied, nil
+}
+
+// Encerra os testes gerenciados pelo HareID. Testes do Stripe terminam pelos webhooks
+func (s *LifecycleServices) trial(ctx context.Context, sub models.Subscription, now time.Time) (bool, error) {
+
+	if sub.Provider != payments.LOCAL {
+		return false, nil
+	}
+
+	if !now.Before(sub.CurrentPeriodEnd) {
+		return s.transition(ctx, sub, enums.TRIAL_ENDED, "trial_ended", "", enums.TRIAL_EXPIRED,
+			func(tx pgx.Tx) error {
+				_, err := s.repo.Subscriptions.UpdateStatus(ctx, tx, sub.SubscriptionID, subscription.INCOMPLETE_EXPIRED, nil, now)
+				return err
+			})
+	}
+
+	if now.After(sub.CurrentPeriodEnd.AddDate(0, 0, -s.cfg.Billing.TrialReminderDays)) {
+		detail := fmt.Sprintf("trial ends at %s", sub.CurrentPeriodEnd.Format(time.RFC3339))
+		return s.transition(ctx, sub, enums.TRIAL_REMINDER_SENT, "trial_reminder", detail, enums.TRIAL_WILL_END, nil)
+	}
+
+	return false, nil
+}
+
+// Lembretes durante a carência e aviso de bloqueio quando ela termina. O acesso em si é calculado pelas entitlements
+func (s *LifecycleServices) dunning(ctx context.Context, sub models.Subscription, now time.Time) (bool, error) {
+
+	// A carência é contada a partir da entrada em inadimplência; cada episódio tem seus próprios lembretes
+	start := graceStart(sub)
+	period := start.Unix()
+	graceEndsAt := start.AddDate(0, 0, s.cfg.Billing.GraceDays)
+
+	if !now.Before(graceEndsAt) {
+		return s.transition(ctx, sub, enums.GRACE_ENDED, fmt.Sprintf("grace_ended_%d", period), s.cfg.Billing.DunningAction, enums.GRACE_PERIOD_ENDED, nil)
+	}
+
+	due := dueReminder(start, s.cfg.Billing.DunningReminderDays, now)
+	if due < 0 {
+		return false, nil
+	}
+
+	detail := fmt.Sprintf("grace ends at %s", graceEndsAt.Format(time.RFC3339))
+	return s.transition(ctx, sub, enums.PAYMENT_REMINDER_SENT, fmt.Sprintf("payment_reminder_%d_%d", due, period), detail, enums.PAYMENT_REMINDER, nil)
+}
+
+// Apenas o lembrete mais recente vencido é enviado, mesmo que o job tenha ficado parado. -1 quando nenhum venceu
+func dueReminder(start time.Time, days []int, now time.Time) int {
+	due := -1
+	for _, day := range days {
+		if !now.Before(start.AddDate(0, 0, day)) {
+			due = day
+		}
+	}
+	return due
+}
+
+// Início da carência. O Stripe avança current_period_end quando a renovação falha, então vale o
+// momento em que a assinatura ficou inadimplente; registros anteriores à coluna usam o fim do período
+func graceStart(sub models.Subscription) time.Time {
+	if sub.PastDueSince != nil {
+		return *sub.PastDueSince
+	}
+	return sub.CurrentPeriodEnd
+}
+
+// Registra a transição, aplica a mudança e notifica o titular na mesma transação
+func (s *LifecycleServices) transition(ctx context.Context, sub models.Subscription, event enums.BillingEvent, dedupeKey, detail string, notification enums.NotificationType, apply func(tx pgx.Tx) error) (bool, error) {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := s.repo.BillingHistory.Create(ctx, tx, models.BillingHistory{
+		SubscriptionID: sub.SubscriptionID,
+		UserID:         sub.UserID,
+		TeamID:         sub.TeamID,
+		Event:          event,
+		Detail:         detail,
+	}, dedupeKey)
+	if err != nil || !created {
+		return false, err
+	}
+
+	if apply != nil {
+		if err := apply(tx); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := s.repo.Notifications.Create(ctx, tx, models.Notification{
+		ReceiverID:  sub.UserID,
+		Type:        notification,
+		ReferenceID: sub.ID,
+	}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"HareID/internal/models"
+	"testing"
+	"time"
+)
+
+func TestDueReminder(t *testing.T) {
+	pastDueSince := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	days := []int{0, 3, 6}
+
+	cases := []struct {
+		name string
+		sub  models.Subscription
+		now  time.Time
+		want int
+	}{
+		{"first reminder right away", models.Subscription{PastDueSince: &pastDueSince}, pastDueSince, 0},
+		{"latest overdue reminder only", models.Subscription{PastDueSince: &pastDueSince}, pastDueSince.AddDate(0, 0, 7), 6},
+		{
+			// O período já foi avançado para junho pela renovação falha; os lembretes seguem a inadimplência
+			name: "period end moved forward by the failed renewal",
+			sub: models.Subscription{
+				PastDueSince:     &pastDueSince,
+				CurrentPeriodEnd: pastDueSince.AddDate(0, 1, 0),
+			},
+			now:  pastDueSince.AddDate(0, 0, 4),
+			want: 3,
+		},
+		{
+			name: "legacy row counts from the period end",
+			sub:  models.Subscription{CurrentPeriodEnd: pastDueSince},
+			now:  pastDueSince.Add(-time.Hour),
+			want: -1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dueReminder(graceStart(tc.sub), days, tc.now); got != tc.want {
+				t.Errorf("dueReminder() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package services
 
 import (
+	"HareID/internal/audit"
 	"HareID/internal/authentication"
+	"HareID/internal/metrics"
+	"HareID/internal/models"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
+	"errors"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -13,21 +18,79 @@ type LoginServices struct {
 	db   *pgxpool.Pool
 }
 
-func (ls *LoginServices) Login(ctx context.Context, googleSubscription string) (string, error) {
+// Autentica o usuário. Se houver uma versão de termos obrigatória ainda não aceita,
+// devolve apenas um token restrito ao registro do consentimento
+func (ls *LoginServices) Login(ctx context.Context, googleSubscription string) (models.LoginResult, error) {
+	ctx, span := tracing.Start(ctx, "LoginServices.Login")
+	defer span.End()
+
+	result, err := ls.login(ctx, googleSubscription)
+	metrics.Login("google", err)
+
+	return result, err
+}
+
+func (ls *LoginServices) login(ctx context.Context, googleSubscription string) (models.LoginResult, error) {
 
 	user, err := ls.repo.Users.GetByGoogleSubscription(ctx, googleSubscription)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return models.LoginResult{}, ErrInvalidCredentials
+	}
 	if err != nil {
-		return "", err
+		return models.LoginResult{}, err
 	}
 
 	if err = user.ValidateUser("login"); err != nil {
-		return "", err
+		return models.LoginResult{}, err
+	}
+
+	current, err := ls.repo.TermsVersions.GetCurrent(ctx)
+	if err != nil {
+		return models.LoginResult{}, err
+	}
+
+	latest, err := ls.repo.Consents.GetLatestByUserID(ctx, user.ID)
+	if err != nil {
+		return models.LoginResult{}, err
+	}
+
+	if pending := pendingTerms(current, latest); len(pending) > 0 {
+		consentToken, err := authentication.CreateConsentToken(user.ID)
+		if err != nil {
+			return models.LoginResult{}, err
+		}
+
+		if err := recordAuditNow(ctx, ls.repo, ls.db, loginAudit(user.ID, authentication.ConsentScope)); err != nil {
+			return models.LoginResult{}, err
+		}
+
+		return models.LoginResult{
+			ConsentRequired: true,
+			ConsentToken:    consentToken,
+			PendingTerms:    pending,
+		}, nil
 	}
 
 	token, err := authentication.CreateToken(user.GoogleSub, user.ID)
 	if err != nil {
-		return "", err
+		return models.LoginResult{}, err
 	}
 
-	return token, nil
+	if err := recordAuditNow(ctx, ls.repo, ls.db, loginAudit(user.ID, "session")); err != nil {
+		return models.LoginResult{}, err
+	}
+
+	return models.LoginResult{Token: token}, nil
+}
+
+// Login com o escopo do token emitido: "session" ou o token restrito aos termos pendentes
+func loginAudit(userID uint64, scope string) models.AuditLog {
+	return models.AuditLog{
+		ActorID:    &userID,
+		Action:     models.AUDIT_LOGIN,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+		After:      audit.Snapshot(map[string]string{"token_scope": scope}),
+	}
 }
@@ -1,11 +1,12 @@
 package services
 
 import (
+	"HareID/internal/listquery"
 	"HareID/internal/models"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"HareID/internal/validators"
 	"context"
-	"errors"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -16,24 +17,23 @@ type NotificationServices struct {
 	db   *pgxpool.Pool
 }
 
-func (s *NotificationServices) GetAll(ctx context.Context, requestUserID, userID uint64) ([]models.Notification, error) {
+func (s *NotificationServices) GetAll(ctx context.Context, requestUserID, userID uint64, params listquery.Params) (listquery.Page[models.Notification], error) {
+	ctx, span := tracing.Start(ctx, "NotificationServices.GetAll")
+	defer span.End()
 
 	if requestUserID != userID {
-		return nil, errors.New("you can only see your own notifications")
+		return listquery.Page[models.Notification]{}, ErrNotDataOwner
 	}
 
-	notifications, err := s.repo.Notifications.GetAll(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	return notifications, nil
+	return s.repo.Notifications.GetAll(ctx, userID, params)
 }
 
 func (s *NotificationServices) GetByID(ctx context.Context, requestUserID, userID, notificationID uint64) (models.Notification, error) {
+	ctx, span := tracing.Start(ctx, "NotificationServices.GetByID")
+	defer span.End()
 
 	if requestUserID != userID {
-		return models.Notification{}, errors.New("you can only see your own notifications")
+		return models.Notification{}, ErrNotDataOwner
 	}
 
 	notification, err := s.repo.Notifications.GetByID(ctx, userID, notificationID)
@@ -45,9 +45,11 @@ func (s *NotificationServices) GetByID(ctx context.Context, requestUserID, userI
 }
 
 func (s *NotificationServices) Delete(ctx context.Context, requestUserID, userID, notificationID uint64) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "NotificationServices.Delete")
+	defer span.End()
 
 	if requestUserID != userID {
-		return 0, errors.New("you can only delete your own notifications")
+		return 0, ErrNotDataOwner
 	}
 
 	tx, err := s.db.Begin(ctx)
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"HareID/internal/models"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+)
+
+type PlanServices struct {
+	repo repository.Repository
+}
+
+func (s *PlanServices) GetAll(ctx context.Context) ([]models.Plan, error) {
+	ctx, span := tracing.Start(ctx, "PlanServices.GetAll")
+	defer span.End()
+
+	plans, err := s.repo.Plans.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]models.Plan{models.FreePlan}, plans...), nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"HareID/internal/listquery"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
+)
+
+const (
+	driftSubscription = "subscription"
+	driftCustomer     = "customer"
+)
+
+type ReconciliationServices struct {
+	repo          repository.Repository
+	db            *pgxpool.Pool
+	stripe        *client.API
+	subscriptions *SubscriptionServices
+	entitlements  *EntitlementServices
+}
+
+// Compara assinaturas e clientes locais com o Stripe. Com fix, o estado do Stripe é gravado localmente
+func (s *ReconciliationServices) Run(ctx context.Context, fix bool) (models.ReconciliationReport, error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationServices.Run")
+	defer span.End()
+
+	report := models.ReconciliationReport{
+		StartedAt: time.Now().UTC(),
+		Fix:       fix,
+		Drifts:    []models.ReconciliationDrift{},
+	}
+
+	if err := s.reconcileSubscriptions(ctx, fix, &report); err != nil {
+		return report, err
+	}
+
+	if err := s.reconcileCustomers(ctx, fix, &report); err != nil {
+		return report, err
+	}
+
+	report.FinishedAt = time.Now().UTC()
+
+	return report, nil
+}
+
+func (s *ReconciliationServices) reconcileSubscriptions(ctx context.Context, fix bool, report *models.ReconciliationReport) error {
+
+	localSubscriptions, err := listquery.All(ctx, s.repo.Subscriptions.GetAll)
+	if err != nil {
+		return err
+	}
+
+	// Assinaturas de outros provedores não aparecem no Stripe
+	local := make(map[string]models.Subscription, len(localSubscriptions))
+	for _, sub := range localSubscriptions {
+		if sub.Provider == payments.STRIPE {
+			local[sub.SubscriptionID] = sub
+		}
+	}
+
+	params := &stripe.SubscriptionListParams{
+		Status: stripe.String("all"),
+	}
+	params.Context = ctx
+
+	iter := s.stripe.Subscriptions.List(params)
+	for iter.Next() {
+		stripeSub := iter.Subscription()
+		report.SubscriptionsChecked++
+
+		localSub, found := local[stripeSub.ID]
+		delete(local, stripeSub.ID)
+
+		remote, err := subscriptionFromStripe(ctx, s.repo, *stripeSub)
+		if err != nil {
+			report.Drifts = append(report.Drifts, models.ReconciliationDrift{
+				Kind:  driftSubscription,
+				ID:    stripeSub.ID,
+				Field: "mapping",
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		var drifts []models.ReconciliationDrift
+
+		if !found {
+			drifts = append(drifts, models.ReconciliationDrift{
+				Kind:   driftSubscription,
+				ID:     stripeSub.ID,
+				Field:  "missing_locally",
+				Remote: subscriptionStatusName(remote.Status),
+			})
+		} else {
+			drifts = subscriptionDrifts(localSub, remote)
+		}
+
+		if len(drifts) == 0 {
+			continue
+		}
+
+		if fix {
+			fixErr := s.applySubscription(ctx, remote)
+			for i := range drifts {
+				drifts[i].Fixed = fixErr == nil
+				if fixErr != nil {
+					drifts[i].Error = fixErr.Error()
+				}
+			}
+		}
+
+		report.Drifts = append(report.Drifts, drifts...)
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	// O que sobrou existe apenas localmente e não pode ser corrigido automaticamente
+	for _, sub := range local {
+		report.Drifts = append(report.Drifts, models.ReconciliationDrift{
+			Kind:  driftSubscription,
+			ID:    sub.SubscriptionID,
+			Field: "missing_in_stripe",
+			Local: subscriptionStatusName(sub.Status),
+		})
+	}
+
+	return nil
+}
+
+func (s *ReconciliationServices) reconcileCustomers(ctx context.Context, fix bool, report *models.ReconciliationReport) error {
+
+	users, err := listquery.All(ctx, s.repo.Users.GetAll)
+	if err != nil {
+		return err
+	}
+
+	linked := make(map[string]models.User)
+	for _, user := range users {
+		if user.StripeCustomerID != "" {
+			linked[user.StripeCustomerID] = user
+		}
+	}
+
+	params := &stripe.CustomerListParams{}
+	params.Context = ctx
+
+	iter := s.stripe.Customers.List(params)
+	for iter.Next() {
+		customer := iter.Customer()
+		report.CustomersChecked++
+
+		if _, ok := linked[customer.ID]; ok {
+			delete(linked, customer.ID)
+			continue
+		}
+
+		// Cliente sem usuário local: só é reportado, pois não há como saber a quem pertence
+		report.Drifts = append(report.Drifts, models.ReconciliationDrift{
+			Kind:   driftCustomer,
+			ID:     customer.ID,
+			Field:  "stripe_customer_id",
+			Remote: customer.ID,
+		})
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	// Usuários apontando para clientes que não existem mais no Stripe
+	for customerID, user := range linked {
+		drift := models.ReconciliationDrift{
+			Kind:  driftCustomer,
+			ID:    customerID,
+			Field: "stripe_customer_id",
+			Local: strconv.FormatUint(user.ID, 10),
+		}
+
+		if fix {
+			if err := s.unlinkCustomer(ctx, user.ID); err != nil {
+				drift.Error = err.Error()
+			} else {
+				drift.Fixed = true
+			}
+		}
+
+		report.Drifts = append(report.Drifts, drift)
+	}
+
+	return nil
+}
+
+// Grava pelo mesmo caminho dos webhooks, com entrada na auditoria e cache de direitos limpo
+func (s *ReconciliationServices) applySubscription(ctx context.Context, sub models.Subscription) error {
+
+	// O estado lido agora do Stripe é mais novo que qualquer evento já emitido
+	if err := s.subscriptions.UpsertSubscription(ctx, sub, time.Now()); err != nil {
+		return err
+	}
+
+	s.entitlements.Invalidate()
+
+	return nil
+}
+
+func (s *ReconciliationServices) unlinkCustomer(ctx context.Context, userID uint64) error {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Users.SetStripeCustomerID(ctx, tx, userID, ""); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func subscriptionDrifts(local, remote models.Subscription) []models.ReconciliationDrift {
+	var drifts []models.ReconciliationDrift
+
+	add := func(field, localValue, remoteValue string) {
+		if localValue != remoteValue {
+			drifts = append(drifts, models.ReconciliationDrift{
+				Kind:   driftSubscription,
+				ID:     remote.SubscriptionID,
+				Field:  field,
+				Local:  localValue,
+				Remote: remoteValue,
+			})
+		}
+	}
+
+	add("status", subscriptionStatusName(local.Status), subscriptionStatusName(remote.Status))
+	add("price_id", local.PriceID, remote.PriceID)
+	add("current_period_start", local.CurrentPeriodStart.UTC().Format(time.RFC3339), remote.CurrentPeriodStart.UTC().Format(time.RFC3339))
+	add("current_period_end", local.CurrentPeriodEnd.UTC().Format(time.RFC3339), remote.CurrentPeriodEnd.UTC().Format(time.RFC3339))
+	add("quantity", fmt.Sprint(local.Quantity), fmt.Sprint(remote.Quantity))
+
+	return drifts
+}
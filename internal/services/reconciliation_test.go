@@ -0,0 +1,54 @@
+package services
+
+import (
+	"HareID/internal/enums/subscription"
+	"HareID/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionDrifts(t *testing.T) {
+	periodEnd := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	local := models.Subscription{SubscriptionID: "sub_1", Status: subscription.ACTIVE, PriceID: "price_pro", Quantity: 3, CurrentPeriodEnd: periodEnd}
+
+	cases := []struct {
+		name   string
+		remote func(sub models.Subscription) models.Subscription
+		want   []models.ReconciliationDrift
+	}{
+		{
+			name:   "in sync",
+			remote: func(sub models.Subscription) models.Subscription { return sub },
+		},
+		{
+			name: "status reported by name",
+			remote: func(sub models.Subscription) models.Subscription {
+				sub.Status = subscription.PAST_DUE
+				return sub
+			},
+			want: []models.ReconciliationDrift{{Kind: driftSubscription, ID: "sub_1", Field: "status", Local: "active", Remote: "past_due"}},
+		},
+		{
+			name: "quantity",
+			remote: func(sub models.Subscription) models.Subscription {
+				sub.Quantity = 5
+				return sub
+			},
+			want: []models.ReconciliationDrift{{Kind: driftSubscription, ID: "sub_1", Field: "quantity", Local: "3", Remote: "5"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := subscriptionDrifts(local, tc.remote(local))
+			if len(got) != len(tc.want) {
+				t.Fatalf("drifts = %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("drift[%d] = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,38 +1,57 @@
 package services
 
 import (
+	"HareID/config"
 	"HareID/internal/enums"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/payments"
 	"HareID/internal/repository"
 	"HareID/internal/validators"
 	"context"
+	"net/http"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v79/client"
 )
 
 type Services struct {
 	Login interface {
-		Login(ctx context.Context, googleSubscription string) (string, error)
+		Login(ctx context.Context, googleSubscription string) (models.LoginResult, error)
 	}
 	Users interface {
-		Create(ctx context.Context, user models.User) (models.User, error)
-		GetAll(ctx context.Context) ([]models.User, error)
-		GetByID(ctx context.Context, userID uint64) (models.User, error)
+		Create(ctx context.Context, user models.User, source models.ConsentSource) (models.User, error)
+		GetAll(ctx context.Context, requestUserID uint64, params listquery.Params) (listquery.Page[models.User], error)
+		GetByID(ctx context.Context, requestUserID, userID uint64) (models.User, error)
+		GetByCpfCnpj(ctx context.Context, requestUserID uint64, cpfCnpj string) (models.User, error)
 		GetByStripeCustomerID(ctx context.Context, stripeCustomerID string) (models.User, error)
 		Update(ctx context.Context, userID, requestUserID uint64, user models.User) (uint64, error)
-		Delete(ctx context.Context, userID, requestUserID uint64) (uint64, error)
+		ValidateSession(ctx context.Context, userID uint64, issuedAt time.Time) error
+		IsAdmin(ctx context.Context, userID uint64) (bool, error)
+		SetAdmin(ctx context.Context, requestUserID, userID uint64, isAdmin bool) (uint64, error)
+		PromoteAdmins(ctx context.Context) error
+		RotateEncryptionKeys(ctx context.Context, afterID uint64, batchSize int) (models.KeyRotationBatch, error)
 	}
 	Subscriptions interface {
 		Create(ctx context.Context, subscription models.Subscription) (models.Subscription, error)
-		GetAll(ctx context.Context) ([]models.Subscription, error)
-		GetBySubscriptionID(ctx context.Context, subscriptionID string) (models.Subscription, error)
+		GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Subscription], error)
+		GetAllByUserID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Subscription], error)
+		GetBySubscriptionID(ctx context.Context, requestUserID uint64, subscriptionID string) (models.Subscription, error)
 		Update(ctx context.Context, subscriptionID string, subscription models.Subscription) (uint64, error)
 		Delete(ctx context.Context, subscriptionID string) (uint64, error)
-		UpsertSubscription(ctx context.Context, subscription models.Subscription) error
+		UpsertSubscription(ctx context.Context, subscription models.Subscription, eventAt time.Time) error
+		SyncTeamSeats(ctx context.Context, teamID uint64) error
+		Cancel(ctx context.Context, requestUserID uint64, subscriptionID string, atPeriodEnd bool) error
+		PreviewPlanChange(ctx context.Context, requestUserID uint64, subscriptionID, priceID string) (models.PlanChangePreview, error)
+		ChangePlan(ctx context.Context, requestUserID uint64, subscriptionID, priceID string, prorationDate int64) error
+		CountByStatus(ctx context.Context) (map[string]uint64, error)
 	}
 	Teams interface {
 		Create(ctx context.Context, requestUserID uint64, team models.Team) (models.Team, models.TeamMember, error)
-		GetAll(ctx context.Context) ([]models.Team, error)
+		GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Team], error)
+		GetAllByMemberID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Team], error)
 		GetByID(ctx context.Context, teamID uint64) (models.Team, error)
 		GetByOwnerID(ctx context.Context, userID uint64) (models.Team, error)
 		Update(ctx context.Context, teamID, requestUserID uint64, team models.Team) (uint64, error)
@@ -42,36 +61,118 @@ type Services struct {
 	}
 	TeamMembers interface {
 		Create(ctx context.Context, role enums.TeamRole, teamID, userID uint64) (models.TeamMember, error)
-		GetAll(ctx context.Context, teamID uint64) ([]models.TeamMember, error)
-		GetByUserID(ctx context.Context, userID uint64) (models.TeamMember, error)
+		GetAll(ctx context.Context, teamID uint64, params listquery.Params) (listquery.Page[models.TeamMember], error)
+		GetByUserID(ctx context.Context, requestUserID, userID uint64) (models.TeamMember, error)
+		Delete(ctx context.Context, requestUserID, teamID, userID uint64) (uint64, error)
 	}
 	JoinRequests interface {
 		Create(ctx context.Context, requestUserID, teamID uint64) (models.JoinRequest, models.Notification, error)
-		GetAll(ctx context.Context, requestUserID, teamID uint64) ([]models.JoinRequest, error)
+		GetAll(ctx context.Context, requestUserID, teamID uint64, params listquery.Params) (listquery.Page[models.JoinRequest], error)
 		GetByID(ctx context.Context, requestUserID, teamID, requestID uint64) (models.JoinRequest, error)
 		Delete(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error)
-		Accept(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error)
+		Accept(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, models.TeamMember, error)
 		Reject(ctx context.Context, requestUserID, teamID, requestID uint64) (uint64, error)
 	}
 	Notifications interface {
-		GetAll(ctx context.Context, requestUserID, userID uint64) ([]models.Notification, error)
+		GetAll(ctx context.Context, requestUserID, userID uint64, params listquery.Params) (listquery.Page[models.Notification], error)
 		GetByID(ctx context.Context, requestUserID, userID, notificationID uint64) (models.Notification, error)
 		Delete(ctx context.Context, requestUserID, userID, notificationID uint64) (uint64, error)
 	}
 	Checkout interface {
 		CreateCheckoutSession(ctx context.Context, userID uint64, priceID, successURL, cancelURL string) (string, error)
+		CreateTeamCheckoutSession(ctx context.Context, requestUserID, teamID uint64, priceID, successURL, cancelURL string) (string, error)
+	}
+	Billing interface {
+		CreatePortalSession(ctx context.Context, userID uint64, returnURL string) (string, error)
+		GetInvoices(ctx context.Context, userID uint64) ([]models.Invoice, error)
+		GetHistory(ctx context.Context, userID uint64) ([]models.BillingHistory, error)
+	}
+	Lifecycle interface {
+		Run(ctx context.Context) (int, error)
+	}
+	Plans interface {
+		GetAll(ctx context.Context) ([]models.Plan, error)
+	}
+	Webhooks interface {
+		Receive(ctx context.Context, providerName string, payload []byte, header http.Header) (bool, error)
+		ProcessPending(ctx context.Context, batchSize int) (int, error)
+		GetAll(ctx context.Context, status enums.WebhookEventStatus, params listquery.Params) (listquery.Page[models.StripeEvent], error)
+		Replay(ctx context.Context, eventID string) (models.StripeEvent, error)
+	}
+	Usage interface {
+		Record(ctx context.Context, events []models.UsageEvent) (int, int, error)
+		ReportPending(ctx context.Context, batchSize int) (int, error)
+		GetTeamSummary(ctx context.Context, requestUserID, teamID uint64) (models.UsageSummary, error)
+	}
+	Exports interface {
+		Request(ctx context.Context, requestUserID, userID uint64, includeCSV bool) (models.DataExport, error)
+		GetByID(ctx context.Context, requestUserID, userID, exportID uint64) (models.DataExport, error)
+		Open(ctx context.Context, exportID uint64, expires int64, signature string) (models.DataExport, error)
+		ProcessPending(ctx context.Context, batchSize int) (int, error)
+		PurgeExpired(ctx context.Context) (int, error)
+	}
+	Deletions interface {
+		Schedule(ctx context.Context, requestUserID, userID uint64, teamPolicy string) (models.AccountDeletion, error)
+		Cancel(ctx context.Context, requestUserID, userID uint64) (models.AccountDeletion, error)
+		Get(ctx context.Context, requestUserID, userID uint64) (models.AccountDeletion, error)
+		ProcessDue(ctx context.Context, batchSize int) (int, error)
+	}
+	Consents interface {
+		GetCurrentTerms(ctx context.Context) ([]models.TermsVersion, error)
+		PublishTerms(ctx context.Context, version models.TermsVersion) (models.TermsVersion, error)
+		GetByUserID(ctx context.Context, userID uint64) (models.ConsentOverview, error)
+		Record(ctx context.Context, userID uint64, requests []models.UserConsent, source models.ConsentSource) (models.ConsentResult, error)
+	}
+	Audit interface {
+		GetByTeamID(ctx context.Context, requestUserID, teamID uint64, filter models.AuditFilter) ([]models.AuditLog, error)
+		GetByUserID(ctx context.Context, requestUserID, userID uint64, filter models.AuditFilter) ([]models.AuditLog, error)
+		Verify(ctx context.Context) (models.AuditVerification, error)
+	}
+	Health interface {
+		Readiness(ctx context.Context) models.Readiness
+		StartDraining()
+	}
+	Reconciliation interface {
+		Run(ctx context.Context, fix bool) (models.ReconciliationReport, error)
+	}
+	Entitlements interface {
+		GetByUserID(ctx context.Context, userID uint64) (models.Entitlement, error)
+		GetByTeamID(ctx context.Context, teamID uint64) (models.Entitlement, error)
+		CanCreateTeam(ctx context.Context, tx pgx.Tx, userID uint64) error
+		CanAddMember(ctx context.Context, tx pgx.Tx, teamID uint64) error
+		CanModifyTeam(ctx context.Context, teamID uint64) error
+		GetEffective(ctx context.Context, userID uint64) (models.UserEntitlements, error)
+		CheckFeature(ctx context.Context, userID uint64, feature string) (models.FeatureCheck, error)
+		CheckTeamFeature(ctx context.Context, teamID uint64, feature string) (models.FeatureCheck, error)
+		Invalidate()
 	}
 }
 
-func NewServices(r repository.Repository, v validators.Validations, db *pgxpool.Pool) Services {
+func NewServices(cfg config.Config, r repository.Repository, v validators.Validations, db *pgxpool.Pool, sc *client.API, providers payments.Registry) Services {
+	entitlements := &EntitlementServices{repo: r, db: db, cfg: cfg, cache: newEntitlementCache(cfg.Billing.EntitlementCacheTTL)}
+	subscriptions := &SubscriptionServices{repo: r, db: db, stripe: sc, providers: providers}
+	members := &TeamMembersServices{repo: r, db: db, val: v, entitlements: entitlements, billing: subscriptions}
+
 	return Services{
-		Login:         &LoginServices{repo: r, db: db},
-		Users:         &UserServices{repo: r, db: db},
-		Subscriptions: &SubscriptionServices{repo: r, db: db},
-		Teams:         &TeamServices{repo: r, db: db},
-		TeamMembers:   &TeamMembersServices{repo: r, db: db, val: v},
-		JoinRequests:  &JoinRequestServices{repo: r, db: db, val: v},
-		Notifications: &NotificationServices{repo: r, db: db, val: v},
-		Checkout:      &CheckoutServices{},
+		Login:          &LoginServices{repo: r, db: db},
+		Users:          &UserServices{repo: r, db: db, cfg: cfg, val: v},
+		Subscriptions:  subscriptions,
+		Teams:          &TeamServices{repo: r, db: db, cfg: cfg, entitlements: entitlements},
+		TeamMembers:    members,
+		JoinRequests:   &JoinRequestServices{repo: r, db: db, val: v, members: members},
+		Notifications:  &NotificationServices{repo: r, db: db, val: v},
+		Checkout:       &CheckoutServices{repo: r, db: db, providers: providers},
+		Billing:        &BillingServices{repo: r, stripe: sc},
+		Plans:          &PlanServices{repo: r},
+		Entitlements:   entitlements,
+		Webhooks:       &WebhookServices{repo: r, db: db, subscriptions: subscriptions, entitlements: entitlements, providers: providers},
+		Reconciliation: &ReconciliationServices{repo: r, db: db, stripe: sc, subscriptions: subscriptions, entitlements: entitlements},
+		Lifecycle:      &LifecycleServices{repo: r, db: db, cfg: cfg, entitlements: entitlements},
+		Usage:          &UsageServices{repo: r, db: db, stripe: sc, entitlements: entitlements},
+		Exports:        &ExportServices{repo: r, db: db, cfg: cfg},
+		Consents:       &ConsentServices{repo: r, db: db},
+		Audit:          &AuditServices{repo: r, db: db, entitlements: entitlements},
+		Health:         &HealthServices{db: db, cfg: cfg},
+		Deletions:      &DeletionServices{repo: r, db: db, cfg: cfg, subscriptions: subscriptions, entitlements: entitlements},
 	}
 }
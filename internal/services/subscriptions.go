@@ -1,24 +1,41 @@
 package services
 
 import (
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/payments"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
 	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
 )
 
 type SubscriptionServices struct {
-	repo repository.Repository
-	db   *pgxpool.Pool
+	repo      repository.Repository
+	db        *pgxpool.Pool
+	stripe    *client.API
+	providers payments.Registry
 }
 
 func (s *SubscriptionServices) Create(ctx context.Context, subscription models.Subscription) (models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.Create")
+	defer span.End()
+
 	if subscription.SubscriptionID == "" {
-		return models.Subscription{}, errors.New("subscription_id is required")
+		return models.Subscription{}, apperrors.Field("subscription_id", "is required")
 	}
-	
+
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return models.Subscription{}, err
@@ -37,27 +54,44 @@ func (s *SubscriptionServices) Create(ctx context.Context, subscription models.S
 	return createdSubscription, nil
 }
 
-func (s *SubscriptionServices) GetAll(ctx context.Context) ([]models.Subscription, error) {
+func (s *SubscriptionServices) GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Subscription], error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.GetAll")
+	defer span.End()
 
-	subscriptions, err := s.repo.Subscriptions.GetAll(ctx)
-	if err != nil {
-		return nil, err
-	}
+	return s.repo.Subscriptions.GetAll(ctx, params)
+}
 
-	return subscriptions, nil
+// Assinaturas das quais o usuário é titular, com os mesmos filtros de GET /subscriptions.
+// O filtro de user_id é somado aos do cliente, então um user_id de outro usuário resulta em lista vazia
+func (s *SubscriptionServices) GetAllByUserID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Subscription], error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.GetAllByUserID")
+	defer span.End()
+
+	params.Filters = append(slices.Clone(params.Filters), listquery.Filter{Field: "user_id", Operator: listquery.Eq, Value: strconv.FormatUint(userID, 10)})
+
+	return s.repo.Subscriptions.GetAll(ctx, params)
 }
 
-func (s *SubscriptionServices) GetBySubscriptionID(ctx context.Context, subscriptionID string) (models.Subscription, error) {
+// Visível para o dono da assinatura e os administradores da plataforma
+func (s *SubscriptionServices) GetBySubscriptionID(ctx context.Context, requestUserID uint64, subscriptionID string) (models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.GetBySubscriptionID")
+	defer span.End()
 
 	subscription, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, subscriptionID)
 	if err != nil {
 		return models.Subscription{}, err
 	}
 
+	if err := requireOwnerOrAdmin(ctx, s.repo, requestUserID, subscription.UserID); err != nil {
+		return models.Subscription{}, err
+	}
+
 	return subscription, nil
 }
 
 func (s *SubscriptionServices) Update(ctx context.Context, subscriptionID string, subscription models.Subscription) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.Update")
+	defer span.End()
 
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
@@ -78,6 +112,9 @@ func (s *SubscriptionServices) Update(ctx context.Context, subscriptionID string
 }
 
 func (s *SubscriptionServices) Delete(ctx context.Context, subscriptionID string) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.Delete")
+	defer span.End()
+
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return 0, nil
@@ -96,16 +133,379 @@ func (s *SubscriptionServices) Delete(ctx context.Context, subscriptionID string
 	return affectedRows, nil
 }
 
-func (s *SubscriptionServices) UpsertSubscription(ctx context.Context, subscription models.Subscription) error {
-	// Tenta buscar a assinatura existente
-	existing, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, subscription.SubscriptionID)
-	if err == nil {
-		// Se existir, atualiza
-		_, err := s.Update(ctx, existing.SubscriptionID, subscription)
+// Aplica o estado recebido por webhook. Eventos mais antigos que o último aplicado não sobrescrevem o estado atual
+func (s *SubscriptionServices) UpsertSubscription(ctx context.Context, subscription models.Subscription, eventAt time.Time) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.UpsertSubscription")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var before any
+	if current, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, subscription.SubscriptionID); err == nil {
+		before = subscriptionAuditState(current)
+	}
+
+	applied, err := s.repo.Subscriptions.Upsert(ctx, tx, subscription, eventAt)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		slog.InfoContext(ctx, "skipping stale event", "subscription_id", subscription.SubscriptionID)
+		return tx.Commit(ctx)
+	}
+
+	entry, err := auditDiff(models.AuditLog{
+		Action:     models.AUDIT_SUBSCRIPTION_SYNCED,
+		TargetType: models.AUDIT_TARGET_SUBSCRIPTION,
+		TargetID:   subscription.SubscriptionID,
+		TeamID:     subscription.TeamID,
+	}, before, subscriptionAuditState(subscription))
+	if err != nil {
+		return err
+	}
+
+	if subscription.UserID != 0 {
+		entry.UserID = &subscription.UserID
+	}
+
+	// Eventos repetidos sem mudança não geram entrada
+	if entry.Before != nil || entry.After != nil {
+		if err := recordAudit(ctx, s.repo, tx, entry); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Ajusta a quantidade de assentos cobrada no Stripe para o número atual de membros da equipe
+func (s *SubscriptionServices) SyncTeamSeats(ctx context.Context, teamID uint64) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.SyncTeamSeats")
+	defer span.End()
+
+	teamSubscription, err := s.repo.Subscriptions.GetCurrentByTeamID(ctx, teamID)
+	if err != nil {
+		// Equipe sem cobrança por assento
+		return nil
+	}
+
+	switch teamSubscription.Status {
+	case subscription.ACTIVE, subscription.TRIALING, subscription.PAST_DUE:
+	default:
+		return nil
+	}
+
+	// Apenas o Stripe cobra por quantidade de assentos
+	if teamSubscription.Provider != payments.STRIPE {
+		return nil
+	}
+
+	seats, err := s.repo.TeamMembers.CountByTeamID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	if int64(seats) == teamSubscription.Quantity {
+		return nil
+	}
+
+	getParams := &stripe.SubscriptionParams{}
+	getParams.Context = ctx
+
+	stripeSub, err := s.stripe.Subscriptions.Get(teamSubscription.SubscriptionID, getParams)
+	if err != nil {
+		return err
+	}
+
+	item, err := currentItem(stripeSub, teamSubscription.PriceID)
+	if err != nil {
+		return err
+	}
+
+	updateParams := &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{
+				ID:       stripe.String(item.ID),
+				Quantity: stripe.Int64(int64(seats)),
+			},
+		},
+		ProrationBehavior: stripe.String("create_prorations"),
+	}
+	updateParams.Context = ctx
+
+	if _, err := s.stripe.Subscriptions.Update(teamSubscription.SubscriptionID, updateParams); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Subscriptions.UpdateQuantity(ctx, tx, teamSubscription.SubscriptionID, int64(seats)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Cancela a assinatura no provedor de pagamento. O estado local é atualizado pelo webhook resultante
+func (s *SubscriptionServices) Cancel(ctx context.Context, requestUserID uint64, subscriptionID string, atPeriodEnd bool) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.Cancel")
+	defer span.End()
+
+	local, err := s.owned(ctx, requestUserID, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAuditNow(ctx, s.repo, s.db, models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_CANCEL_REQUESTED,
+		TargetType: models.AUDIT_TARGET_SUBSCRIPTION,
+		TargetID:   subscriptionID,
+		TeamID:     local.TeamID,
+		UserID:     &local.UserID,
+		After:      audit.Snapshot(map[string]bool{"at_period_end": atPeriodEnd}),
+	}); err != nil {
+		return err
+	}
+
+	// O teste do HareID não existe em nenhum provedor e é encerrado localmente
+	if local.Provider == payments.LOCAL {
+		return s.cancelLocal(ctx, subscriptionID)
+	}
+
+	provider, err := s.providers.Get(local.Provider)
+	if err != nil {
+		return err
+	}
+
+	return provider.Cancel(ctx, subscriptionID, atPeriodEnd)
+}
+
+func (s *SubscriptionServices) cancelLocal(ctx context.Context, subscriptionID string) error {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Subscriptions.UpdateStatus(ctx, tx, subscriptionID, subscription.CANCELED, nil, time.Now()); err != nil {
 		return err
 	}
 
-	// Se não existir, cria uma nova
-	_, err = s.Create(ctx, subscription)
+	return tx.Commit(ctx)
+}
+
+// Calcula quanto será cobrado na próxima fatura ao trocar para o novo preço
+func (s *SubscriptionServices) PreviewPlanChange(ctx context.Context, requestUserID uint64, subscriptionID, priceID string) (models.PlanChangePreview, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.PreviewPlanChange")
+	defer span.End()
+
+	local, err := s.owned(ctx, requestUserID, subscriptionID)
+	if err != nil {
+		return models.PlanChangePreview{}, err
+	}
+
+	item, err := s.changeItem(ctx, local, priceID)
+	if err != nil {
+		return models.PlanChangePreview{}, err
+	}
+
+	// A mesma data deve ser enviada na troca para que o valor cobrado seja o da prévia
+	prorationDate := time.Now().Unix()
+
+	params := &stripe.InvoiceUpcomingParams{
+		Subscription: stripe.String(subscriptionID),
+		SubscriptionItems: []*stripe.SubscriptionItemsParams{
+			{
+				ID:    stripe.String(item.ID),
+				Price: stripe.String(priceID),
+			},
+		},
+		SubscriptionProrationBehavior: stripe.String("create_prorations"),
+		SubscriptionProrationDate:     stripe.Int64(prorationDate),
+	}
+	params.Context = ctx
+
+	upcoming, err := s.stripe.Invoices.Upcoming(params)
+	if err != nil {
+		return models.PlanChangePreview{}, err
+	}
+
+	return models.PlanChangePreview{
+		SubscriptionID: subscriptionID,
+		PriceID:        priceID,
+		Currency:       string(upcoming.Currency),
+		AmountDue:      upcoming.AmountDue,
+		ProrationDate:  prorationDate,
+	}, nil
+}
+
+// Troca o preço da assinatura com rateio. O estado local é atualizado pelo webhook resultante
+func (s *SubscriptionServices) ChangePlan(ctx context.Context, requestUserID uint64, subscriptionID, priceID string, prorationDate int64) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.ChangePlan")
+	defer span.End()
+
+	local, err := s.owned(ctx, requestUserID, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.changeItem(ctx, local, priceID)
+	if err != nil {
+		return err
+	}
+
+	params := &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{
+				ID:    stripe.String(item.ID),
+				Price: stripe.String(priceID),
+			},
+		},
+		ProrationBehavior: stripe.String("create_prorations"),
+	}
+	if prorationDate > 0 {
+		params.ProrationDate = stripe.Int64(prorationDate)
+	}
+	params.Context = ctx
+
+	if err := recordAuditNow(ctx, s.repo, s.db, models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_PLAN_CHANGED,
+		TargetType: models.AUDIT_TARGET_SUBSCRIPTION,
+		TargetID:   subscriptionID,
+		TeamID:     local.TeamID,
+		UserID:     &local.UserID,
+		Before:     audit.Snapshot(map[string]string{"price_id": local.PriceID}),
+		After:      audit.Snapshot(map[string]string{"price_id": priceID}),
+	}); err != nil {
+		return err
+	}
+
+	_, err = s.stripe.Subscriptions.Update(subscriptionID, params)
 	return err
 }
+
+// Nomes dos status usados nas métricas, no formato do Stripe
+var subscriptionStatusNames = map[subscription.Subscription]string{
+	subscription.UNKNOWN:            "unknown",
+	subscription.ACTIVE:             "active",
+	subscription.INACTIVE:           "inactive",
+	subscription.CANCELED:           "canceled",
+	subscription.PAST_DUE:           "past_due",
+	subscription.UNPAID:             "unpaid",
+	subscription.TRIALING:           "trialing",
+	subscription.INCOMPLETE:         "incomplete",
+	subscription.INCOMPLETE_EXPIRED: "incomplete_expired",
+	subscription.OPEN:               "open",
+}
+
+func subscriptionStatusName(status subscription.Subscription) string {
+	if name, ok := subscriptionStatusNames[status]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Quantidade de assinaturas por status, para as métricas
+func (s *SubscriptionServices) CountByStatus(ctx context.Context) (map[string]uint64, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionServices.CountByStatus")
+	defer span.End()
+
+	counts, err := s.repo.Subscriptions.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]uint64{}
+	for status, count := range counts {
+		byName[subscriptionStatusName(status)] += count
+	}
+
+	return byName, nil
+}
+
+// Campos da assinatura registrados na auditoria
+func subscriptionAuditState(sub models.Subscription) map[string]any {
+	return map[string]any{
+		"status":               sub.Status,
+		"price_id":             sub.PriceID,
+		"quantity":             sub.Quantity,
+		"current_period_start": sub.CurrentPeriodStart,
+		"current_period_end":   sub.CurrentPeriodEnd,
+	}
+}
+
+func (s *SubscriptionServices) owned(ctx context.Context, requestUserID uint64, subscriptionID string) (models.Subscription, error) {
+
+	local, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, subscriptionID)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	if local.UserID != requestUserID {
+		return models.Subscription{}, ErrNotSubscriptionOwner
+	}
+
+	return local, nil
+}
+
+// Valida o novo preço e devolve o item do Stripe que será trocado
+func (s *SubscriptionServices) changeItem(ctx context.Context, local models.Subscription, priceID string) (*stripe.SubscriptionItem, error) {
+	if priceID == "" {
+		return nil, apperrors.Field("price_id", "is required")
+	}
+
+	if priceID == local.PriceID {
+		return nil, apperrors.Conflict("already_on_plan", "subscription is already on this plan")
+	}
+
+	// A troca com rateio só existe no Stripe
+	if local.Provider != payments.STRIPE {
+		return nil, payments.ErrUnsupported
+	}
+
+	plan, err := s.repo.Plans.GetByPriceID(ctx, priceID)
+	if err != nil {
+		return nil, ErrUnknownPlan
+	}
+
+	if plan.Provider != payments.STRIPE {
+		return nil, payments.ErrUnsupported
+	}
+
+	params := &stripe.SubscriptionParams{}
+	params.Context = ctx
+
+	stripeSub, err := s.stripe.Subscriptions.Get(local.SubscriptionID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return currentItem(stripeSub, local.PriceID)
+}
+
+// Item da assinatura que corresponde ao preço gravado localmente, ou o primeiro item
+func currentItem(stripeSub *stripe.Subscription, priceID string) (*stripe.SubscriptionItem, error) {
+	if stripeSub.Items == nil || len(stripeSub.Items.Data) == 0 {
+		return nil, errors.New("stripe subscription has no items")
+	}
+
+	for _, item := range stripeSub.Items.Data {
+		if item.Price != nil && item.Price.ID == priceID {
+			return item, nil
+		}
+	}
+
+	return stripeSub.Items.Data[0], nil
+}
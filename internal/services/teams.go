@@ -1,23 +1,36 @@
 package services
 
 import (
+	"HareID/config"
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
 	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/payments"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
-	"errors"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TeamServices struct {
-	repo repository.Repository
-	db   *pgxpool.Pool
+	repo         repository.Repository
+	db           *pgxpool.Pool
+	cfg          config.Config
+	entitlements *EntitlementServices
 }
 
 func (s *TeamServices) Create(ctx context.Context, requestUserID uint64, team models.Team) (models.Team, models.TeamMember, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.Create")
+	defer span.End()
 
-	tx, err := s.db.Begin(ctx)
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return models.Team{}, models.TeamMember{}, err
 	}
@@ -29,6 +42,10 @@ func (s *TeamServices) Create(ctx context.Context, requestUserID uint64, team mo
 		return models.Team{}, models.TeamMember{}, err
 	}
 
+	if err := s.entitlements.CanCreateTeam(ctx, tx, requestUserID); err != nil {
+		return models.Team{}, models.TeamMember{}, err
+	}
+
 	team, err = s.repo.Teams.Create(ctx, tx, team)
 	if err != nil {
 		return models.Team{}, models.TeamMember{}, err
@@ -45,34 +62,93 @@ func (s *TeamServices) Create(ctx context.Context, requestUserID uint64, team mo
 		return models.Team{}, models.TeamMember{}, err
 	}
 
+	if err := s.startTrial(ctx, tx, team); err != nil {
+		return models.Team{}, models.TeamMember{}, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_TEAM_CREATED,
+		TargetType: models.AUDIT_TARGET_TEAM,
+		TargetID:   auditID(team.ID),
+		TeamID:     &team.ID,
+		After:      audit.Snapshot(teamAuditState(team)),
+	}); err != nil {
+		return models.Team{}, models.TeamMember{}, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return models.Team{}, models.TeamMember{}, err
 	}
 
+	s.entitlements.Invalidate()
+
 	return team, models.TeamMember{}, nil
 }
 
-func (s *TeamServices) GetAll(ctx context.Context) ([]models.Team, error) {
+// Dá à nova equipe um período de teste sem cartão, apenas se o dono nunca teve assinatura
+func (s *TeamServices) startTrial(ctx context.Context, tx pgx.Tx, team models.Team) error {
 
-	tx, err := s.db.Begin(ctx)
-	if err != nil {
-		return nil, err
+	if s.cfg.Billing.TrialDays <= 0 || s.cfg.Billing.TrialPriceID == "" {
+		return nil
 	}
-	defer tx.Rollback(ctx)
 
-	teams, err := s.repo.Teams.GetAll(ctx)
+	previous, err := s.repo.Subscriptions.CountByUserID(ctx, team.OwnerID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if previous > 0 {
+		return nil
 	}
 
-	if len(teams) < 1 {
-		return nil, errors.New("no team found")
+	trialStartsAt := time.Now()
+	trialEndsAt := trialStartsAt.AddDate(0, 0, s.cfg.Billing.TrialDays)
+
+	trial, err := s.repo.Subscriptions.Create(ctx, tx, models.Subscription{
+		UserID:             team.OwnerID,
+		TeamID:             &team.ID,
+		SubscriptionID:     fmt.Sprintf("trial_team_%d", team.ID),
+		Provider:           payments.LOCAL,
+		PriceID:            s.cfg.Billing.TrialPriceID,
+		Quantity:           1,
+		Status:             subscription.TRIALING,
+		CurrentPeriodStart: trialStartsAt,
+		CurrentPeriodEnd:   trialEndsAt,
+	})
+	if err != nil {
+		return err
 	}
 
-	return teams, nil
+	_, err = s.repo.BillingHistory.Create(ctx, tx, models.BillingHistory{
+		SubscriptionID: trial.SubscriptionID,
+		UserID:         team.OwnerID,
+		TeamID:         &team.ID,
+		Event:          enums.TRIAL_STARTED,
+		Detail:         fmt.Sprintf("trial ends at %s", trialEndsAt.Format(time.RFC3339)),
+	}, "trial_started")
+
+	return err
+}
+
+func (s *TeamServices) GetAll(ctx context.Context, params listquery.Params) (listquery.Page[models.Team], error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.GetAll")
+	defer span.End()
+
+	return s.repo.Teams.GetAll(ctx, params)
+}
+
+// Equipes das quais o usuário é membro, incluindo as que ele é dono
+func (s *TeamServices) GetAllByMemberID(ctx context.Context, userID uint64, params listquery.Params) (listquery.Page[models.Team], error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.GetAllByMemberID")
+	defer span.End()
+
+	return s.repo.Teams.GetAllByMemberID(ctx, userID, params)
 }
 
 func (ts *TeamServices) GetByID(ctx context.Context, teamID uint64) (models.Team, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.GetByID")
+	defer span.End()
 
 	tx, err := ts.db.Begin(ctx)
 	if err != nil {
@@ -89,6 +165,9 @@ func (ts *TeamServices) GetByID(ctx context.Context, teamID uint64) (models.Team
 }
 
 func (ts *TeamServices) GetByOwnerID(ctx context.Context, userID uint64) (models.Team, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.GetByOwnerID")
+	defer span.End()
+
 	tx, err := ts.db.Begin(ctx)
 	if err != nil {
 		return models.Team{}, err
@@ -104,8 +183,10 @@ func (ts *TeamServices) GetByOwnerID(ctx context.Context, userID uint64) (models
 }
 
 func (ts *TeamServices) Update(ctx context.Context, teamID, requestUserID uint64, team models.Team) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.Update")
+	defer span.End()
 
-	tx, err := ts.db.Begin(ctx)
+	tx, err := beginAudited(ctx, ts.db, ts.repo)
 	if err != nil {
 		return 0, err
 	}
@@ -115,11 +196,35 @@ func (ts *TeamServices) Update(ctx context.Context, teamID, requestUserID uint64
 		return 0, err
 	}
 
+	if err := ts.entitlements.CanModifyTeam(ctx, teamID); err != nil {
+		return 0, err
+	}
+
+	before, err := ts.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return 0, err
+	}
+
 	affectedRows, err := ts.repo.Teams.Update(ctx, tx, teamID, team)
 	if err != nil {
 		return 0, err
 	}
 
+	entry, err := auditDiff(models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_TEAM_UPDATED,
+		TargetType: models.AUDIT_TARGET_TEAM,
+		TargetID:   auditID(teamID),
+		TeamID:     &teamID,
+	}, teamAuditState(before), teamAuditState(team))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordAudit(ctx, ts.repo, tx, entry); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return 0, err
 	}
@@ -128,8 +233,10 @@ func (ts *TeamServices) Update(ctx context.Context, teamID, requestUserID uint64
 }
 
 func (ts *TeamServices) Delete(ctx context.Context, teamID, requestUserID uint64) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.Delete")
+	defer span.End()
 
-	tx, err := ts.db.Begin(ctx)
+	tx, err := beginAudited(ctx, ts.db, ts.repo)
 	if err != nil {
 		return 0, err
 	}
@@ -139,11 +246,20 @@ func (ts *TeamServices) Delete(ctx context.Context, teamID, requestUserID uint64
 		return 0, err
 	}
 
+	team, err := ts.repo.Teams.GetByID(ctx, teamID)
+	if err != nil {
+		return 0, err
+	}
+
 	affectedRows, err := ts.repo.Teams.Delete(ctx, tx, teamID)
 	if err != nil {
 		return 0, err
 	}
 
+	if err := recordAudit(ctx, ts.repo, tx, teamDeletedAudit(&requestUserID, team)); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return 0, err
 	}
@@ -152,6 +268,8 @@ func (ts *TeamServices) Delete(ctx context.Context, teamID, requestUserID uint64
 }
 
 func (ts *TeamServices) GetOwnerID(ctx context.Context, teamID uint64) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "TeamServices.GetOwnerID")
+	defer span.End()
 
 	tx, err := ts.db.Begin(ctx)
 	if err != nil {
@@ -168,6 +286,8 @@ func (ts *TeamServices) GetOwnerID(ctx context.Context, teamID uint64) (uint64,
 }
 
 func (ts *TeamServices) CompareUserIDWithTeamOwnerID(ctx context.Context, userID, teamID uint64) error {
+	ctx, span := tracing.Start(ctx, "TeamServices.CompareUserIDWithTeamOwnerID")
+	defer span.End()
 
 	team, err := ts.repo.Teams.GetByID(ctx, teamID)
 	if err != nil {
@@ -175,8 +295,27 @@ func (ts *TeamServices) CompareUserIDWithTeamOwnerID(ctx context.Context, userID
 	}
 
 	if team.OwnerID != userID {
-		return errors.New("only the owner can delete the team")
+		return apperrors.Forbidden("not_team_owner", "only the owner can delete the team")
 	}
 
 	return nil
 }
+
+// Campos da equipe registrados na auditoria
+func teamAuditState(team models.Team) map[string]any {
+	return map[string]any{
+		"name":   team.Name,
+		"domain": team.Domain,
+	}
+}
+
+func teamDeletedAudit(actorID *uint64, team models.Team) models.AuditLog {
+	return models.AuditLog{
+		ActorID:    actorID,
+		Action:     models.AUDIT_TEAM_DELETED,
+		TargetType: models.AUDIT_TARGET_TEAM,
+		TargetID:   auditID(team.ID),
+		TeamID:     &team.ID,
+		Before:     audit.Snapshot(teamAuditState(team)),
+	}
+}
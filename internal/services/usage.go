@@ -0,0 +1,388 @@
+package services
+
+import (
+	"HareID/internal/apperrors"
+	"HareID/internal/enums"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/metrics"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/client"
+)
+
+const (
+	// Número de tentativas antes de o evento de uso ser marcado como falho
+	usageMaxAttempts = 8
+	// Tempo em que um evento reservado fica invisível para outros workers
+	usageLease = 5 * time.Minute
+	// Metadado do preço medido no Stripe que indica o medidor cobrado
+	usageMeterMetadata = "meter"
+)
+
+type UsageServices struct {
+	repo         repository.Repository
+	db           *pgxpool.Pool
+	stripe       *client.API
+	entitlements *EntitlementServices
+}
+
+// Grava os eventos de uso. Eventos com chave de idempotência repetida são contados como duplicados
+func (s *UsageServices) Record(ctx context.Context, events []models.UsageEvent) (int, int, error) {
+	ctx, span := tracing.Start(ctx, "UsageServices.Record")
+	defer span.End()
+
+	if len(events) == 0 {
+		return 0, 0, apperrors.Field("events", "is required")
+	}
+
+	teams := make(map[uint64]bool)
+
+	for i := range events {
+		if err := validateUsageEvent(&events[i]); err != nil {
+			return 0, 0, fmt.Errorf("event %d: %w", i, err)
+		}
+
+		if teams[events[i].TeamID] {
+			continue
+		}
+		if _, err := s.repo.Teams.GetByID(ctx, events[i].TeamID); err != nil {
+			return 0, 0, fmt.Errorf("event %d: %w", i, err)
+		}
+		teams[events[i].TeamID] = true
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	accepted := 0
+
+	for _, event := range events {
+		created, err := s.repo.Usage.Create(ctx, tx, event)
+		if err != nil {
+			return 0, 0, err
+		}
+		if created {
+			accepted++
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	return accepted, len(events) - accepted, nil
+}
+
+// Envia ao Stripe o consumo pendente, somado por equipe e medidor. Retorna quantos eventos foram reservados
+func (s *UsageServices) ReportPending(ctx context.Context, batchSize int) (int, error) {
+	ctx, span := tracing.Start(ctx, "UsageServices.ReportPending")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	events, err := s.repo.Usage.ClaimDue(ctx, tx, batchSize, usageLease)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	for _, batch := range groupUsage(events) {
+		status, expired, reportErr := s.report(ctx, batch)
+
+		if len(expired) > 0 {
+			if err := s.finish(ctx, expired, enums.USAGE_EXPIRED, nil); err != nil {
+				return len(events), err
+			}
+			batch = slices.DeleteFunc(batch, func(event models.UsageEvent) bool {
+				return slices.ContainsFunc(expired, func(e models.UsageEvent) bool { return e.ID == event.ID })
+			})
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := s.finish(ctx, batch, status, reportErr); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// Consumo da equipe no período de cobrança atual comparado aos limites do plano
+func (s *UsageServices) GetTeamSummary(ctx context.Context, requestUserID, teamID uint64) (models.UsageSummary, error) {
+	ctx, span := tracing.Start(ctx, "UsageServices.GetTeamSummary")
+	defer span.End()
+
+	member, err := s.repo.TeamMembers.Exists(ctx, teamID, requestUserID)
+	if err != nil {
+		return models.UsageSummary{}, err
+	}
+	if !member {
+		return models.UsageSummary{}, ErrNotTeamMember
+	}
+
+	entitlement, err := s.entitlements.GetByTeamID(ctx, teamID)
+	if err != nil {
+		return models.UsageSummary{}, err
+	}
+
+	start, end := s.currentPeriod(ctx, teamID)
+
+	totals, err := s.repo.Usage.SumByTeam(ctx, teamID, start, end)
+	if err != nil {
+		return models.UsageSummary{}, err
+	}
+
+	meters := make([]string, 0, len(totals)+len(entitlement.Plan.MeterLimits))
+	for meter := range totals {
+		meters = append(meters, meter)
+	}
+	for meter := range entitlement.Plan.MeterLimits {
+		if _, ok := totals[meter]; !ok {
+			meters = append(meters, meter)
+		}
+	}
+	slices.Sort(meters)
+
+	summary := models.UsageSummary{
+		TeamID:      teamID,
+		Plan:        entitlement.Plan.Name,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Meters:      make([]models.MeterUsage, 0, len(meters)),
+	}
+
+	for _, meter := range meters {
+		usage := models.MeterUsage{Meter: meter, Quantity: totals[meter]}
+		if limit, ok := entitlement.Plan.MeterLimits[meter]; ok {
+			usage.Limit = &limit
+		}
+		summary.Meters = append(summary.Meters, usage)
+	}
+
+	return summary, nil
+}
+
+// Período pago da assinatura da equipe, de qualquer intervalo; sem assinatura, o mês corrente
+func (s *UsageServices) currentPeriod(ctx context.Context, teamID uint64) (time.Time, time.Time) {
+
+	if sub, err := s.repo.Subscriptions.GetCurrentByTeamID(ctx, teamID); err == nil && !sub.CurrentPeriodStart.IsZero() && !sub.CurrentPeriodEnd.IsZero() {
+		return sub.CurrentPeriodStart, sub.CurrentPeriodEnd
+	}
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Envia um lote ao item medido da assinatura da equipe. Sem item medido o lote é ignorado. O Stripe só aceita
+// registros no período atual: eventos de um período já fechado são devolvidos em expired e não são enviados
+func (s *UsageServices) report(ctx context.Context, batch []models.UsageEvent) (enums.UsageStatus, []models.UsageEvent, error) {
+
+	first := batch[0]
+
+	sub, err := s.repo.Subscriptions.GetCurrentByTeamID(ctx, first.TeamID)
+	if err != nil || sub.Provider != payments.STRIPE || sub.Status == subscription.CANCELED {
+		return enums.USAGE_SKIPPED, nil, nil
+	}
+
+	stripeSub, err := s.stripe.Subscriptions.Get(sub.SubscriptionID, &stripe.SubscriptionParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return enums.USAGE_PENDING, nil, err
+	}
+
+	item := meteredItem(stripeSub, first.Meter)
+	if item == nil {
+		return enums.USAGE_SKIPPED, nil, nil
+	}
+
+	current, expired := splitByPeriod(batch, time.Unix(stripeSub.CurrentPeriodStart, 0))
+	if len(expired) > 0 {
+		slog.WarnContext(ctx, "dropping usage from a closed billing period", "team_id", first.TeamID, "meter", first.Meter, "events", len(expired))
+		metrics.UsageDropped(first.Meter, "closed_period", len(expired))
+	}
+
+	if len(current) == 0 {
+		return enums.USAGE_EXPIRED, expired, nil
+	}
+
+	var quantity int64
+	var ids []uint64
+	var timestamp int64
+
+	for _, event := range current {
+		quantity += event.Quantity
+		ids = append(ids, event.ID)
+		timestamp = max(timestamp, event.OccurredAt.Unix())
+	}
+
+	// Nunca no futuro: o relógio do cliente pode estar alguns minutos adiantado
+	if now := time.Now().Unix(); timestamp > now {
+		timestamp = now
+	}
+
+	params := &stripe.UsageRecordParams{
+		SubscriptionItem: stripe.String(item.ID),
+		Action:           stripe.String("increment"),
+		Quantity:         stripe.Int64(quantity),
+		Timestamp:        stripe.Int64(timestamp),
+	}
+	params.Context = ctx
+	// Um lote reenviado após falha não é contado duas vezes
+	params.SetIdempotencyKey(usageIdempotencyKey(ids))
+
+	if _, err := s.stripe.UsageRecords.New(params); err != nil {
+		return enums.USAGE_PENDING, expired, err
+	}
+
+	return enums.USAGE_REPORTED, expired, nil
+}
+
+// Registra o resultado do envio, agendando nova tentativa com backoff exponencial
+func (s *UsageServices) finish(ctx context.Context, batch []models.UsageEvent, status enums.UsageStatus, reportErr error) error {
+
+	ids := make([]uint64, len(batch))
+	attempts := 0
+	for i, event := range batch {
+		ids[i] = event.ID
+		attempts = max(attempts, event.Attempts)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	switch {
+	case reportErr == nil:
+		err = s.repo.Usage.SetStatus(ctx, tx, ids, status, "")
+	case attempts >= usageMaxAttempts:
+		slog.ErrorContext(ctx, "usage report failed permanently", "team_id", batch[0].TeamID, "meter", batch[0].Meter, "error", reportErr)
+		err = s.repo.Usage.SetStatus(ctx, tx, ids, enums.USAGE_FAILED, reportErr.Error())
+	default:
+		slog.WarnContext(ctx, "usage report failed", "team_id", batch[0].TeamID, "meter", batch[0].Meter, "attempt", attempts, "error", reportErr)
+		err = s.repo.Usage.MarkRetry(ctx, tx, ids, reportErr.Error(), time.Now().Add(webhookBackoff(attempts)))
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func validateUsageEvent(event *models.UsageEvent) error {
+	if event.TeamID == 0 {
+		return apperrors.Field("team_id", "is required")
+	}
+	if event.Meter == "" {
+		return apperrors.Field("meter", "is required")
+	}
+	if event.Quantity <= 0 {
+		return apperrors.Field("quantity", "must be positive")
+	}
+	if event.IdempotencyKey == "" {
+		return apperrors.Field("idempotency_key", "is required")
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.OccurredAt.After(time.Now().Add(5 * time.Minute)) {
+		return apperrors.Field("occurred_at", "cannot be in the future")
+	}
+	return nil
+}
+
+// Separa os eventos do período atual dos que ocorreram antes de periodStart
+func splitByPeriod(events []models.UsageEvent, periodStart time.Time) ([]models.UsageEvent, []models.UsageEvent) {
+	var current, expired []models.UsageEvent
+	for _, event := range events {
+		if event.OccurredAt.Before(periodStart) {
+			expired = append(expired, event)
+		} else {
+			current = append(current, event)
+		}
+	}
+	return current, expired
+}
+
+// Agrupa os eventos por equipe e medidor, mantendo a ordem de chegada
+func groupUsage(events []models.UsageEvent) [][]models.UsageEvent {
+
+	type usageKey struct {
+		teamID uint64
+		meter  string
+	}
+
+	index := make(map[usageKey]int)
+	var batches [][]models.UsageEvent
+
+	for _, event := range events {
+		key := usageKey{event.TeamID, event.Meter}
+		i, ok := index[key]
+		if !ok {
+			i = len(batches)
+			index[key] = i
+			batches = append(batches, nil)
+		}
+		batches[i] = append(batches[i], event)
+	}
+
+	return batches
+}
+
+// Item de preço medido cujo metadado "meter" corresponde ao medidor
+func meteredItem(stripeSub *stripe.Subscription, meter string) *stripe.SubscriptionItem {
+	if stripeSub.Items == nil {
+		return nil
+	}
+
+	for _, item := range stripeSub.Items.Data {
+		price := item.Price
+		if price == nil || price.Recurring == nil || price.Recurring.UsageType != stripe.PriceRecurringUsageTypeMetered {
+			continue
+		}
+		if price.Metadata[usageMeterMetadata] == meter {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func usageIdempotencyKey(ids []uint64) string {
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+
+	hash := sha256.New()
+	for _, id := range sorted {
+		hash.Write([]byte(strconv.FormatUint(id, 10) + ","))
+	}
+
+	return "usage_" + hex.EncodeToString(hash.Sum(nil))[:32]
+}
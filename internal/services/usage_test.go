@@ -0,0 +1,52 @@
+package services
+
+import (
+	"HareID/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitByPeriod(t *testing.T) {
+	periodStart := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	event := func(id uint64, occurredAt time.Time) models.UsageEvent {
+		return models.UsageEvent{ID: id, OccurredAt: occurredAt}
+	}
+
+	cases := []struct {
+		name        string
+		events      []models.UsageEvent
+		wantCurrent []uint64
+		wantExpired []uint64
+	}{
+		{"all in the current period", []models.UsageEvent{event(1, periodStart), event(2, periodStart.Add(time.Hour))}, []uint64{1, 2}, nil},
+		{
+			// Evento enviado depois da renovação não pode ser empurrado para o período novo
+			name:        "event from the closed period",
+			events:      []models.UsageEvent{event(1, periodStart.Add(-time.Minute)), event(2, periodStart.Add(time.Hour))},
+			wantCurrent: []uint64{2},
+			wantExpired: []uint64{1},
+		},
+		{"only closed period", []models.UsageEvent{event(1, periodStart.AddDate(0, -1, 0))}, nil, []uint64{1}},
+	}
+
+	ids := func(events []models.UsageEvent) []uint64 {
+		var out []uint64
+		for _, event := range events {
+			out = append(out, event.ID)
+		}
+		return out
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			current, expired := splitByPeriod(tc.events, periodStart)
+			if got := ids(current); !slices.Equal(got, tc.wantCurrent) {
+				t.Errorf("current = %v, want %v", got, tc.wantCurrent)
+			}
+			if got := ids(expired); !slices.Equal(got, tc.wantExpired) {
+				t.Errorf("expired = %v, want %v", got, tc.wantExpired)
+			}
+		})
+	}
+}
@@ -1,21 +1,39 @@
 package services
 
 import (
+	"HareID/config"
+	"HareID/internal/apperrors"
+	"HareID/internal/audit"
+	"HareID/internal/listquery"
 	"HareID/internal/models"
+	"HareID/internal/pii"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"HareID/internal/validators"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type UserServices struct {
 	repo repository.Repository
 	db   *pgxpool.Pool
+	cfg  config.Config
+	val  validators.Validations
 }
 
-func (s *UserServices) Create(ctx context.Context, user models.User) (models.User, error) {
-	tx, err := s.db.Begin(ctx)
+func (s *UserServices) Create(ctx context.Context, user models.User, source models.ConsentSource) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.Create")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -25,8 +43,27 @@ func (s *UserServices) Create(ctx context.Context, user models.User) (models.Use
 		return models.User{}, err
 	}
 
+	if user.CpfCnpj, err = s.val.Users.CpfCnpj(ctx, 0, user.CpfCnpj); err != nil {
+		return models.User{}, err
+	}
+
 	createdUser, err := s.repo.Users.Create(ctx, tx, user)
 	if err != nil {
+		return models.User{}, cpfCnpjConflict(err)
+	}
+
+	if err := recordSignupConsents(ctx, s.repo, tx, createdUser, source); err != nil {
+		return models.User{}, err
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		ActorID:    &createdUser.ID,
+		Action:     models.AUDIT_USER_CREATED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(createdUser.ID),
+		UserID:     &createdUser.ID,
+		After:      audit.Snapshot(userAuditState(createdUser)),
+	}); err != nil {
 		return models.User{}, err
 	}
 
@@ -34,29 +71,68 @@ func (s *UserServices) Create(ctx context.Context, user models.User) (models.Use
 		return models.User{}, err
 	}
 
+	s.presentPII(createdUser.ID, &createdUser)
+
 	return createdUser, nil
 }
 
-func (s *UserServices) GetAll(ctx context.Context) ([]models.User, error) {
-	users, err := s.repo.Users.GetAll(ctx)
+func (s *UserServices) GetAll(ctx context.Context, requestUserID uint64, params listquery.Params) (listquery.Page[models.User], error) {
+	ctx, span := tracing.Start(ctx, "UserServices.GetAll")
+	defer span.End()
+
+	users, err := s.repo.Users.GetAll(ctx, params)
 	if err != nil {
-		return nil, err
+		return listquery.Page[models.User]{}, err
+	}
+
+	for i := range users.Items {
+		s.presentPII(requestUserID, &users.Items[i])
 	}
 
 	return users, nil
 }
 
-func (s *UserServices) GetByID(ctx context.Context, userID uint64) (models.User, error) {
+func (s *UserServices) GetByID(ctx context.Context, requestUserID, userID uint64) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.GetByID")
+	defer span.End()
 
 	user, err := s.repo.Users.GetByID(ctx, userID)
 	if err != nil {
 		return models.User{}, err
 	}
 
+	s.presentPII(requestUserID, &user)
+
+	// O cliente no provedor de pagamento só interessa ao próprio usuário
+	if requestUserID != user.ID {
+		user.StripeCustomerID = ""
+	}
+
+	return user, nil
+}
+
+// Busca exata pelo CPF/CNPJ, pelo índice cego
+func (s *UserServices) GetByCpfCnpj(ctx context.Context, requestUserID uint64, cpfCnpj string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.GetByCpfCnpj")
+	defer span.End()
+
+	if pii.Normalize(cpfCnpj) == "" {
+		return models.User{}, apperrors.Field("cpf_cnpj", "is required")
+	}
+
+	user, err := s.repo.Users.GetByCpfCnpj(ctx, cpfCnpj)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.presentPII(requestUserID, &user)
+
 	return user, nil
 }
 
 func (s *UserServices) GetByStripeCustomerID(ctx context.Context, stripeCustomerID string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.GetByStripeCustomerID")
+	defer span.End()
 
 	user, err := s.repo.Users.GetByStripeCustomerID(ctx, stripeCustomerID)
 	if err != nil {
@@ -67,14 +143,17 @@ func (s *UserServices) GetByStripeCustomerID(ctx context.Context, stripeCustomer
 }
 
 func (s *UserServices) Update(ctx context.Context, userID, requestUserID uint64, user models.User) (uint64, error) {
-	tx, err := s.db.Begin(ctx)
+	ctx, span := tracing.Start(ctx, "UserServices.Update")
+	defer span.End()
+
+	tx, err := beginAudited(ctx, s.db, s.repo)
 	if err != nil {
 		return 0, err
 	}
 
 	if userID != requestUserID {
 		tx.Rollback(ctx)
-		return 0, errors.New("Only the owner can update the user")
+		return 0, ErrNotDataOwner
 	}
 
 	if err := user.ValidateUser("update"); err != nil {
@@ -82,7 +161,36 @@ func (s *UserServices) Update(ctx context.Context, userID, requestUserID uint64,
 		return 0, err
 	}
 
+	if user.CpfCnpj, err = s.val.Users.CpfCnpj(ctx, userID, user.CpfCnpj); err != nil {
+		tx.Rollback(ctx)
+		return 0, err
+	}
+
+	before, err := s.repo.Users.GetByID(ctx, userID)
+	if err != nil {
+		tx.Rollback(ctx)
+		return 0, err
+	}
+
+	// Não é gravado pelo Update; mantém a auditoria fiel ao que ficou no banco
+	user.StripeCustomerID = before.StripeCustomerID
+
 	affectedRows, err := s.repo.Users.Update(ctx, tx, userID, user)
+	if err != nil {
+		tx.Rollback(ctx)
+		return 0, cpfCnpjConflict(err)
+	}
+
+	entry, err := auditDiff(models.AuditLog{
+		ActorID:    &requestUserID,
+		Action:     models.AUDIT_USER_UPDATED,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+	}, userAuditState(before), userAuditState(user))
+	if err == nil {
+		err = recordAudit(ctx, s.repo, tx, entry)
+	}
 	if err != nil {
 		tx.Rollback(ctx)
 		return 0, err
@@ -96,27 +204,199 @@ func (s *UserServices) Update(ctx context.Context, userID, requestUserID uint64,
 	return affectedRows, nil
 }
 
+// Usado pelo middleware das rotas administrativas
+func (s *UserServices) IsAdmin(ctx context.Context, userID uint64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.IsAdmin")
+	defer span.End()
 
-func (s *UserServices) Delete(ctx context.Context, userID, requestUserID uint64) (uint64, error) {
-	tx, err := s.db.Begin(ctx)
+	return s.repo.Users.IsAdmin(ctx, userID)
+}
+
+// Libera o dado do próprio usuário (ownerID) ou de quem é administrador da plataforma
+func requireOwnerOrAdmin(ctx context.Context, repo repository.Repository, requestUserID, ownerID uint64) error {
+	if requestUserID == ownerID {
+		return nil
+	}
+
+	isAdmin, err := repo.Users.IsAdmin(ctx, requestUserID)
 	if err != nil {
-		return 0, nil
+		return err
 	}
-	defer tx.Rollback(ctx)
 
-	if userID != requestUserID {
-		return 0, errors.New("Only the owner can delete the user")
+	if !isAdmin {
+		return ErrNotDataOwner
 	}
 
-	affectedRows, err := s.repo.Users.Delete(ctx, tx, userID)
+	return nil
+}
+
+// Concede ou revoga o acesso de administrador da plataforma. O administrador não pode revogar o próprio
+// acesso, o que evita ficar sem nenhum
+func (s *UserServices) SetAdmin(ctx context.Context, requestUserID, userID uint64, isAdmin bool) (uint64, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.SetAdmin")
+	defer span.End()
+
+	if userID == requestUserID && !isAdmin {
+		return 0, ErrOwnAdminRevoke
+	}
+
+	wasAdmin, err := s.repo.Users.IsAdmin(ctx, userID)
 	if err != nil {
 		return 0, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
+	return s.setAdmin(ctx, &requestUserID, userID, wasAdmin, isAdmin)
+}
+
+// Promove os usuários de ADMIN_USER_IDS ao subir a API. IDs sem cadastro são ignorados com um aviso.
+// Revogar um deles só vale depois de retirá-lo da variável
+func (s *UserServices) PromoteAdmins(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "UserServices.PromoteAdmins")
+	defer span.End()
+
+	for _, id := range s.cfg.Auth.AdminUserIDs {
+		userID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ADMIN_USER_IDS: invalid user id %q", id)
+		}
+
+		isAdmin, err := s.repo.Users.IsAdmin(ctx, userID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			slog.WarnContext(ctx, "admin user not found", "user_id", userID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if isAdmin {
+			continue
+		}
+
+		if _, err := s.setAdmin(ctx, nil, userID, false, true); err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "user promoted to platform admin", "user_id", userID)
+	}
+
+	return nil
+}
+
+// Sem actorID a alteração fica na auditoria como feita pelo sistema
+func (s *UserServices) setAdmin(ctx context.Context, actorID *uint64, userID uint64, wasAdmin, isAdmin bool) (uint64, error) {
+	tx, err := beginAudited(ctx, s.db, s.repo)
+	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback(ctx)
 
-	return affectedRows, nil
+	affectedRows, err := s.repo.Users.SetAdmin(ctx, tx, userID, isAdmin)
+	if err != nil {
+		return 0, err
+	}
+
+	action := models.AUDIT_ADMIN_GRANTED
+	if !isAdmin {
+		action = models.AUDIT_ADMIN_REVOKED
+	}
+
+	if err := recordAudit(ctx, s.repo, tx, models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: models.AUDIT_TARGET_USER,
+		TargetID:   auditID(userID),
+		UserID:     &userID,
+		Before:     audit.Snapshot(map[string]bool{"is_admin": wasAdmin}),
+		After:      audit.Snapshot(map[string]bool{"is_admin": isAdmin}),
+	}); err != nil {
+		return 0, err
+	}
+
+	return affectedRows, tx.Commit(ctx)
+}
+
+// Passa para a chave mestra ativa um lote de CPF/CNPJ com id maior que afterID.
+// Registros que falham são registrados no log e ficam para a próxima execução do job
+func (s *UserServices) RotateEncryptionKeys(ctx context.Context, afterID uint64, batchSize int) (models.KeyRotationBatch, error) {
+	ctx, span := tracing.Start(ctx, "UserServices.RotateEncryptionKeys")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.KeyRotationBatch{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch, err := s.repo.Users.RotateCpfCnpj(ctx, tx, afterID, batchSize)
+	if err != nil {
+		return models.KeyRotationBatch{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.KeyRotationBatch{}, err
+	}
+
+	for userID, err := range batch.Failed {
+		slog.WarnContext(ctx, "skipping pii record that could not be rotated", "user_id", userID, "error", err)
+	}
+
+	return batch, nil
+}
+
+// Formata o CPF/CNPJ gravado só com dígitos. O próprio usuário e quem está em PII_READER_USER_IDS
+// veem o documento completo; os demais, mascarado
+func (s *UserServices) presentPII(requestUserID uint64, user *models.User) {
+	if user.CpfCnpj == "" {
+		return
+	}
+
+	user.CpfCnpjType = validators.DocumentTypeOf(user.CpfCnpj)
+
+	if requestUserID == user.ID || slices.Contains(s.cfg.Privacy.PIIReaderUserIDs, strconv.FormatUint(requestUserID, 10)) {
+		user.CpfCnpj = validators.FormatDocument(user.CpfCnpj)
+		return
+	}
+
+	user.CpfCnpj = pii.MaskCpfCnpj(user.CpfCnpj)
+}
+
+// Campos do usuário registrados na auditoria. A trilha é imutável e sobrevive à exclusão da conta, então
+// guarda apenas identificadores e indicadores: nem o nome nem o documento, mesmo mascarado
+func userAuditState(user models.User) map[string]string {
+	return map[string]string{
+		"has_name":           strconv.FormatBool(user.Name != ""),
+		"cpf_cnpj_type":      strconv.Itoa(int(validators.DocumentTypeOf(user.CpfCnpj))),
+		"stripe_customer_id": user.StripeCustomerID,
+	}
+}
+
+// A restrição única do índice cego cobre duas contas gravando o mesmo documento ao mesmo tempo
+func cpfCnpjConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_cpf_cnpj_index_key" {
+		return validators.FieldErrors{"cpf_cnpj": "already registered to another account"}
+	}
+	return err
+}
+
+// Recusa tokens emitidos antes da última revogação de sessões do usuário (ex: conta excluída)
+func (s *UserServices) ValidateSession(ctx context.Context, userID uint64, issuedAt time.Time) error {
+	ctx, span := tracing.Start(ctx, "UserServices.ValidateSession")
+	defer span.End()
+
+	revokedAt, err := s.repo.Users.GetSessionsRevokedAt(ctx, userID)
+	if err != nil {
+		// Conta excluída: o token deixa de valer mesmo que a revogação não tenha sido registrada
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+
+	if revokedAt != nil && issuedAt.Before(*revokedAt) {
+		return ErrSessionRevoked
+	}
 
+	return nil
 }
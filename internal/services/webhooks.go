@@ -0,0 +1,615 @@
+package services
+
+import (
+	"HareID/internal/enums"
+	"HareID/internal/enums/invoice"
+	"HareID/internal/enums/subscription"
+	"HareID/internal/listquery"
+	"HareID/internal/metrics"
+	"HareID/internal/models"
+	"HareID/internal/payments"
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v79"
+)
+
+const (
+	// Número de tentativas antes de o evento ser marcado como falho
+	webhookMaxAttempts = 10
+	// Tempo em que um evento reservado fica invisível para outros workers
+	webhookLease = 5 * time.Minute
+)
+
+type WebhookServices struct {
+	repo          repository.Repository
+	db            *pgxpool.Pool
+	subscriptions *SubscriptionServices
+	entitlements  *EntitlementServices
+	providers     payments.Registry
+}
+
+// Verifica e guarda o evento recebido do provedor. Retorna false quando é uma entrega repetida
+func (s *WebhookServices) Receive(ctx context.Context, providerName string, payload []byte, header http.Header) (bool, error) {
+	ctx, span := tracing.Start(ctx, "WebhookServices.Receive")
+	defer span.End()
+
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return false, err
+	}
+
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		metrics.WebhookEvent(provider.Name(), "unknown", "rejected")
+		return false, err
+	}
+
+	stored := models.StripeEvent{
+		ID:              event.ID,
+		Provider:        provider.Name(),
+		Type:            event.Type,
+		ObjectID:        event.ObjectID,
+		Payload:         event.Payload,
+		StripeCreatedAt: event.CreatedAt,
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := s.repo.StripeEvents.Create(ctx, tx, stored)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	if created {
+		metrics.WebhookEvent(stored.Provider, stored.Type, "received")
+	} else {
+		metrics.WebhookEvent(stored.Provider, stored.Type, "duplicate")
+	}
+
+	return created, nil
+}
+
+// Processa um lote de eventos pendentes. Retorna quantos eventos foram tratados
+func (s *WebhookServices) ProcessPending(ctx context.Context, batchSize int) (int, error) {
+	ctx, span := tracing.Start(ctx, "WebhookServices.ProcessPending")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	events, err := s.repo.StripeEvents.ClaimDue(ctx, tx, batchSize, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	// Uma falha ao gravar o resultado não segura o restante do lote. O evento volta à fila quando o lease vencer
+	for _, event := range events {
+		if err := s.finish(ctx, event, s.process(ctx, event)); err != nil {
+			slog.ErrorContext(ctx, "error finishing webhook event", "event_id", event.ID, "error", err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func (s *WebhookServices) GetAll(ctx context.Context, status enums.WebhookEventStatus, params listquery.Params) (listquery.Page[models.StripeEvent], error) {
+	ctx, span := tracing.Start(ctx, "WebhookServices.GetAll")
+	defer span.End()
+
+	return s.repo.StripeEvents.GetAll(ctx, status, params)
+}
+
+// Devolve o evento à fila com as tentativas zeradas. O processamento fica com o worker, que reivindica
+// o evento em ClaimDue, para nunca haver dois processamentos do mesmo evento ao mesmo tempo
+func (s *WebhookServices) Replay(ctx context.Context, eventID string) (models.StripeEvent, error) {
+	ctx, span := tracing.Start(ctx, "WebhookServices.Replay")
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.StripeEvent{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	reset, err := s.repo.StripeEvents.Reset(ctx, tx, eventID)
+	if err != nil {
+		return models.StripeEvent{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.StripeEvent{}, err
+	}
+
+	event, err := s.repo.StripeEvents.GetByID(ctx, eventID)
+	if err != nil {
+		return models.StripeEvent{}, err
+	}
+
+	if reset == 0 {
+		return models.StripeEvent{}, ErrWebhookEventQueued
+	}
+
+	return event, nil
+}
+
+// Registra o resultado do processamento, agendando nova tentativa com backoff exponencial
+func (s *WebhookServices) finish(ctx context.Context, event models.StripeEvent, processErr error) error {
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var outcome string
+
+	switch {
+	case processErr == nil:
+		// Eventos de cobrança podem mudar o plano de qualquer usuário em cache
+		s.entitlements.Invalidate()
+		outcome = "processed"
+		err = s.repo.StripeEvents.MarkProcessed(ctx, tx, event.ID)
+	case event.Attempts >= webhookMaxAttempts:
+		slog.ErrorContext(ctx, "stripe event failed permanently", "event_id", event.ID, "error", processErr)
+		outcome = "failed"
+		err = s.repo.StripeEvents.MarkFailed(ctx, tx, event.ID, processErr.Error())
+	default:
+		slog.WarnContext(ctx, "stripe event failed", "event_id", event.ID, "attempt", event.Attempts, "error", processErr)
+		outcome = "retry"
+		err = s.repo.StripeEvents.MarkRetry(ctx, tx, event.ID, processErr.Error(), time.Now().Add(webhookBackoff(event.Attempts)))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	metrics.WebhookEvent(event.Provider, event.Type, outcome)
+	return nil
+}
+
+func (s *WebhookServices) process(ctx context.Context, stored models.StripeEvent) error {
+
+	if stored.Provider == payments.ASAAS {
+		return s.processAsaas(ctx, stored)
+	}
+
+	return s.processStripe(ctx, stored)
+}
+
+// Eventos do Asaas chegam como cobranças (PAYMENT_*) ou assinaturas (SUBSCRIPTION_*)
+func (s *WebhookServices) processAsaas(ctx context.Context, stored models.StripeEvent) error {
+
+	event, err := payments.ParseAsaasEvent(stored.Payload)
+	if err != nil {
+		return err
+	}
+
+	subscriptionID := event.SubscriptionID()
+	if subscriptionID == "" {
+		// Cobrança avulsa, sem assinatura
+		return nil
+	}
+
+	provider, err := s.providers.Get(payments.ASAAS)
+	if err != nil {
+		return err
+	}
+
+	status, period, err := asaasStatusChange(provider, event)
+	if err != nil {
+		return err
+	}
+
+	if status == subscription.UNKNOWN {
+		return nil
+	}
+
+	// O registro local é criado no checkout; se ainda não existir o evento volta para a fila
+	if _, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, subscriptionID); err != nil {
+		return fmt.Errorf("subscription %s: %w", subscriptionID, err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	applied, err := s.repo.Subscriptions.UpdateStatus(ctx, tx, subscriptionID, status, period, stored.StripeCreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		slog.InfoContext(ctx, "skipping stale event", "subscription_id", subscriptionID)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Status aplicado por um evento do Asaas; UNKNOWN quando o evento não muda a assinatura.
+// Cobranças criadas ou aguardando pagamento são ignoradas: o registro já nasce INCOMPLETE no checkout
+// e a cobrança da renovação é gerada antes do vencimento, então não pode rebaixar uma assinatura em dia
+func asaasStatusChange(provider payments.PaymentProvider, event payments.AsaasEvent) (subscription.Subscription, *models.BillingPeriod, error) {
+
+	switch {
+	case event.Payment != nil:
+		status := provider.MapStatus(event.Payment.Status)
+		if status == subscription.INCOMPLETE {
+			return subscription.UNKNOWN, nil, nil
+		}
+
+		// Cobrança paga libera o mês seguinte ao vencimento
+		if status == subscription.ACTIVE {
+			dueDate, err := payments.ParseAsaasDate(event.Payment.DueDate)
+			if err != nil {
+				return subscription.UNKNOWN, nil, err
+			}
+			return status, &models.BillingPeriod{Start: dueDate, End: dueDate.AddDate(0, 1, 0)}, nil
+		}
+
+		return status, nil, nil
+
+	case event.Subscription != nil:
+		if event.Subscription.Deleted || event.Event == "SUBSCRIPTION_DELETED" {
+			return subscription.CANCELED, nil, nil
+		}
+		return provider.MapStatus(event.Subscription.Status), nil, nil
+	}
+
+	return subscription.UNKNOWN, nil, nil
+}
+
+func (s *WebhookServices) processStripe(ctx context.Context, stored models.StripeEvent) error {
+
+	var event stripe.Event
+	if err := json.Unmarshal(stored.Payload, &event); err != nil {
+		return err
+	}
+
+	if event.Data == nil {
+		return errors.New("event has no data")
+	}
+
+	eventAt := time.Unix(event.Created, 0)
+
+	switch event.Type {
+	case "checkout.session.completed":
+		var session stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
+			return err
+		}
+
+		return s.processCheckoutCompleted(ctx, session)
+
+	case "customer.subscription.updated", "customer.subscription.created", "customer.subscription.deleted":
+		var stripeSub stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &stripeSub); err != nil {
+			return err
+		}
+
+		return s.processSubscriptionEvent(ctx, stripeSub, eventAt)
+
+	case "customer.subscription.trial_will_end":
+		var stripeSub stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &stripeSub); err != nil {
+			return err
+		}
+
+		return s.processTrialWillEnd(ctx, stripeSub)
+
+	case "invoice.paid", "invoice.payment_failed":
+		var stripeInvoice stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &stripeInvoice); err != nil {
+			return err
+		}
+
+		return s.processInvoiceEvent(ctx, stripeInvoice, event.Type == "invoice.payment_failed", eventAt)
+
+	case "customer.deleted":
+		var customer stripe.Customer
+		if err := json.Unmarshal(event.Data.Raw, &customer); err != nil {
+			return err
+		}
+
+		return s.processCustomerDeleted(ctx, customer)
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return err
+		}
+
+		return s.processChargeRefunded(ctx, charge)
+	}
+
+	return nil
+}
+
+func (s *WebhookServices) processCheckoutCompleted(ctx context.Context, session stripe.CheckoutSession) error {
+
+	if session.ClientReferenceID == "" || session.Customer == nil {
+		return nil
+	}
+
+	userID, err := strconv.ParseUint(session.ClientReferenceID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid client_reference_id %q: %w", session.ClientReferenceID, err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Users.SetStripeCustomerID(ctx, tx, userID, session.Customer.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *WebhookServices) processSubscriptionEvent(ctx context.Context, stripeSub stripe.Subscription, eventAt time.Time) error {
+
+	sub, err := subscriptionFromStripe(ctx, s.repo, stripeSub)
+	if err != nil {
+		return err
+	}
+
+	return s.subscriptions.UpsertSubscription(ctx, sub, eventAt)
+}
+
+// Converte a assinatura do Stripe para o registro local
+func subscriptionFromStripe(ctx context.Context, repo repository.Repository, stripeSub stripe.Subscription) (models.Subscription, error) {
+
+	if stripeSub.Customer == nil {
+		return models.Subscription{}, errors.New("subscription has no customer")
+	}
+
+	// Se o cliente ainda não foi vinculado (checkout.session.completed pendente) o evento volta para a fila
+	user, err := repo.Users.GetByStripeCustomerID(ctx, stripeSub.Customer.ID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("customer %s: %w", stripeSub.Customer.ID, err)
+	}
+
+	item, err := planItem(ctx, repo, stripeSub)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	sub := models.Subscription{
+		UserID:             user.ID,
+		SubscriptionID:     stripeSub.ID,
+		Provider:           payments.STRIPE,
+		PriceID:            item.Price.ID,
+		Quantity:           item.Quantity,
+		Status:             payments.MapStripeStatus(string(stripeSub.Status)),
+		CurrentPeriodStart: time.Unix(stripeSub.CurrentPeriodStart, 0),
+		CurrentPeriodEnd:   time.Unix(stripeSub.CurrentPeriodEnd, 0),
+	}
+
+	// Assinaturas de equipe carregam o team_id nos metadados do checkout
+	if teamIDString, ok := stripeSub.Metadata["team_id"]; ok {
+		teamID, err := strconv.ParseUint(teamIDString, 10, 64)
+		if err != nil {
+			return models.Subscription{}, err
+		}
+		sub.TeamID = &teamID
+	}
+
+	return sub, nil
+}
+
+// Escolhe o item que define o plano: o primeiro com preço mapeado em plans, senão o primeiro item não medido
+func planItem(ctx context.Context, repo repository.Repository, stripeSub stripe.Subscription) (*stripe.SubscriptionItem, error) {
+
+	if stripeSub.Items == nil {
+		return nil, errors.New("subscription has no items")
+	}
+
+	var fallback *stripe.SubscriptionItem
+
+	for _, item := range stripeSub.Items.Data {
+		if item == nil || item.Price == nil {
+			continue
+		}
+
+		if _, err := repo.Plans.GetByPriceID(ctx, item.Price.ID); err == nil {
+			return item, nil
+		}
+
+		metered := item.Price.Recurring != nil && item.Price.Recurring.UsageType == stripe.PriceRecurringUsageTypeMetered
+		if fallback == nil && !metered {
+			fallback = item
+		}
+	}
+
+	if fallback == nil {
+		return nil, errors.New("subscription has no licensed items")
+	}
+
+	return fallback, nil
+}
+
+func (s *WebhookServices) processTrialWillEnd(ctx context.Context, stripeSub stripe.Subscription) error {
+
+	sub, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, stripeSub.ID)
+	if err != nil {
+		return fmt.Errorf("subscription %s: %w", stripeSub.ID, err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Notifications.Create(ctx, tx, models.Notification{
+		ReceiverID:  sub.UserID,
+		Type:        enums.TRIAL_WILL_END,
+		ReferenceID: sub.ID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *WebhookServices) processInvoiceEvent(ctx context.Context, stripeInvoice stripe.Invoice, paymentFailed bool, eventAt time.Time) error {
+
+	if stripeInvoice.Customer == nil {
+		return errors.New("invoice has no customer")
+	}
+
+	user, err := s.repo.Users.GetByStripeCustomerID(ctx, stripeInvoice.Customer.ID)
+	if err != nil {
+		return fmt.Errorf("customer %s: %w", stripeInvoice.Customer.ID, err)
+	}
+
+	inv := models.Invoice{
+		InvoiceID:        stripeInvoice.ID,
+		UserID:           user.ID,
+		Status:           mapStripeInvoiceStatusToEnum(string(stripeInvoice.Status)),
+		Currency:         string(stripeInvoice.Currency),
+		AmountDue:        stripeInvoice.AmountDue,
+		AmountPaid:       stripeInvoice.AmountPaid,
+		HostedInvoiceURL: stripeInvoice.HostedInvoiceURL,
+		PeriodStart:      time.Unix(stripeInvoice.PeriodStart, 0),
+		PeriodEnd:        time.Unix(stripeInvoice.PeriodEnd, 0),
+		CreatedAt:        time.Unix(stripeInvoice.Created, 0),
+	}
+
+	if stripeInvoice.Subscription != nil {
+		inv.SubscriptionID = stripeInvoice.Subscription.ID
+
+		if sub, err := s.repo.Subscriptions.GetBySubscriptionID(ctx, inv.SubscriptionID); err == nil {
+			inv.TeamID = sub.TeamID
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	inv, applied, err := s.repo.Invoices.Upsert(ctx, tx, inv, eventAt)
+	if err != nil {
+		return err
+	}
+
+	if paymentFailed && applied {
+		if _, err := s.repo.Notifications.Create(ctx, tx, models.Notification{
+			ReceiverID:  user.ID,
+			Type:        enums.PAYMENT_FAILED,
+			ReferenceID: inv.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Desvincula o cliente removido no Stripe do usuário local
+func (s *WebhookServices) processCustomerDeleted(ctx context.Context, customer stripe.Customer) error {
+
+	user, err := s.repo.Users.GetByStripeCustomerID(ctx, customer.ID)
+	if err != nil {
+		// Nenhum usuário vinculado, nada a fazer
+		return nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Users.SetStripeCustomerID(ctx, tx, user.ID, ""); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *WebhookServices) processChargeRefunded(ctx context.Context, charge stripe.Charge) error {
+
+	// Cobranças avulsas não têm fatura local
+	if charge.Invoice == nil {
+		return nil
+	}
+
+	status := invoice.PARTIALLY_REFUNDED
+	if charge.Refunded {
+		status = invoice.REFUNDED
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.repo.Invoices.SetRefund(ctx, tx, charge.Invoice.ID, charge.AmountRefunded, status); err != nil {
+		return fmt.Errorf("invoice %s: %w", charge.Invoice.ID, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := time.Duration(math.Pow(2, float64(attempts))) * 30 * time.Second
+	if backoff > 6*time.Hour {
+		return 6 * time.Hour
+	}
+	return backoff
+}
+
+func mapStripeInvoiceStatusToEnum(status string) invoice.Invoice {
+	switch strings.ToLower(status) {
+	case "draft":
+		return invoice.DRAFT
+	case "open":
+		return invoice.OPEN
+	case "paid":
+		return invoice.PAID
+	case "uncollectible":
+		return invoice.UNCOLLECTIBLE
+	case "void":
+		return invoice.VOID
+	default:
+		return invoice.UNKNOWN
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"HareID/internal/enums/subscription"
+	"HareID/internal/payments"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAsaasStatusChange(t *testing.T) {
+	provider := payments.NewAsaas("", "", "", time.Second)
+
+	tests := []struct {
+		fixture         string
+		wantStatus      subscription.Subscription
+		wantPeriodStart string
+		wantPeriodEnd   string
+	}{
+		// A renovação é gerada antes do vencimento e não pode rebaixar a assinatura paga
+		{"payment_created.json", subscription.UNKNOWN, "", ""},
+		{"payment_received.json", subscription.ACTIVE, "2026-06-01", "2026-07-01"},
+		{"payment_overdue.json", subscription.PAST_DUE, "", ""},
+		// O reembolso de uma cobrança não cancela a assinatura
+		{"payment_refunded.json", subscription.UNKNOWN, "", ""},
+		{"subscription_deleted.json", subscription.CANCELED, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("..", "payments", "testdata", "asaas", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event, err := payments.ParseAsaasEvent(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status, period, err := asaasStatusChange(provider, event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", status, tt.wantStatus)
+			}
+
+			switch {
+			case tt.wantPeriodEnd == "" && period != nil:
+				t.Errorf("period = %+v, want none", period)
+			case tt.wantPeriodEnd != "" && period == nil:
+				t.Errorf("period = none, want %s to %s", tt.wantPeriodStart, tt.wantPeriodEnd)
+			case tt.wantPeriodEnd != "" && (period.Start.Format("2006-01-02") != tt.wantPeriodStart || period.End.Format("2006-01-02") != tt.wantPeriodEnd):
+				t.Errorf("period = %s to %s, want %s to %s", period.Start.Format("2006-01-02"), period.End.Format("2006-01-02"), tt.wantPeriodStart, tt.wantPeriodEnd)
+			}
+		})
+	}
+}
+
+func TestAsaasStatusChangeInvalidDueDate(t *testing.T) {
+	provider := payments.NewAsaas("", "", "", time.Second)
+
+	event := payments.AsaasEvent{
+		ID:      "evt_1",
+		Event:   "PAYMENT_CONFIRMED",
+		Payment: &payments.AsaasPayment{Subscription: "sub_1", Status: "CONFIRMED", DueDate: "01/06/2026"},
+	}
+
+	if _, _, err := asaasStatusChange(provider, event); err == nil {
+		t.Fatal("expected an error for an invalid due date")
+	}
+}
@@ -0,0 +1,151 @@
+package validators
+
+import (
+	"HareID/internal/enums"
+	"errors"
+	"strings"
+)
+
+var (
+	errInvalidDocument = errors.New("must be a valid CPF (11 digits) or CNPJ (14 characters)")
+	errInvalidCPF      = errors.New("invalid CPF check digits")
+	errInvalidCNPJ     = errors.New("invalid CNPJ check digits")
+)
+
+// Deixa o documento só com dígitos (e letras, no CNPJ alfanumérico), em maiúsculas.
+// Pontos, traços, barras e espaços são removidos; qualquer outro caractere é mantido para falhar na validação
+func NormalizeDocument(value string) string {
+	var normalized strings.Builder
+
+	for _, r := range strings.ToUpper(strings.TrimSpace(value)) {
+		switch r {
+		case '.', '-', '/', ' ':
+			continue
+		}
+		normalized.WriteRune(r)
+	}
+
+	return normalized.String()
+}
+
+// Identifica e valida o documento já normalizado, incluindo os dígitos verificadores
+func ValidateDocument(document string) (enums.DocumentType, error) {
+	switch len(document) {
+	case 11:
+		if !isDigits(document) {
+			return enums.DOCUMENT_NONE, errInvalidDocument
+		}
+		if !validCPF(document) {
+			return enums.DOCUMENT_CPF, errInvalidCPF
+		}
+		return enums.DOCUMENT_CPF, nil
+	case 14:
+		// Desde 2026 o CNPJ pode ter letras nas 12 primeiras posições; os verificadores continuam numéricos
+		if !isAlphanumeric(document[:12]) || !isDigits(document[12:]) {
+			return enums.DOCUMENT_NONE, errInvalidDocument
+		}
+		if !validCNPJ(document) {
+			return enums.DOCUMENT_CNPJ, errInvalidCNPJ
+		}
+		return enums.DOCUMENT_CNPJ, nil
+	default:
+		return enums.DOCUMENT_NONE, errInvalidDocument
+	}
+}
+
+// Tipo do documento normalizado, sem validar os verificadores
+func DocumentTypeOf(document string) enums.DocumentType {
+	switch len(document) {
+	case 11:
+		return enums.DOCUMENT_CPF
+	case 14:
+		return enums.DOCUMENT_CNPJ
+	default:
+		return enums.DOCUMENT_NONE
+	}
+}
+
+// Formata o documento normalizado: 000.000.000-00 ou 00.000.000/0000-00. Outros valores voltam como estão
+func FormatDocument(document string) string {
+	switch DocumentTypeOf(document) {
+	case enums.DOCUMENT_CPF:
+		return document[:3] + "." + document[3:6] + "." + document[6:9] + "-" + document[9:]
+	case enums.DOCUMENT_CNPJ:
+		return document[:2] + "." + document[2:5] + "." + document[5:8] + "/" + document[8:12] + "-" + document[12:]
+	default:
+		return document
+	}
+}
+
+func validCPF(cpf string) bool {
+	if allSame(cpf) {
+		return false
+	}
+
+	for _, size := range []int{9, 10} {
+		sum := 0
+		for i := 0; i < size; i++ {
+			sum += int(cpf[i]-'0') * (size + 1 - i)
+		}
+
+		digit := sum * 10 % 11
+		if digit == 10 {
+			digit = 0
+		}
+
+		if digit != int(cpf[size]-'0') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Cada caractere vale seu código ASCII menos 48, o que mantém o cálculo antigo para CNPJs só com dígitos
+func validCNPJ(cnpj string) bool {
+	if allSame(cnpj) {
+		return false
+	}
+
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+
+	for _, size := range []int{12, 13} {
+		sum := 0
+		for i := 0; i < size; i++ {
+			sum += int(cnpj[i]-'0') * weights[i+13-size]
+		}
+
+		digit := 0
+		if rest := sum % 11; rest >= 2 {
+			digit = 11 - rest
+		}
+
+		if digit != int(cnpj[size]-'0') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(value string) bool {
+	for _, r := range value {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func allSame(value string) bool {
+	return strings.Count(value, value[:1]) == len(value)
+}
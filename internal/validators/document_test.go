@@ -0,0 +1,99 @@
+package validators
+
+import (
+	"HareID/internal/enums"
+	"testing"
+)
+
+func TestNormalizeDocument(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"529.982.247-25", "52998224725"},
+		{" 11.222.333/0001-81 ", "11222333000181"},
+		{"12.abc.345/01de-35", "12ABC34501DE35"},
+		{"529_982_247_25", "529_982_247_25"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeDocument(tt.value); got != tt.want {
+			t.Errorf("NormalizeDocument(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		wantType enums.DocumentType
+		wantErr  error
+	}{
+		{"valid cpf", "52998224725", enums.DOCUMENT_CPF, nil},
+		{"another valid cpf", "11144477735", enums.DOCUMENT_CPF, nil},
+		{"cpf with wrong check digit", "52998224726", enums.DOCUMENT_CPF, errInvalidCPF},
+		{"cpf with repeated digits", "11111111111", enums.DOCUMENT_CPF, errInvalidCPF},
+		{"cpf with letters", "5299822472A", enums.DOCUMENT_NONE, errInvalidDocument},
+		{"valid cnpj", "11222333000181", enums.DOCUMENT_CNPJ, nil},
+		{"valid alphanumeric cnpj", "12ABC34501DE35", enums.DOCUMENT_CNPJ, nil},
+		{"cnpj with wrong check digit", "11222333000182", enums.DOCUMENT_CNPJ, errInvalidCNPJ},
+		{"alphanumeric cnpj with wrong check digit", "12ABC34501DE36", enums.DOCUMENT_CNPJ, errInvalidCNPJ},
+		{"cnpj with repeated digits", "00000000000000", enums.DOCUMENT_CNPJ, errInvalidCNPJ},
+		{"cnpj with letters in check digits", "12ABC34501DE3A", enums.DOCUMENT_NONE, errInvalidDocument},
+		{"cnpj with lowercase letters", "12abc34501de35", enums.DOCUMENT_NONE, errInvalidDocument},
+		{"too short", "1234567890", enums.DOCUMENT_NONE, errInvalidDocument},
+		{"too long", "112223330001811", enums.DOCUMENT_NONE, errInvalidDocument},
+		{"empty", "", enums.DOCUMENT_NONE, errInvalidDocument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, err := ValidateDocument(tt.document)
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if gotType != tt.wantType {
+				t.Errorf("type = %v, want %v", gotType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestFormatDocument(t *testing.T) {
+	tests := []struct {
+		document string
+		want     string
+	}{
+		{"52998224725", "529.982.247-25"},
+		{"11222333000181", "11.222.333/0001-81"},
+		{"12ABC34501DE35", "12.ABC.345/01DE-35"},
+		{"12345", "12345"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDocument(tt.document); got != tt.want {
+			t.Errorf("FormatDocument(%q) = %q, want %q", tt.document, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentTypeOf(t *testing.T) {
+	tests := []struct {
+		document string
+		want     enums.DocumentType
+	}{
+		{"52998224725", enums.DOCUMENT_CPF},
+		{"11222333000181", enums.DOCUMENT_CNPJ},
+		{"123", enums.DOCUMENT_NONE},
+		{"", enums.DOCUMENT_NONE},
+	}
+
+	for _, tt := range tests {
+		if got := DocumentTypeOf(tt.document); got != tt.want {
+			t.Errorf("DocumentTypeOf(%q) = %v, want %v", tt.document, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package validators
+
+import (
+	"sort"
+	"strings"
+)
+
+// Erros de validação por campo. A resposta da API traz cada campo com a sua mensagem
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field+": "+e[field])
+	}
+
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e FieldErrors) Fields() map[string]string {
+	return e
+}
@@ -2,6 +2,7 @@ package validators
 
 import (
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
 )
 
@@ -10,6 +11,8 @@ type JoinRequestValidations struct {
 }
 
 func (v *JoinRequestValidations) CanSee(ctx context.Context, requestUserID, requestID, teamID uint64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "JoinRequestValidations.CanSee")
+	defer span.End()
 
 	request, err := v.repo.JoinRequests.GetByID(ctx, requestID, teamID)
 	if err != nil {
@@ -1,10 +1,11 @@
 package validators
 
 import (
-	"HareID/internal/models"
+	"HareID/internal/listquery"
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
-	"slices"
+	"strconv"
 )
 
 type TeamMemberValidations struct {
@@ -12,14 +13,16 @@ type TeamMemberValidations struct {
 }
 
 func (v *TeamMemberValidations) IsTeamMember(ctx context.Context, userID, teamID uint64) (bool, error) {
-	members, err := v.repo.TeamMembers.GetAll(ctx, teamID)
+	ctx, span := tracing.Start(ctx, "TeamMemberValidations.IsTeamMember")
+	defer span.End()
+
+	members, err := v.repo.TeamMembers.GetAll(ctx, teamID, listquery.Params{
+		Limit:   1,
+		Filters: []listquery.Filter{{Field: "user_id", Operator: listquery.Eq, Value: strconv.FormatUint(userID, 10)}},
+	})
 	if err != nil {
 		return false, err
 	}
 
-	result := slices.ContainsFunc(members, func(member models.TeamMember) bool {
-		return member.UserID == userID
-	})
-
-	return result, nil
+	return len(members.Items) > 0, nil
 }
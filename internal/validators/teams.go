@@ -2,6 +2,7 @@ package validators
 
 import (
 	"HareID/internal/repository"
+	"HareID/internal/tracing"
 	"context"
 )
 
@@ -10,6 +11,8 @@ type TeamValidations struct {
 }
 
 func (r *TeamValidations) IsTeamOwner(ctx context.Context, userID, TeamID uint64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TeamValidations.IsTeamOwner")
+	defer span.End()
 
 	team, err := r.repo.Teams.GetByID(ctx, TeamID)
 	if err != nil {
@@ -1,6 +1,10 @@
 package validators
 
-import "HareID/internal/repository"
+import (
+	"HareID/internal/repository"
+	"HareID/internal/tracing"
+	"context"
+)
 
 type UserValidations struct {
 	repo repository.Repository
@@ -9,3 +13,29 @@ type UserValidations struct {
 func (v *UserValidations) CanModify(requestUserID, userID uint64) bool {
 	return requestUserID == userID
 }
+
+// Valida e normaliza o CPF/CNPJ e garante um documento por conta. Vazio é aceito (o documento é opcional)
+func (v *UserValidations) CpfCnpj(ctx context.Context, userID uint64, value string) (string, error) {
+	ctx, span := tracing.Start(ctx, "UserValidations.CpfCnpj")
+	defer span.End()
+
+	document := NormalizeDocument(value)
+	if document == "" {
+		return "", nil
+	}
+
+	if _, err := ValidateDocument(document); err != nil {
+		return "", FieldErrors{"cpf_cnpj": err.Error()}
+	}
+
+	taken, err := v.repo.Users.ExistsByCpfCnpj(ctx, document, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if taken {
+		return "", FieldErrors{"cpf_cnpj": "already registered to another account"}
+	}
+
+	return document, nil
+}
@@ -8,6 +8,7 @@ import (
 type Validations struct {
 	Users interface {
 		CanModify(requestUserID, userID uint64) bool
+		CpfCnpj(ctx context.Context, userID uint64, value string) (string, error)
 	}
 	Teams interface {
 		IsTeamOwner(ctx context.Context, userID, teamID uint64) (bool, error)